@@ -3,17 +3,24 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"gopkg.in/yaml.v3"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 
 	"codetest"
 	"codetest/internal/entity"
+	"codetest/internal/pkg/progress"
+	"codetest/internal/pkg/repoinfo"
 	"codetest/internal/usecase"
+	"codetest/internal/usecase/prompt"
 	"codetest/internal/usecase/repo"
-	"codetest/internal/usecase/web_api"
 	workflow_server "codetest/internal/usecase/workflow-server"
 
 	"github.com/spf13/cobra"
@@ -29,41 +36,94 @@ var (
 	languageVersion string
 	username        string
 	password        string
+	apiKey          string
 	apiBasePath     string
-	configFile      string // 新增：配置文件路径
+	budget          float64
+	priceTableFile  string
+	dryRun          bool
+	sinkNames       []string
+	summaryFormats  []string
+	retryFailed     bool
+	noCache         bool
+	resume          bool
+	failOnError     string
+	failThreshold   usecase.FailThreshold // 解析后的 --fail-on-error
+	since           string
+	diffRange       string
+	analyzedCommit  string        // 分析的提交 SHA，不在 git 仓库中时为空
+	repoInfo        repoinfo.Info // analyze 目录所在仓库的信息
+)
 
+// 支持的输出目标
+const (
+	sinkLocal          = "local"
+	sinkWorkflowServer = "workflow-server"
+	sinkSQLite         = "sqlite"
 )
 
-// Config 配置结构体，用于映射 YAML 文件
-type Config struct {
-	ProjectName     string `yaml:"project_name"`
-	Language        string `yaml:"language"`
-	LanguageVersion string `yaml:"language_version"`
-	ApiBasePath     string `yaml:"api_base_path"`
-	Username        string `yaml:"username"`
-	Password        string `yaml:"password"`
-	OutputDir       string `yaml:"output_dir"`
-	OpenAIToken     string `yaml:"openai_token"`
-	Dir             string `yaml:"dir"`
-	ProjectID       int    `yaml:"project_id"`
-}
+// outboxFileName 上传失败的代码片段保存在输出目录下的这个文件中
+const outboxFileName = "outbox.jsonl"
 
 // analyzeCmd 定义了分析命令
 var analyzeCmd = &cobra.Command{
 	Use:   "analyze",
 	Short: "Analyze code in the specified directory using AI",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// 读取配置文件
-		if err := loadConfig(configFile); err != nil {
-			return err
+		// 试运行不需要认证信息
+		if dryRun {
+			return runDryRun(dir)
 		}
 
-		// 确保必需的参数存在
-		if projectName == "" || language == "" || languageVersion == "" {
-			return fmt.Errorf("projectName, language, and languageVersion are required")
+		for _, name := range sinkNames {
+			switch name {
+			case sinkLocal, sinkSQLite:
+			case sinkWorkflowServer:
+				// 上传到 workflow server 时确保认证参数存在
+				if apiBasePath == "" || (apiKey == "" && (username == "" || password == "")) {
+					return newConfigError(fmt.Errorf("apiBasePath and either apiKey or username and password are required for --sink %s", sinkWorkflowServer))
+				}
+			default:
+				return newConfigError(fmt.Errorf("unknown sink %q, supported sinks: %s, %s, %s", name, sinkLocal, sinkWorkflowServer, sinkSQLite))
+			}
 		}
-		if username == "" || password == "" || apiBasePath == "" {
-			return fmt.Errorf("username, password, and apiBasePath are required for authentication")
+		if err := repo.ValidateSummaryFormats(summaryFormats); err != nil {
+			return newConfigError(err)
+		}
+		var err error
+		if failThreshold, err = usecase.ParseFailThreshold(failOnError); err != nil {
+			return newConfigError(err)
+		}
+		if since != "" || diffRange != "" {
+			if since != "" && diffRange != "" {
+				return newConfigError(fmt.Errorf("--since and --diff cannot be used together"))
+			}
+			if noCache {
+				return newConfigError(fmt.Errorf("--since and --diff reuse the stored results of unchanged files and cannot be used with --no-cache"))
+			}
+			// 没有变化的文件的结果和包总结从本地输出目录读取，缺少时汇总只会包含变化的文件
+			if !slices.Contains(sinkNames, sinkLocal) {
+				return newConfigError(fmt.Errorf("--since and --diff read the results of unchanged files from --output-dir and need --sink %s", sinkLocal))
+			}
+			if diffRange != "" {
+				if _, _, err := repoinfo.ParseDiffRange(diffRange); err != nil {
+					return newConfigError(err)
+				}
+			}
+		}
+		// 未指定的项目信息从仓库中检测
+		info, err := repoinfo.Detect(dir)
+		if err != nil {
+			return fmt.Errorf("failed to inspect repository: %v", err)
+		}
+		repoInfo = info
+		if projectName == "" {
+			projectName = info.Name
+		}
+		if language == "" {
+			language = info.Language
+		}
+		if languageVersion == "" {
+			languageVersion = info.LanguageVersion
 		}
 		return run(dir, openAIToken)
 	},
@@ -79,173 +139,607 @@ func init() {
 	analyzeCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "./result", "Directory to save analysis results")
 
 	// 新增的参数
-	analyzeCmd.Flags().StringVarP(&projectName, "project-name", "p", "", "Project name (required)")
-	analyzeCmd.Flags().IntVarP(&projectID, "project-id", "i", 0, "Project ID (required)")
-	analyzeCmd.Flags().StringVarP(&language, "language", "l", "", "Programming language (required)")
-	analyzeCmd.Flags().StringVarP(&languageVersion, "language-version", "v", "", "Programming language version (required)")
-	analyzeCmd.Flags().StringVarP(&username, "username", "u", "", "Username for authentication (required)")
-	analyzeCmd.Flags().StringVarP(&password, "password", "w", "", "Password for authentication (required)")
-	analyzeCmd.Flags().StringVarP(&apiBasePath, "api-base-path", "a", "", "Base API URL for the server (required)")
-	analyzeCmd.Flags().StringVarP(&configFile, "config", "c", "./config/config.yaml", "Path to the YAML configuration file")
-}
-
-// 加载配置文件
-func loadConfig(configFile string) error {
-	if configFile == "" {
-		return nil
+	analyzeCmd.Flags().StringSliceVar(&sinkNames, "sink", []string{sinkLocal}, "Where to save the results: local, workflow-server, sqlite (repeatable)")
+	analyzeCmd.Flags().StringSliceVar(&summaryFormats, "summary-format", []string{repo.SummaryFormatMarkdown}, "Formats of the regenerated file summary: markdown, json, yaml (repeatable)")
+	analyzeCmd.Flags().StringVarP(&projectName, "project-name", "p", "", "Project name, created on the workflow server if missing (defaults to the repository name)")
+	analyzeCmd.Flags().IntVarP(&projectID, "project-id", "i", 0, "Project ID on the workflow server, takes precedence over --project-name")
+	analyzeCmd.Flags().StringVarP(&language, "language", "l", "", "Programming language (detected from the repository by default)")
+	analyzeCmd.Flags().StringVarP(&languageVersion, "language-version", "v", "", "Programming language version (detected from the repository by default)")
+	analyzeCmd.Flags().StringVarP(&username, "username", "u", "", "Username for authentication (workflow-server)")
+	analyzeCmd.Flags().StringVarP(&password, "password", "w", "", "Password for authentication (workflow-server)")
+	analyzeCmd.Flags().StringVar(&apiKey, "api-key", "", "API key for authentication instead of username and password (workflow-server)")
+	addSecretFileFlags(analyzeCmd.Flags(), "token", "password", "api-key")
+	analyzeCmd.Flags().StringVarP(&apiBasePath, "api-base-path", "a", "", "Base API URL for the server (workflow-server)")
+	addBudgetFlags(analyzeCmd)
+	analyzeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only estimate files, tokens and cost without calling the LLM or logging in")
+	analyzeCmd.Flags().BoolVar(&retryFailed, "retry-failed", false, "Only re-analyze the files in <output-dir>/"+usecase.FailuresFileName+" from the previous run")
+	analyzeCmd.Flags().BoolVar(&noCache, "no-cache", false, "Re-analyze files even when a result for the same content, model and prompt exists")
+	analyzeCmd.Flags().BoolVar(&resume, "resume", false, "Continue the unfinished run in <output-dir>/"+usecase.CheckpointFileName+" instead of starting over")
+	analyzeCmd.Flags().StringVar(&failOnError, "fail-on-error", "1",
+		"Exit with code 4 when at least this many files (e.g. 10) or this share of files (e.g. 5%) failed, 0 never fails on file errors")
+	analyzeCmd.Flags().StringVar(&since, "since", "", "Only re-analyze files changed since this git ref, including uncommitted and new files")
+	analyzeCmd.Flags().StringVar(&diffRange, "diff", "", "Only re-analyze files changed between two git refs, as base..head")
+	addDBFlag(analyzeCmd.Flags())
+	addPromptFlags(analyzeCmd)
+}
+
+// run 主要逻辑
+func run(directory, token string) error {
+	prompts, err := loadPromptSet()
+	if err != nil {
+		return err
 	}
 
-	file, err := os.Open(configFile)
+	prices, err := usecase.LoadPriceTable(priceTableFile)
 	if err != nil {
-		return fmt.Errorf("failed to open config file: %v", err)
+		return err
 	}
-	defer file.Close()
 
-	var config Config
-	decoder := yaml.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
-		return fmt.Errorf("failed to decode config file: %v", err)
+	// 创建 API 客户端
+	chatClient, model := newLLMClient(token)
+	llmClient := usecase.NewUsageMeter(chatClient, prices, budget, model)
+	llmClient.RunID = runID
+	aiCode := usecase.NewAiCode(llmClient, prompts)
+
+	// --since、--diff 时只分析变化的文件
+	changed, deleted, err := changedFiles(directory)
+	if err != nil {
+		return err
 	}
+	llmClient.Commit = analyzedCommit
 
-	// 使用配置文件中的值，覆盖命令行传递的参数（如果有的话）
-	if projectName == "" {
-		projectName = config.ProjectName
+	// 保存到 SQLite 时记录本次运行
+	var store *repo.SQLiteStore
+	if slices.Contains(sinkNames, sinkSQLite) {
+		if store, err = openStore(directory); err != nil {
+			return err
+		}
+		defer store.CloseDB()
+		if err := store.BeginRun(context.Background(), projectName, model, analyzedCommit); err != nil {
+			return err
+		}
 	}
-	if language == "" {
-		language = config.Language
+
+	sink, err := newSink(context.Background(), store)
+	if err != nil {
+		return err
 	}
-	if languageVersion == "" {
-		languageVersion = config.LanguageVersion
+
+	// 结束时（包括出错时）输出 LLM 用量
+	defer writeUsageReport(llmClient, store)
+
+	// 中断（Ctrl-C）后保留 checkpoint，下次用 --resume 继续
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	checkpoint, err := openCheckpoint(directory, model, aiCode)
+	if err != nil {
+		return err
+	}
+	defer checkpoint.Close()
+	retry, err := loadRetryFiles()
+	if err != nil {
+		return err
+	}
+	var cache *repo.CodeSummary
+	if !noCache {
+		cache = repo.NewCodeSummaryRepo(outputDir, directory)
 	}
-	if apiBasePath == "" {
-		apiBasePath = config.ApiBasePath
+
+	// 之前运行中已处理完的文件直接使用保存的结果
+	runSummary := usecase.NewRunSummary()
+	var results []entity.FileResult
+	completed := checkpoint.Completed()
+	for _, entry := range completed {
+		var err error
+		if entry.Error != "" {
+			err = errors.New(entry.Error)
+		}
+		runSummary.Add(entry.Path, entry.Status, err)
+		if entry.Status == usecase.FileSucceeded || entry.Status == usecase.FileCached {
+			result, err := entry.Result(projectName)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
 	}
-	if username == "" {
-		username = config.Username
+
+	// 终端中显示进度条，期间的日志先清除进度条再输出
+	bar := progress.New(os.Stderr, len(checkpoint.Files))
+	if bar.Interactive() && logFile == "" {
+		if err := setLogOutput(bar.Writer(os.Stderr)); err != nil {
+			return err
+		}
+		defer setLogOutput(os.Stderr)
 	}
-	if password == "" {
-		password = config.Password
+
+	budgetExceeded := false
+	var authErr error
+	// 处理每个文件，超出预算后剩余的文件只使用缓存
+	for i, path := range checkpoint.Pending() {
+		if ctx.Err() != nil {
+			break
+		}
+		analyze := !budgetExceeded && (retry == nil || retry[path])
+		var result entity.FileResult
+		var err error
+		status := usecase.FileSkipped
+		if changed != nil && !changed[path] {
+			// 没有变化的文件只读取保存的结果用于汇总，输出目标中的记录保持不变
+			result, status, err = processFile(ctx, path, model, aiCode, nil, cache, false)
+			if status == usecase.FileSkipped {
+				slog.Info("No stored result for unchanged file, analyzing it", "file", path)
+			}
+		}
+		if status == usecase.FileSkipped {
+			result, status, err = processFile(ctx, path, model, aiCode, sink, cache, analyze)
+		}
+		if ctx.Err() != nil {
+			// 中断时正在处理的文件留到下次
+			break
+		}
+		if errors.Is(err, entity.ErrUnauthorized) {
+			// 认证失败时其余文件也会失败，停止并留到下次
+			authErr = err
+			break
+		}
+		if errors.Is(err, usecase.ErrBudgetExceeded) {
+			budgetExceeded, status = true, usecase.FileSkipped
+		}
+		runSummary.Add(path, status, err)
+		if status == usecase.FileSucceeded || status == usecase.FileCached {
+			result.Code = "" // 汇总时不需要源码
+			results = append(results, result)
+		}
+		// 因预算跳过的文件留到下次，其余文件记录为已处理
+		if status != usecase.FileSkipped || !budgetExceeded {
+			entry := usecase.CheckpointEntry{Path: path, Status: status, Raw: result.Raw, Meta: result.Meta}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if err := checkpoint.Record(entry); err != nil {
+				return err
+			}
+		}
+		usage := llmClient.Total()
+		bar.Update(len(completed)+i+1, usage.PromptTokens+usage.CompletionTokens)
 	}
-	if outputDir == "" {
-		outputDir = config.OutputDir
+	bar.Finish()
+
+	// 所有文件都处理完后才汇总和上传项目信息
+	pending := len(checkpoint.Pending())
+	printRunSummary(runSummary, pending == 0)
+	if pending > 0 {
+		flushSink(sink)
+		switch {
+		case authErr != nil:
+			return fmt.Errorf("stopped with %d files pending, fix the credentials and run analyze --resume to continue: %w", pending, authErr)
+		case ctx.Err() != nil:
+			return fmt.Errorf("%w with %d files pending, run analyze --resume to continue", errInterrupted, pending)
+		}
+		return fmt.Errorf("stopped with %d files pending, run analyze --resume to continue: %w", pending, usecase.ErrBudgetExceeded)
 	}
-	if openAIToken == "" {
-		openAIToken = config.OpenAIToken
+	count := len(results)
+
+	// 所有文件都失败时没有可以汇总的结果
+	fileErr := runSummary.Err()
+	var fileErrs *usecase.FileErrors
+	if errors.As(fileErr, &fileErrs) && fileErrs.AllFailed() {
+		if err := checkpoint.Remove(); err != nil {
+			slog.Error("Failed to remove checkpoint", "error", err)
+		}
+		return fileErr
 	}
-	if dir == "" {
-		dir = config.Dir
+
+	// 删除或重命名的文件从输出目标中删除
+	if remover, ok := sink.(usecase.Remover); ok {
+		for _, path := range deleted {
+			if err := remover.RemoveFileResult(ctx, path); err != nil {
+				slog.Error("Failed to remove result of deleted file", "file", path, "sink", sink.Name(), "error", err)
+			}
+		}
 	}
 
-	if projectID == 0 {
-		projectID = config.ProjectID
+	// 其余文件都被跳过时没有可以汇总的结果，输出目标中保持之前的汇总
+	if count == 0 {
+		slog.Warn("No file results to summarize, the project summary is left unchanged")
+		if err := checkpoint.Remove(); err != nil {
+			return err
+		}
+		flushSink(sink)
+		if fileErrs != nil && failThreshold.Exceeded(len(fileErrs.Errors), fileErrs.Files) {
+			return fileErr
+		}
+		return nil
 	}
 
+	projectSummary, err := summarizeAndClose(ctx, aiCode, sink, results, reusablePackages(cache, changed, deleted))
+	if err != nil {
+		return err
+	}
+	if err := checkpoint.Remove(); err != nil {
+		return err
+	}
+	fmt.Printf("Processed %d files in %d packages, results saved to %s\n", count, len(projectSummary.Packages), sink.Name())
+	if fileErrs != nil && failThreshold.Exceeded(len(fileErrs.Errors), fileErrs.Files) {
+		return fileErr
+	}
 	return nil
 }
 
-// run 主要逻辑
-func run(directory, token string) error {
-	// 创建 API 客户端
-	llmClient := web_api.NewChatGPTClient(token)
-	codeSummaryRepo := repo.NewCodeSummaryRepo(outputDir)
-	apiClient := workflow_server.NewApiClient(apiBasePath, username, password) // 使用新的身份认证参数
-	loginRes, err := apiClient.Login(context.Background())
+// writeUsageReport 把 LLM 用量写入 run_report.json 并输出汇总，store 不为空时同时保存到数据库
+func writeUsageReport(llmClient *usecase.UsageMeter, store *repo.SQLiteStore) {
+	reportPath, err := llmClient.WriteReport(outputDir)
 	if err != nil {
-		log.Printf("Failed to login: %v\n", err)
-		return err
+		slog.Error("Failed to write run report", "error", err)
 	}
-	log.Println("Login Success:", loginRes)
-	aiCode := usecase.NewAiCode(llmClient, apiClient)
-
-	var count int
-	// 遍历目录并处理每个文件
-	err = code.WalkDir(directory, func(path string) {
-		if err := processFile(path, aiCode, codeSummaryRepo); err == nil {
-			count++
+	report := llmClient.Report()
+	if store != nil {
+		if err := store.FinishRun(context.Background(), report); err != nil {
+			slog.Error("Failed to save token usage", "error", err)
 		}
-	})
+	}
+	fmt.Printf("LLM usage: %d calls, %d prompt tokens, %d completion tokens, cost %.4f %s (report: %s)\n",
+		report.Total.Calls, report.Total.PromptTokens, report.Total.CompletionTokens, report.Total.Cost, report.Currency, reportPath)
+}
 
-	// 上报项目的汇总信息
-	{
-		// 获取项目详情
-		project, err := apiClient.GetProjectByID(context.Background(), uint(projectID))
-		if err != nil {
-			log.Printf("Failed to get project details: %v\n", err)
-			return err
+// reusablePackages --since、--diff 时返回输出目录中保存的、没有变化的文件的包总结，汇总时直接使用；
+// 其余情况或没有保存的总结时返回 nil，所有包都重新总结
+func reusablePackages(cache *repo.CodeSummary, changed map[string]bool, deleted []string) map[string]entity.PackageSummary {
+	if changed == nil || cache == nil {
+		return nil
+	}
+	previous, err := cache.LoadProjectSummary()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed to load the previous project summary, summarizing all packages", "error", err)
 		}
-		summaryFilePath := filepath.Join(outputDir, "summary.md")
-		summary, err := os.ReadFile(summaryFilePath)
-		if err != nil {
-			log.Printf("Failed to read summary file: %v\n", err)
-			return err
+		return nil
+	}
+	changedDirs := map[string]bool{}
+	for path := range changed {
+		changedDirs[filepath.Dir(path)] = true
+	}
+	for _, path := range deleted {
+		changedDirs[filepath.Dir(path)] = true
+	}
+	reuse := map[string]entity.PackageSummary{}
+	for _, pkg := range previous.Packages {
+		if !changedDirs[pkg.Path] {
+			reuse[pkg.Path] = pkg
 		}
+	}
+	return reuse
+}
 
-		project.Desc = string(summary)
-		if err := apiClient.UpdateProject(context.Background(), project); err != nil {
-			log.Printf("Failed to update project: %v\n", err)
-			return err
+// summarizeAndClose 逐层汇总（文件 -> 包 -> 项目）后把项目的汇总信息交给 sink 并关闭它，reuse 中的包总结直接使用。
+// 汇总失败时 checkpoint 保留，--resume 不会再次发送已处理的文件，所以先把缓冲中的文件结果写出
+func summarizeAndClose(ctx context.Context, aiCode usecase.AICodeUseCase, sink usecase.Sink, results []entity.FileResult,
+	reuse map[string]entity.PackageSummary) (entity.ProjectSummary, error) {
+	projectSummary, err := aiCode.SummarizeProject(ctx, projectName, results, reuse)
+	if err != nil {
+		slog.Error("Failed to summarize project", "error", err)
+		flushSink(sink)
+		return projectSummary, fmt.Errorf("failed to summarize project, run analyze --resume to retry: %w", err)
+	}
+
+	if err := sink.Close(ctx, projectSummary); err != nil {
+		slog.Error("Failed to finish sink", "sink", sink.Name(), "error", err)
+		return projectSummary, err
+	}
+	return projectSummary, nil
+}
+
+// flushSink 写出 sink 缓冲中的文件结果，ctx 可能已被中断取消，使用新的 context
+func flushSink(sink usecase.Sink) {
+	if flusher, ok := sink.(usecase.Flusher); ok {
+		if err := flusher.Flush(context.Background()); err != nil {
+			slog.Error("Failed to flush sink", "sink", sink.Name(), "error", err)
+		}
+	}
+}
+
+// newSink 根据 --sink 创建输出目标，store 为 --sink sqlite 时已打开的数据库
+func newSink(ctx context.Context, store *repo.SQLiteStore) (usecase.Sink, error) {
+	var sinks []usecase.Sink
+	for _, name := range sinkNames {
+		switch name {
+		case sinkLocal:
+			local := repo.NewCodeSummaryRepo(outputDir, dir)
+			local.SummaryFormats = summaryFormats
+			sinks = append(sinks, local)
+		case sinkSQLite:
+			sinks = append(sinks, store)
+		case sinkWorkflowServer:
+			if replayClient != nil {
+				return nil, fmt.Errorf("--sink %s needs network access and cannot be used when replaying a transcript", sinkWorkflowServer)
+			}
+			apiClient, err := newApiClient(ctx)
+			if err != nil {
+				return nil, err
+			}
+			project, err := resolveProject(ctx, apiClient)
+			if err != nil {
+				return nil, err
+			}
+			project.Language, project.LanguageVersion = language, languageVersion
+			project.CommitSHA = analyzedCommit
+			outbox := workflow_server.NewOutbox(filepath.Join(outputDir, outboxFileName))
+			sinks = append(sinks, workflow_server.NewSink(apiClient, outbox, *project))
 		}
 	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return usecase.NewMultiSink(sinks...), nil
+}
+
+// addBudgetFlags 注册 --budget 和 --price-table，用于调用 LLM 的命令
+func addBudgetFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&budget, "budget", 0, "Stop before the LLM spend (USD) would exceed this limit, 0 means no limit")
+	cmd.Flags().StringVar(&priceTableFile, "price-table", "", "YAML file with model prices per million tokens, merged over the defaults")
+}
+
+// newApiClient 创建 workflow server 客户端：优先使用 API key，否则使用用户名密码登录，token 过期后会自动重新登录
+func newApiClient(ctx context.Context) (*workflow_server.ApiClient, error) {
+	if apiKey != "" {
+		return workflow_server.NewApiClientWithAPIKey(apiBasePath, apiKey), nil
+	}
+	apiClient := workflow_server.NewApiClient(apiBasePath, username, password)
+	if _, err := apiClient.Login(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to login", "server", apiBasePath, "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Logged in", "server", apiBasePath, "username", username)
+	return apiClient, nil
+}
 
+// resolveProject 找到本次分析对应的项目：指定了 --project-id 时直接获取，
+// 否则按 --project-name 查找，不存在时用仓库信息创建；已有项目缺少的地址、语言和标签会用仓库信息补全
+func resolveProject(ctx context.Context, apiClient *workflow_server.ApiClient) (*workflow_server.Project, error) {
+	if projectID != 0 {
+		return apiClient.GetProjectByID(ctx, uint(projectID))
+	}
+
+	info := repoInfo
+	info.Language, info.LanguageVersion = language, languageVersion
+	tags := info.Tags()
+	project, err := apiClient.FindProjectByName(ctx, projectName)
+	if errors.Is(err, workflow_server.ErrProjectNotFound) {
+		project, err = apiClient.CreateProject(ctx, &workflow_server.Project{
+			Name:            projectName,
+			GitUrl:          repoInfo.GitURL,
+			Language:        language,
+			LanguageVersion: languageVersion,
+			Tags:            tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "Created project", "project", project.Name, "id", project.ID)
+		return project, nil
+	}
 	if err != nil {
-		log.Printf("Error during directory traversal: %v\n", err)
-		return err
+		return nil, err
 	}
-	fmt.Printf("Processed %d files\n", count)
-	return nil
+
+	changed := false
+	if project.GitUrl == "" && repoInfo.GitURL != "" {
+		project.GitUrl, changed = repoInfo.GitURL, true
+	}
+	if project.Language == "" && language != "" {
+		project.Language, changed = language, true
+	}
+	if project.LanguageVersion == "" && languageVersion != "" {
+		project.LanguageVersion, changed = languageVersion, true
+	}
+	if merged := workflow_server.MergeTags(project.Tags, tags); len(merged) != len(project.Tags) {
+		project.Tags, changed = merged, true
+	}
+	if changed {
+		if err := apiClient.UpdateProject(ctx, project); err != nil {
+			return nil, err
+		}
+	}
+	return project, nil
 }
 
-// 处理单个文件
-func processFile(path string, aiClient usecase.AICodeUseCase, repo *repo.CodeSummary) error {
-	fmt.Println("Processing file:", path)
+// processFile 处理单个文件，返回处理结果（usecase.FileSucceeded 等）。
+// cache 不为空且内容和提示词没有变化时使用之前保存的结果；analyze 为 false 时不调用 LLM，没有缓存的文件跳过；
+// sink 为 nil 时不保存结果。
+func processFile(ctx context.Context, path, model string, aiClient usecase.AICodeUseCase, sink usecase.Sink,
+	cache *repo.CodeSummary, analyze bool) (entity.FileResult, string, error) {
+	slog.DebugContext(ctx, "Processing file", "file", path)
 
 	// 读取文件内容
 	fileContent, err := os.ReadFile(path)
 	if err != nil {
-		log.Printf("Failed to read file %s: %v\n", path, err)
-		return fmt.Errorf("failed to read file %s: %v", path, err)
+		slog.ErrorContext(ctx, "Failed to read file", "file", path, "error", err)
+		return entity.FileResult{}, usecase.FileFailed, fmt.Errorf("failed to read file %s: %v", path, err)
+	}
+	meta := entity.ResultMeta{
+		Model:          model,
+		PromptVersion:  aiClient.PromptVersion(prompt.FileAnalysis),
+		OutputLanguage: outputLanguage,
 	}
 
-	// 调用 AI 进行分析
-	rawAiResponse, yamlResult, err := aiClient.AIAnalysisCode(path, string(fileContent))
+	status := usecase.FileSucceeded
+	result, ok := entity.FileResult{}, false
+	if cache != nil {
+		result, ok = cache.CachedResult(path, string(fileContent), meta)
+	}
+	if ok {
+		status = usecase.FileCached
+		result.ProjectName = projectName
+	} else {
+		if !analyze {
+			return entity.FileResult{}, usecase.FileSkipped, nil
+		}
+		// 调用 AI 进行分析
+		rawAiResponse, yamlResult, err := aiClient.AIAnalysisCode(ctx, path, string(fileContent))
+		if err != nil {
+			slog.ErrorContext(ctx, "AI analysis failed", "file", path, "error", err)
+			return entity.FileResult{}, usecase.FileFailed, fmt.Errorf("AI analysis failed for %s: %w", path, err)
+		}
+		result = entity.FileResult{
+			ProjectName: projectName,
+			Path:        path,
+			Code:        string(fileContent),
+			Raw:         rawAiResponse,
+			Parsed:      yamlResult,
+			Meta:        meta,
+		}
+	}
+
+	// 保存 AI 分析结果，缓存的结果也保存到每个输出目标
+	if sink == nil {
+		return result, status, nil
+	}
+	if err := sink.SaveFileResult(ctx, result); err != nil {
+		slog.ErrorContext(ctx, "Failed to save AI result", "file", path, "sink", sink.Name(), "error", err)
+		return result, usecase.FileFailed, fmt.Errorf("failed to save AI result for %s: %v", path, err)
+	}
+	return result, status, nil
+}
+
+// changedFiles 按 --since 或 --diff 返回需要重新分析的文件和删除的文件，路径与 WalkDir 返回的一致，
+// 都没有指定时 changed 为 nil。同时把分析的提交记录到 analyzedCommit。
+func changedFiles(directory string) (changed map[string]bool, deleted []string, err error) {
+	analyzedCommit = repoinfo.HeadCommit(directory)
+	base, head := since, ""
+	if diffRange != "" {
+		base, head, _ = repoinfo.ParseDiffRange(diffRange)
+		if analyzedCommit, err = repoinfo.ResolveCommit(directory, head); err != nil {
+			return nil, nil, newConfigError(err)
+		}
+		// 文件内容从工作区读取，结果会记录为 head 的分析结果，所以工作区必须与 head 一致
+		if current := repoinfo.HeadCommit(directory); current != analyzedCommit {
+			return nil, nil, newConfigError(fmt.Errorf("--diff %s needs %s checked out, the working tree is at %s", diffRange, head, current))
+		}
+		dirty, err := repoinfo.Dirty(directory)
+		if err != nil {
+			return nil, nil, newConfigError(err)
+		}
+		if dirty {
+			return nil, nil, newConfigError(fmt.Errorf("--diff %s needs a clean working tree, commit or stash the changes in %s first", diffRange, directory))
+		}
+	}
+	if base == "" {
+		return nil, nil, nil
+	}
+
+	changes, err := repoinfo.Diff(directory, base, head)
 	if err != nil {
-		log.Printf("AI analysis failed for %s: %v\n", path, err)
-		return fmt.Errorf("AI analysis failed for %s: %v", path, err)
-	}
-
-	// 保存 AI 分析结果
-	if err := repo.SaveAIResult(projectName, path, rawAiResponse); err != nil {
-		log.Printf("Failed to save AI result for %s: %v\n", path, err)
-		return fmt.Errorf("failed to save AI result for %s: %v", path, err)
-	}
-
-	// 更新总结文件
-	if err := repo.UpdateSummaryFile(projectName, path, &yamlResult); err != nil {
-		log.Printf("Failed to update summary for %s: %v\n", path, err)
-		return fmt.Errorf("failed to update summary for %s: %v", path, err)
-	}
-
-	// 上传代码信息到远程
-	err = aiClient.UploadCodeInfo(context.Background(), entity.AICodeSnippet{
-		ProjectName:     projectName,
-		FilePath:        path,
-		FileName:        filepath.Base(path),
-		FileType:        filepath.Ext(path),
-		CodeRaw:         string(fileContent),
-		Desc:            yamlResult.FileDescription,
-		Snippet:         rawAiResponse,
-		Language:        language,
-		LanguageVersion: languageVersion,
-		Tags: []string{
-			"lang:" + language,
-			"langVersion:" + languageVersion,
-			"project:" + projectName,
-		},
-	})
+		return nil, nil, newConfigError(err)
+	}
+	changed = map[string]bool{}
+	for _, change := range changes {
+		path := filepath.Join(directory, filepath.FromSlash(change.Path))
+		switch change.Status {
+		case repoinfo.ChangeDeleted:
+			deleted = append(deleted, path)
+		case repoinfo.ChangeRenamed:
+			deleted = append(deleted, filepath.Join(directory, filepath.FromSlash(change.OldPath)))
+			changed[path] = true
+		default:
+			changed[path] = true
+		}
+	}
+	slog.Info("Analyzing changed files only", "base", base, "head", head, "changed", len(changed), "deleted", len(deleted))
+	return changed, deleted, nil
+}
+
+// loadRetryFiles 指定 --retry-failed 时返回上一次运行失败的文件，否则返回 nil
+func loadRetryFiles() (map[string]bool, error) {
+	if !retryFailed {
+		return nil, nil
+	}
+	report, err := usecase.LoadFailures(filepath.Join(outputDir, usecase.FailuresFileName))
 	if err != nil {
-		log.Printf("Failed to upload code info for %s: %v\n", path, err)
-		return err
+		return nil, err
+	}
+	retry := make(map[string]bool, len(report.Failures))
+	for _, failure := range report.Failures {
+		retry[failure.Path] = true
 	}
+	slog.Info("Retrying failed files", "count", len(retry), "previous_run_id", report.RunID)
+	return retry, nil
+}
 
-	return nil
+// openCheckpoint 返回本次运行的 checkpoint：--resume 时打开上次未完成的运行，参数必须一致；
+// 否则遍历 directory 创建新的 checkpoint，丢弃之前未完成的运行
+func openCheckpoint(directory, model string, aiCode usecase.AICodeUseCase) (*usecase.Checkpoint, error) {
+	config := usecase.CheckpointConfig{
+		Dir:            directory,
+		ProjectName:    projectName,
+		Model:          model,
+		PromptVersion:  aiCode.PromptVersion(prompt.FileAnalysis),
+		OutputLanguage: outputLanguage,
+		Sinks:          sinkNames,
+		RetryFailed:    retryFailed,
+		Since:          since,
+		DiffRange:      diffRange,
+		Commit:         analyzedCommit,
+	}
+	if resume {
+		checkpoint, err := usecase.OpenCheckpoint(outputDir)
+		if err != nil {
+			return nil, err
+		}
+		if diff := checkpoint.Config.Diff(config); len(diff) > 0 {
+			checkpoint.Close()
+			return nil, fmt.Errorf("cannot resume run %s: %s changed, re-run with the same options or without --resume to start over",
+				checkpoint.RunID, strings.Join(diff, ", "))
+		}
+		slog.Info("Resuming run", "previous_run_id", checkpoint.RunID, "started", checkpoint.CreatedAt,
+			"completed", len(checkpoint.Files)-len(checkpoint.Pending()), "pending", len(checkpoint.Pending()))
+		return checkpoint, nil
+	}
+
+	if usecase.CheckpointExists(outputDir) {
+		slog.Warn("Starting over, discarding the unfinished run in the output directory; use --resume to continue it", "dir", outputDir)
+	}
+	var files []string
+	if err := code.WalkDir(directory, func(path string) { files = append(files, path) }); err != nil {
+		slog.Error("Failed to walk the source directory", "dir", directory, "error", err)
+		return nil, fmt.Errorf("failed to walk %s: %w", directory, err)
+	}
+	return usecase.CreateCheckpoint(outputDir, runID, config, files)
+}
+
+// maxListedFailures 汇总表后最多列出的失败文件数，完整的列表在 failures.json 中
+const maxListedFailures = 10
+
+// printRunSummary 输出各处理结果的文件数和失败的文件，finished 时把失败的文件写入 failures.json
+func printRunSummary(summary *usecase.RunSummary, finished bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tFILES")
+	for _, status := range usecase.FileStatuses {
+		fmt.Fprintf(w, "%s\t%d\n", status, summary.Count(status))
+	}
+	fmt.Fprintf(w, "total\t%d\n", summary.Total())
+	w.Flush()
+
+	failures := summary.Failures()
+	hint := "run analyze --resume to finish first"
+	if finished {
+		// 未完成的运行不覆盖 failures.json，--resume 时 --retry-failed 仍然重试同一批文件
+		failuresPath, err := summary.WriteFailures(outputDir, runID)
+		if err != nil {
+			slog.Error("Failed to write failures", "error", err)
+		}
+		hint = fmt.Sprintf("all %d in %s, re-run with --retry-failed", len(failures), failuresPath)
+	}
+	if len(failures) == 0 {
+		return
+	}
+	fmt.Printf("\nFailed files (%s):\n", hint)
+	for i, failure := range failures {
+		if i == maxListedFailures {
+			fmt.Printf("  ... and %d more\n", len(failures)-maxListedFailures)
+			break
+		}
+		fmt.Printf("  %s: %s\n", failure.Path, truncate(failure.Error, 120))
+	}
 }
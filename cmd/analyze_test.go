@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/prompt"
+	"codetest/internal/usecase/repo"
+)
+
+// stubLLMClient 按顺序返回预设回复，用完后返回 err
+type stubLLMClient struct {
+	responses []string
+	err       error
+}
+
+func (s *stubLLMClient) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
+	if len(s.responses) == 0 {
+		return entity.LLMResponse{}, s.err
+	}
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+	return entity.LLMResponse{Content: response, Model: "gpt-4o-mini"}, nil
+}
+
+// bufferedSink 记录 Flush 和 Close 的调用，模拟缓冲文件结果的 workflow server sink
+type bufferedSink struct {
+	buffered []string
+	flushed  []string
+	closed   bool
+}
+
+func (s *bufferedSink) Name() string { return "buffered" }
+
+func (s *bufferedSink) SaveFileResult(ctx context.Context, result entity.FileResult) error {
+	s.buffered = append(s.buffered, result.Path)
+	return nil
+}
+
+func (s *bufferedSink) Flush(ctx context.Context) error {
+	s.flushed = append(s.flushed, s.buffered...)
+	s.buffered = nil
+	return nil
+}
+
+func (s *bufferedSink) Close(ctx context.Context, summary entity.ProjectSummary) error {
+	s.closed = true
+	return s.Flush(ctx)
+}
+
+func TestSummarizeAndCloseFlushesOnError(t *testing.T) {
+	var parsed entity.ParsedYAML
+	parsed.FileDescription = "入口"
+	parsed.FileInfo.PackageName = "main"
+	results := []entity.FileResult{{Path: "svc/main.go", Parsed: parsed}}
+
+	sink := &bufferedSink{}
+	if err := sink.SaveFileResult(context.Background(), results[0]); err != nil {
+		t.Fatal(err)
+	}
+	aiCode := usecase.NewAiCode(&stubLLMClient{err: usecase.ErrBudgetExceeded}, prompt.Default())
+
+	_, err := summarizeAndClose(context.Background(), aiCode, sink, results, nil)
+	if !errors.Is(err, usecase.ErrBudgetExceeded) {
+		t.Errorf("error = %v, want ErrBudgetExceeded", err)
+	}
+	if sink.closed {
+		t.Error("sink closed without a project summary")
+	}
+	if len(sink.flushed) != 1 || len(sink.buffered) != 0 {
+		t.Errorf("buffered results were not flushed: flushed %q, still buffered %q", sink.flushed, sink.buffered)
+	}
+}
+
+func TestSummarizeAndClose(t *testing.T) {
+	var parsed entity.ParsedYAML
+	parsed.FileDescription = "入口"
+	parsed.FileInfo.PackageName = "main"
+	results := []entity.FileResult{{Path: "svc/main.go", Parsed: parsed}}
+
+	sink := &bufferedSink{}
+	aiCode := usecase.NewAiCode(&stubLLMClient{responses: []string{"入口包。", "## 项目简介\n服务", "一个服务"}}, prompt.Default())
+	summary, err := summarizeAndClose(context.Background(), aiCode, sink, results, nil)
+	if err != nil {
+		t.Fatalf("summarizeAndClose: %v", err)
+	}
+	if !sink.closed || len(summary.Packages) != 1 {
+		t.Errorf("closed = %v, summary = %+v", sink.closed, summary)
+	}
+}
+
+func TestAnalyzeSinceNeedsLocalSink(t *testing.T) {
+	_, err := runServerCmd(t, analyzeCmd, "", nil, map[string]string{"since": "HEAD", "sink": sinkSQLite})
+	if ExitCode(err) != ExitConfig || err == nil || !strings.Contains(err.Error(), "--sink local") {
+		t.Errorf("err = %v, want a config error asking for --sink local", err)
+	}
+}
+
+func TestReusablePackages(t *testing.T) {
+	outDir := t.TempDir()
+	cache := repo.NewCodeSummaryRepo(outDir, "src")
+	if reuse := reusablePackages(cache, map[string]bool{}, nil); reuse != nil {
+		t.Errorf("reuse without a saved summary = %+v, want nil", reuse)
+	}
+
+	previous := entity.ProjectSummary{Name: "svc", Packages: []entity.PackageSummary{
+		{Path: "src", Files: []string{"src/main.go"}, Summary: "入口"},
+		{Path: "src/store", Files: []string{"src/store/store.go"}, Summary: "存储"},
+		{Path: "src/api", Files: []string{"src/api/old.go"}, Summary: "接口"},
+	}}
+	data, _ := json.Marshal(previous)
+	if err := os.MkdirAll(filepath.Join(outDir, "summaries"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "summaries", "summary.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reuse := reusablePackages(cache, map[string]bool{"src/main.go": true}, []string{"src/api/old.go"})
+	if len(reuse) != 1 || reuse["src/store"].Summary != "存储" {
+		t.Errorf("reuse = %+v, want only the unchanged src/store package", reuse)
+	}
+	if reuse := reusablePackages(cache, nil, nil); reuse != nil {
+		t.Errorf("reuse of a full run = %+v, want nil", reuse)
+	}
+}
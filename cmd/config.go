@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"codetest/internal/pkg/config"
+	"codetest/internal/pkg/repoinfo"
+
+	"github.com/spf13/cobra"
+)
+
+// configCmd 查看配置
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the layered configuration",
+}
+
+// configShowCmd 输出每个配置项的最终取值及来源，保密的配置项不显示内容
+//
+//	go run entry/main.go config show -d ../task-mini-program
+//	CODEANALYSIS_OUTPUT_DIR=/tmp/result go run entry/main.go config show --format json
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective config and where each value comes from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		layers, err := configLayers(cmd)
+		if err != nil {
+			return err
+		}
+		values := config.Resolve(layers)
+		for i, v := range values {
+			if flag := cmd.Flags().Lookup(v.Key.Flag); flag != nil && flag.Changed {
+				values[i].Value, values[i].Source = flag.Value.String(), config.SourceFlag
+			}
+		}
+		if outputFormat == formatJSON {
+			type item struct {
+				Key    string `json:"key"`
+				Value  string `json:"value"`
+				Source string `json:"source"`
+				Env    string `json:"env"`
+			}
+			items := make([]item, 0, len(values))
+			for _, v := range values {
+				items = append(items, item{Key: v.Key.Name, Value: v.Display(), Source: v.Source, Env: v.Key.Env()})
+			}
+			return printJSON(os.Stdout, items)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tENV")
+		for _, v := range values {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Key.Name, v.Display(), v.Source, v.Key.Env())
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd, configValidateCmd)
+	configCmd.PersistentFlags().StringVarP(&dir, "dir", "d", ".", "Source directory to look for "+config.ProjectFileName+" in")
+	addFormatFlag(configShowCmd)
+}
+
+// configValidateCmd 检查配置文件能否读取，token、密码等保密项是否明文保存在已提交到 git 的文件中，
+// 以及源码目录中已提交或可能被提交的文件是否包含明文 API key
+//
+//	go run entry/main.go config validate -d ../task-mini-program
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config files and warn about plain-text secrets and API keys in committed files",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		layers, err := configLayers(cmd)
+		if err != nil {
+			return err
+		}
+		for _, layer := range layers[1:] {
+			fmt.Printf("Loaded %s\n", layer.Source)
+		}
+		warnings := config.Validate(layers, nil)
+		warnings = append(warnings, config.ScanKeys(dir, repoinfo.GitFiles(dir))...)
+		for _, warning := range warnings {
+			fmt.Printf("warning: %s\n", warning)
+		}
+		if len(warnings) == 0 {
+			fmt.Println("Config OK")
+		}
+		return nil
+	},
+}
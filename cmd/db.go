@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/repo"
+	"codetest/internal/usecase/web_api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var dbPath string
+
+// dbCmd 在 SQLite 数据库和输出目录中的 YAML 布局之间导入、导出分析结果，以及计算文件描述的向量
+//
+//	go run entry/main.go db import -d ../task-mini-program -o ./result
+//	go run entry/main.go db export -d ../task-mini-program -o ./result --db ./analysis.db
+//	go run entry/main.go db embed -d ../task-mini-program -o ./result -t sk-xxx
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Import or export the SQLite analysis store",
+}
+
+var dbImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import the YAML results in the output directory into the database",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openStore(dir)
+		if err != nil {
+			return err
+		}
+		defer store.CloseDB()
+		count, err := store.Import(context.Background(), repo.NewCodeSummaryRepo(outputDir, dir))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d files from %s into %s\n", count, outputDir, storePath())
+		return nil
+	},
+}
+
+var dbExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the database to YAML results in the output directory",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openStore(dir)
+		if err != nil {
+			return err
+		}
+		defer store.CloseDB()
+		count, err := store.Export(context.Background(), repo.NewCodeSummaryRepo(outputDir, dir))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d files from %s to %s\n", count, storePath(), outputDir)
+		return nil
+	},
+}
+
+// embedBatchSize db embed 每次请求计算向量的文件数
+const embedBatchSize = 100
+
+// embedStage 向量请求在用量报告和 transcript 中的阶段
+const embedStage = "embed"
+
+// newEmbeddingClient 创建计算向量的客户端。与其他 LLM 调用一样经过 newLLMClient，
+// 写入日志和 --trace-file、支持 replay，并按 --budget、--price-table 统计和限制费用
+func newEmbeddingClient(token string) (*usecase.UsageMeter, error) {
+	prices, err := usecase.LoadPriceTable(priceTableFile)
+	if err != nil {
+		return nil, err
+	}
+	client, _ := newLLMClient(token)
+	meter := usecase.NewUsageMeter(client, prices, budget, web_api.EmbeddingModel)
+	meter.RunID = runID
+	return meter, nil
+}
+
+var dbEmbedCmd = &cobra.Command{
+	Use:   "embed",
+	Short: "Compute embeddings of the file descriptions for search --semantic",
+	Long: `Compute embeddings of the file descriptions in the database that have none yet.
+Files whose description changed since are embedded again.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openStore(dir)
+		if err != nil {
+			return err
+		}
+		defer store.CloseDB()
+
+		ctx := context.Background()
+		files, err := store.FilesWithoutEmbedding(ctx, web_api.EmbeddingModel)
+		if err != nil {
+			return err
+		}
+		client, err := newEmbeddingClient(openAIToken)
+		if err != nil {
+			return err
+		}
+		ctx = usecase.WithStage(ctx, embedStage)
+		for start := 0; start < len(files); start += embedBatchSize {
+			batch := files[start:min(start+embedBatchSize, len(files))]
+			texts := make([]string, len(batch))
+			for i, file := range batch {
+				texts[i] = file.Path + "\n" + file.Description
+			}
+			response, err := client.Embed(ctx, texts)
+			if err != nil {
+				return fmt.Errorf("embedded %d of %d files, run db embed again to continue: %w", start, len(files), err)
+			}
+			for i, file := range batch {
+				if err := store.SaveEmbedding(ctx, filepath.Join(dir, filepath.FromSlash(file.Path)), web_api.EmbeddingModel, response.Vectors[i]); err != nil {
+					return err
+				}
+			}
+			slog.Info("Embedded files", "done", start+len(batch), "total", len(files))
+		}
+		usage := client.Total()
+		fmt.Printf("Embedded %d files into %s (%d tokens, cost %.4f USD)\n", len(files), storePath(), usage.PromptTokens, usage.Cost)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbImportCmd, dbExportCmd, dbEmbedCmd)
+	dbEmbedCmd.Flags().StringVarP(&openAIToken, "token", "t", "", "API token for the embedding model")
+	addSecretFileFlags(dbEmbedCmd.Flags(), "token")
+	addBudgetFlags(dbEmbedCmd)
+	dbCmd.PersistentFlags().StringVarP(&dir, "dir", "d", ".", "Source directory that was analyzed")
+	dbCmd.PersistentFlags().StringVarP(&outputDir, "output-dir", "o", "./result", "Directory of the YAML analyze results")
+	addDBFlag(dbCmd.PersistentFlags())
+}
+
+// addDBFlag 注册 --db 参数
+func addDBFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&dbPath, "db", "", "SQLite analysis store (defaults to <output-dir>/"+repo.DefaultDBFileName+")")
+}
+
+// storePath 返回 SQLite 数据库的路径
+func storePath() string {
+	if dbPath != "" {
+		return dbPath
+	}
+	return filepath.Join(outputDir, repo.DefaultDBFileName)
+}
+
+// openStore 打开 SQLite 数据库，sourceDir 为被分析的源码根目录
+func openStore(sourceDir string) (*repo.SQLiteStore, error) {
+	return repo.OpenSQLiteStore(storePath(), sourceDir)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"codetest"
+	"codetest/internal/pkg/repoinfo"
+	"codetest/internal/usecase/docsite"
+	"codetest/internal/usecase/repo"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	siteDir    string
+	siteFormat string
+)
+
+// docsCmd 把 analyze 保存在输出目录中的分析结果生成静态文档站点
+//
+//	go run entry/main.go docs -d ../task-mini-program -o ./result
+//	go run entry/main.go docs -d ../task-mini-program -o ./result --format markdown --site-dir ./docs
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate a static HTML or Markdown site from saved analysis results",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if siteDir == "" {
+			siteDir = filepath.Join(outputDir, "site")
+		}
+		return runDocs(dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+	docsCmd.Flags().StringVarP(&dir, "dir", "d", ".", "Source directory that was analyzed")
+	docsCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "./result", "Directory of the analyze results")
+	docsCmd.Flags().StringVarP(&projectName, "project-name", "p", "", "Project name shown on the site (defaults to the analyzed project)")
+	docsCmd.Flags().StringVar(&siteDir, "site-dir", "", "Directory to write the site to (defaults to <output-dir>/site)")
+	docsCmd.Flags().StringVar(&siteFormat, "format", docsite.FormatHTML, "Site format: html or markdown")
+	docsCmd.Flags().StringVar(&dbPath, "db", "", "Read the results from this SQLite analysis store instead of the output directory")
+}
+
+// runDocs 主要逻辑
+func runDocs(directory string) error {
+	if siteFormat != docsite.FormatHTML && siteFormat != docsite.FormatMarkdown {
+		return fmt.Errorf("unknown --format %q, expected %s or %s", siteFormat, docsite.FormatHTML, docsite.FormatMarkdown)
+	}
+
+	var store docsite.ResultStore = repo.NewCodeSummaryRepo(outputDir, directory)
+	if dbPath != "" {
+		sqliteStore, err := openStore(directory)
+		if err != nil {
+			return err
+		}
+		defer sqliteStore.CloseDB()
+		store = sqliteStore
+	}
+	site, err := loadSite(directory, store)
+	if err != nil {
+		return err
+	}
+	if err := site.Write(siteDir, siteFormat); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d packages and %d files to %s\n", len(site.Packages), len(site.Files), siteDir)
+	return nil
+}
+
+// loadSite 读取 directory 中的源码和 store 中的分析结果，没有指定 --project-name 时使用分析时的项目名称
+func loadSite(directory string, store docsite.ResultStore) (*docsite.Site, error) {
+	if projectName == "" {
+		summary, err := store.LoadProjectSummary()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		projectName = summary.Name
+	}
+	if projectName == "" {
+		info, err := repoinfo.Detect(directory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect repository: %v", err)
+		}
+		projectName = info.Name
+	}
+
+	var paths []string
+	if err := code.WalkDir(directory, func(path string) {
+		paths = append(paths, path)
+	}); err != nil {
+		return nil, fmt.Errorf("error walking directory: %v", err)
+	}
+	return docsite.Load(projectName, directory, paths, store)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"codetest"
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/web_api"
+)
+
+// dryRunTopFiles 试运行时列出的最大文件数量
+const dryRunTopFiles = 10
+
+// dryRunFile 试运行时单个文件的估算结果
+type dryRunFile struct {
+	path             string
+	size             int64
+	promptTokens     int
+	completionTokens int
+}
+
+// dryRunEstimate 试运行的估算结果
+type dryRunEstimate struct {
+	files            []dryRunFile
+	packages         int
+	size             int64
+	promptTokens     int // 包括分层汇总的调用
+	completionTokens int
+	model            string
+	cost             float64
+	unreadable       int
+}
+
+// runDryRun 按 analyze 相同的规则遍历目录并构建提示词，估算 token 数和费用，不调用 LLM 也不登录远程服务
+func runDryRun(directory string) error {
+	prompts, err := loadPromptSet()
+	if err != nil {
+		return err
+	}
+	prices, err := usecase.LoadPriceTable(priceTableFile)
+	if err != nil {
+		return err
+	}
+	estimate, err := estimateDryRun(directory, usecase.NewAiCode(nil, prompts), prices)
+	if err != nil {
+		return err
+	}
+	printDryRun(os.Stdout, estimate, prices)
+	return nil
+}
+
+// estimateDryRun 遍历 directory，用 aiCode 构建每个文件的提示词并估算 token 数和费用
+func estimateDryRun(directory string, aiCode usecase.AICodeUseCase, prices usecase.PriceTable) (dryRunEstimate, error) {
+	var estimate dryRunEstimate
+	err := code.WalkDir(directory, func(path string) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			estimate.unreadable++
+			return
+		}
+		prompt, err := aiCode.FileAnalysisPrompt(path, string(content))
+		if err != nil {
+			estimate.unreadable++
+			return
+		}
+		estimate.files = append(estimate.files, dryRunFile{
+			path:             path,
+			size:             int64(len(content)),
+			promptTokens:     usecase.EstimateTokens(prompt),
+			completionTokens: usecase.EstimateCompletionTokens(string(content)),
+		})
+	})
+	if err != nil {
+		return estimate, fmt.Errorf("error during directory traversal: %v", err)
+	}
+
+	packages := map[string]bool{}
+	for _, f := range estimate.files {
+		estimate.size += f.size
+		estimate.promptTokens += f.promptTokens
+		estimate.completionTokens += f.completionTokens
+		packages[filepath.Dir(f.path)] = true
+	}
+	estimate.packages = len(packages)
+	// 分层汇总（包总结、项目概览、项目描述）的调用
+	summaryPrompt, summaryCompletion := usecase.EstimateSummaryTokens(len(estimate.files), len(packages))
+	estimate.promptTokens += summaryPrompt
+	estimate.completionTokens += summaryCompletion
+	estimate.model = web_api.DefaultChatGPTModel
+	estimate.cost = prices.Cost(estimate.model, estimate.promptTokens, estimate.completionTokens)
+	return estimate, nil
+}
+
+// printDryRun 输出估算结果和 prompt token 最多的文件
+func printDryRun(out io.Writer, estimate dryRunEstimate, prices usecase.PriceTable) {
+	fmt.Fprintln(out, "Dry run: no LLM calls and no remote login were made")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Files:\t%d\n", len(estimate.files))
+	fmt.Fprintf(w, "Packages:\t%d\n", estimate.packages)
+	fmt.Fprintf(w, "Source size:\t%.1f KB\n", float64(estimate.size)/1024)
+	fmt.Fprintf(w, "Prompt tokens:\t~%d\n", estimate.promptTokens)
+	fmt.Fprintf(w, "Completion tokens:\t~%d\n", estimate.completionTokens)
+	fmt.Fprintf(w, "Model:\t%s\n", estimate.model)
+	fmt.Fprintf(w, "Estimated cost:\t~%.4f USD\n", estimate.cost)
+	if estimate.unreadable > 0 {
+		fmt.Fprintf(w, "Unreadable files:\t%d\n", estimate.unreadable)
+	}
+	w.Flush()
+	if _, ok := prices.Lookup(estimate.model); !ok {
+		fmt.Fprintf(out, "Warning: no price for model %s, pass --price-table to estimate the cost\n", estimate.model)
+	}
+	if budget > 0 && estimate.cost > budget {
+		fmt.Fprintf(out, "Warning: the estimated cost exceeds --budget %.4f USD, analyze would stop early\n", budget)
+	}
+
+	files := append([]dryRunFile(nil), estimate.files...)
+	sort.Slice(files, func(i, j int) bool { return files[i].promptTokens > files[j].promptTokens })
+	if len(files) > dryRunTopFiles {
+		files = files[:dryRunTopFiles]
+	}
+	if len(files) > 0 {
+		fmt.Fprintln(out, "\nLargest files:")
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tSIZE\tPROMPT TOKENS")
+		for _, f := range files {
+			fmt.Fprintf(w, "%s\t%.1f KB\t~%d\n", f.path, float64(f.size)/1024, f.promptTokens)
+		}
+		w.Flush()
+	}
+}
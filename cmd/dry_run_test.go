@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/prompt"
+)
+
+// writeDryRunFixture 两个包中的三个 Go 文件，以及 analyze 不处理的测试、vendor 和非 Go 文件
+func writeDryRunFixture(t *testing.T) (string, map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	analyzed := map[string]string{
+		"store/store.go": "package store\n\ntype Store struct{ data map[string]string }\n",
+		"store/get.go":   "package store\n\nfunc (s *Store) Get(key string) string { return s.data[key] }\n",
+		"main.go":        "package main\n\nfunc main() {}\n",
+	}
+	skipped := map[string]string{
+		"store/store_test.go": "package store\n",
+		"vendor/x/x.go":       "package x\n",
+		"README.md":           "# demo\n",
+	}
+	for _, files := range []map[string]string{analyzed, skipped} {
+		for name, content := range files {
+			path := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root, analyzed
+}
+
+func TestEstimateDryRun(t *testing.T) {
+	root, analyzed := writeDryRunFixture(t)
+	aiCode := usecase.NewAiCode(nil, prompt.Default())
+	prices := usecase.PriceTable{"gpt-4o-mini": {Prompt: 1, Completion: 2}}
+
+	estimate, err := estimateDryRun(root, aiCode, prices)
+	if err != nil {
+		t.Fatalf("estimateDryRun: %v", err)
+	}
+	if len(estimate.files) != 3 || estimate.packages != 2 || estimate.unreadable != 0 {
+		t.Fatalf("estimate = %+v", estimate)
+	}
+
+	var size int64
+	promptTokens, completionTokens := usecase.EstimateSummaryTokens(3, 2)
+	for name, content := range analyzed {
+		size += int64(len(content))
+		p, err := aiCode.FileAnalysisPrompt(filepath.Join(root, name), content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		promptTokens += usecase.EstimateTokens(p)
+		completionTokens += 200 // 小文件按最少 200 个回复 token 估算
+	}
+	if estimate.size != size || estimate.promptTokens != promptTokens || estimate.completionTokens != completionTokens {
+		t.Errorf("size, prompt, completion = %d, %d, %d; want %d, %d, %d",
+			estimate.size, estimate.promptTokens, estimate.completionTokens, size, promptTokens, completionTokens)
+	}
+	if want := float64(promptTokens+2*completionTokens) / 1e6; math.Abs(estimate.cost-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", estimate.cost, want)
+	}
+
+	var out bytes.Buffer
+	printDryRun(&out, estimate, prices)
+	for _, want := range []string{"Files:              3\n", "Packages:           2\n", "Model:              gpt-4o-mini\n", "Largest files:"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Warning") {
+		t.Errorf("unexpected warning:\n%s", out.String())
+	}
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/prompt"
+	"codetest/internal/usecase/web_api"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	evalCasesFile string
+	evalReplay    string
+	evalRecord    string
+	evalMinScore  float64
+)
+
+// evalCmd 使用已标注的文件集评估提示词的效果
+//
+//	go run entry/main.go eval --cases eval/cases.yaml --replay eval/golden.yaml
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Score the file analysis prompt against a labeled set of files",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if evalCasesFile == "" {
+			return fmt.Errorf("--cases is required")
+		}
+		return runEval()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+	evalCmd.Flags().StringVar(&evalCasesFile, "cases", "", "YAML file with the labeled files (required)")
+	evalCmd.Flags().StringVarP(&openAIToken, "token", "t", "", "API token for AI analysis")
+	addSecretFileFlags(evalCmd.Flags(), "token")
+	evalCmd.Flags().StringVar(&evalReplay, "replay", "", "Replay LLM responses from a golden file instead of calling the API")
+	evalCmd.Flags().StringVar(&evalRecord, "record", "", "Record the LLM responses of this run to a golden file")
+	evalCmd.Flags().Float64Var(&evalMinScore, "min-score", 0, "Fail when the average score is below this value (0-1)")
+	addPromptFlags(evalCmd)
+}
+
+// runEval 主要逻辑
+func runEval() error {
+	cases, err := usecase.LoadEvalCases(evalCasesFile)
+	if err != nil {
+		return fmt.Errorf("failed to load eval cases: %v", err)
+	}
+	parser := web_api.NewParser()
+	for i := range cases {
+		if len(cases[i].Symbols) > 0 {
+			continue
+		}
+		if cases[i].Symbols, err = exportedSymbols(parser, cases[i].File); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", cases[i].File, err)
+		}
+	}
+
+	prompts, err := loadPromptSet()
+	if err != nil {
+		return err
+	}
+
+	var llmClient usecase.LLMClient
+	if evalReplay != "" {
+		if llmClient, err = usecase.NewReplayClient(evalReplay); err != nil {
+			return err
+		}
+	} else {
+		llmClient = web_api.NewChatGPTClient(openAIToken)
+	}
+	llmClient = wrapLLMClient(llmClient, web_api.DefaultChatGPTModel)
+	var recorder *usecase.RecordingClient
+	if evalRecord != "" {
+		recorder = usecase.NewRecordingClient(llmClient)
+		llmClient = recorder
+	}
+
+	aiCode := usecase.NewAiCode(llmClient, prompts)
+	results := usecase.EvaluateAnalysis(context.Background(), aiCode, aiCode.PromptVersion(prompt.FileAnalysis), cases)
+	if recorder != nil {
+		if err := recorder.Save(evalRecord); err != nil {
+			return err
+		}
+	}
+
+	average := printEvalResults(results)
+	if average < evalMinScore {
+		return fmt.Errorf("average score %.3f is below --min-score %.3f", average, evalMinScore)
+	}
+	return nil
+}
+
+// printEvalResults 输出评估结果表格并返回平均分
+func printEvalResults(results []usecase.EvalResult) float64 {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tPACKAGE\tIMPORTS\tSYMBOLS\tSCORE\tMISSING")
+	var total float64
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t0.000\terror: %v\n", r.File, r.Err)
+			continue
+		}
+		missing := append(append([]string{}, r.MissingImports...), r.MissingSymbols...)
+		fmt.Fprintf(w, "%s\t%t\t%.2f\t%.2f\t%.3f\t%s\n", r.File, r.PackageOK, r.ImportRecall, r.SymbolRecall, r.Score, strings.Join(missing, ","))
+		total += r.Score
+	}
+	w.Flush()
+
+	average := 0.0
+	if len(results) > 0 {
+		average = total / float64(len(results))
+		fmt.Printf("\nprompt: %s\naverage score: %.3f\n", results[0].PromptVersion, average)
+	}
+	return average
+}
+
+// exportedSymbols 使用语法树解析出文件中所有导出的符号名称
+func exportedSymbols(parser *web_api.Parser, file string) ([]string, error) {
+	result, err := parser.ParseByFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	set := map[string]bool{}
+	add := func(name string) {
+		if ast.IsExported(name) {
+			set[name] = true
+		}
+	}
+	for name, info := range result.Structs {
+		add(name)
+		for _, method := range info.Methods {
+			add(symbolName(method))
+		}
+	}
+	for name := range result.Interfaces {
+		add(name)
+	}
+	for _, constant := range result.Constants {
+		add(symbolName(constant))
+	}
+	for _, fn := range result.ExportedFunc {
+		add(symbolName(fn))
+	}
+	for _, v := range result.ExportedVar {
+		add(symbolName(v))
+	}
+
+	symbols := make([]string, 0, len(set))
+	for name := range set {
+		symbols = append(symbols, name)
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+// symbolName 从 "Name(params) (results)" 或 "Name = value" 中取出名称
+func symbolName(decl string) string {
+	fields := strings.FieldsFunc(decl, func(r rune) bool { return r == '(' || r == '=' || r == ' ' })
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
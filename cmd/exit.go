@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+
+	"codetest/internal/entity"
+	"codetest/internal/usecase"
+)
+
+// 命令行工具的退出码
+const (
+	ExitOK          = 0
+	ExitError       = 1   // 其他错误
+	ExitConfig      = 2   // 参数、配置文件或保密参数错误
+	ExitAuth        = 3   // LLM 或 workflow server 拒绝了认证信息
+	ExitPartial     = 4   // 部分文件失败（达到 --fail-on-error），或因超出预算没有处理完
+	ExitTotal       = 5   // 所有文件都失败
+	ExitInterrupted = 130 // 被 Ctrl-C 中断
+)
+
+// errInterrupted analyze 被中断，已处理的文件保存在 checkpoint 中
+var errInterrupted = errors.New("interrupted")
+
+// configError 参数或配置错误
+type configError struct {
+	err error
+}
+
+// newConfigError 把 err 标记为配置错误，err 为 nil 时返回 nil
+func newConfigError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &configError{err: err}
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+// ExitCode 返回 Execute 的错误对应的退出码
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var cfgErr *configError
+	var fileErrs *usecase.FileErrors
+	switch {
+	case errors.As(err, &cfgErr):
+		return ExitConfig
+	case errors.Is(err, entity.ErrUnauthorized):
+		return ExitAuth
+	case errors.Is(err, errInterrupted):
+		return ExitInterrupted
+	case errors.As(err, &fileErrs):
+		if fileErrs.AllFailed() {
+			return ExitTotal
+		}
+		return ExitPartial
+	case errors.Is(err, usecase.ErrBudgetExceeded):
+		return ExitPartial
+	}
+	return ExitError
+}
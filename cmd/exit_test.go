@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"codetest/internal/entity"
+	"codetest/internal/usecase"
+)
+
+func TestExitCode(t *testing.T) {
+	summary := func(statuses map[string]string) error {
+		s := usecase.NewRunSummary()
+		for path, status := range statuses {
+			var err error
+			if status == usecase.FileFailed {
+				err = fmt.Errorf("failed to analyze %s", path)
+			}
+			s.Add(path, status, err)
+		}
+		return s.Err()
+	}
+	unauthorized := usecase.NewRunSummary()
+	unauthorized.Add("a.go", usecase.FileSucceeded, nil)
+	unauthorized.Add("b.go", usecase.FileFailed, fmt.Errorf("request failed: %w", entity.ErrUnauthorized))
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"other", errors.New("boom"), ExitError},
+		{"config", newConfigError(errors.New("unknown flag")), ExitConfig},
+		{"wrapped config", fmt.Errorf("loading: %w", newConfigError(errors.New("bad yaml"))), ExitConfig},
+		{"unauthorized", fmt.Errorf("login: %w", entity.ErrUnauthorized), ExitAuth},
+		{"unauthorized file", unauthorized.Err(), ExitAuth},
+		{"interrupted", fmt.Errorf("%w with 3 files pending", errInterrupted), ExitInterrupted},
+		{"partial", summary(map[string]string{"a.go": usecase.FileSucceeded, "b.go": usecase.FileFailed}), ExitPartial},
+		{"failed and skipped", summary(map[string]string{"a.go": usecase.FileSkipped, "b.go": usecase.FileFailed}), ExitPartial},
+		{"total", summary(map[string]string{"a.go": usecase.FileFailed, "b.go": usecase.FileFailed}), ExitTotal},
+		{"budget", fmt.Errorf("stopped with 2 files pending: %w", usecase.ErrBudgetExceeded), ExitPartial},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("%s: ExitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
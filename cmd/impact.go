@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"codetest"
+	"codetest/internal/pkg/repoinfo"
+	"codetest/internal/pkg/unidiff"
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/docsite"
+	"codetest/internal/usecase/impact"
+	"codetest/internal/usecase/repo"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	impactFormat string
+	impactOut    string
+	maxDepth     int
+	explain      bool
+)
+
+// impactCmd 分析函数变化的影响范围：根据源码的调用关系找出受影响的包、导出的 API 和测试，
+// 附上保存的包总结，--explain 时让 LLM 说明风险。
+//
+//	go run entry/main.go impact store.Store.Get api.Handle -d ../task-mini-program -o ./result
+//	go run entry/main.go impact --since main -d ../task-mini-program --format dot --out impact.dot
+//	go run entry/main.go impact --diff main..feature -d ../task-mini-program --explain -t sk-xxx
+var impactCmd = &cobra.Command{
+	Use:   "impact [function...]",
+	Short: "List the packages, exported APIs and tests affected by changed functions",
+	Long: `List the packages, exported APIs and tests that transitively call the changed functions.
+
+Functions are named as <import path>.<func>, <dir>.<func>, <package>.<func> or just <func>;
+methods as <type>.<method>, e.g. store.Store.Get. Instead of naming them, the changed functions
+can be taken from --since, --diff or --patch. The call graph is built from the Go syntax of
+--dir without type information, so calls through interfaces or values are matched by method name.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sources := 0
+		for _, set := range []bool{len(args) > 0, since != "", diffRange != "", patchFile != ""} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return newConfigError(fmt.Errorf("pass either function names, --since, --diff or --patch"))
+		}
+		if impactFormat != impact.FormatText && impactFormat != impact.FormatJSON && impactFormat != impact.FormatDOT {
+			return newConfigError(fmt.Errorf("unknown --format %q, expected %s, %s or %s", impactFormat, impact.FormatText, impact.FormatJSON, impact.FormatDOT))
+		}
+		if maxDepth < 0 {
+			return newConfigError(fmt.Errorf("--max-depth must not be negative"))
+		}
+		return runImpact(dir, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(impactCmd)
+	impactCmd.Flags().StringVarP(&dir, "dir", "d", ".", "Source directory to build the call graph from")
+	impactCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "./result", "Directory of the analyze results")
+	impactCmd.Flags().StringVar(&dbPath, "db", "", "Read the results from this SQLite analysis store instead of the output directory")
+	impactCmd.Flags().StringVarP(&projectName, "project-name", "p", "", "Project name (defaults to the analyzed project)")
+	impactCmd.Flags().StringVar(&since, "since", "", "Take the changed functions from the changes since this git ref, including uncommitted ones")
+	impactCmd.Flags().StringVar(&diffRange, "diff", "", "Take the changed functions from the changes between two commits (base..head)")
+	impactCmd.Flags().StringVar(&patchFile, "patch", "", "Take the changed functions from this unified diff (- for stdin)")
+	impactCmd.Flags().StringVar(&impactFormat, "format", impact.FormatText, "Output format: text, json or dot")
+	impactCmd.Flags().StringVar(&impactOut, "out", "", "Write the report to this file instead of stdout")
+	impactCmd.Flags().IntVar(&maxDepth, "max-depth", 0, "Follow callers up to this many levels, 0 for no limit")
+	impactCmd.Flags().BoolVar(&explain, "explain", false, "Ask the LLM to explain the risk of the change")
+	impactCmd.Flags().StringVarP(&openAIToken, "token", "t", "", "API token for --explain")
+	addSecretFileFlags(impactCmd.Flags(), "token")
+	addPromptFlags(impactCmd)
+}
+
+// runImpact 主要逻辑
+func runImpact(directory string, names []string) error {
+	var paths []string
+	if err := code.WalkDirWithTests(directory, func(path string) {
+		paths = append(paths, path)
+	}); err != nil {
+		return fmt.Errorf("error walking directory: %v", err)
+	}
+	graph, err := impact.LoadGraph(directory, paths)
+	if err != nil {
+		return err
+	}
+
+	changed, err := changedFunctions(graph, directory, names)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		slog.Warn("No changed functions found, the report is empty")
+	}
+
+	var store docsite.ResultStore = repo.NewCodeSummaryRepo(outputDir, directory)
+	if dbPath != "" {
+		sqliteStore, err := openStore(directory)
+		if err != nil {
+			return err
+		}
+		defer sqliteStore.CloseDB()
+		store = sqliteStore
+	}
+	site, err := loadSite(directory, store)
+	if err != nil {
+		return err
+	}
+
+	report := impact.Analyze(graph, site.Project, changed, maxDepth)
+	report.Annotate(site)
+	slog.Info("Impact analyzed", "changed", len(report.Changed), "functions", len(report.Functions),
+		"packages", len(report.Packages), "apis", len(report.APIs), "tests", len(report.Tests))
+
+	if explain && len(changed) > 0 {
+		prompts, err := loadPromptSet()
+		if err != nil {
+			return err
+		}
+		llmClient, _ := newLLMClient(openAIToken)
+		aiCode := usecase.NewAiCode(llmClient, prompts)
+		if report.Narrative, err = aiCode.ExplainImpact(context.Background(), site.Project, report.Text()); err != nil {
+			return err
+		}
+	}
+
+	data, err := report.Render(impactFormat)
+	if err != nil {
+		return err
+	}
+	if impactOut == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(impactOut, data, 0644); err != nil {
+		return fmt.Errorf("failed to write impact report: %v", err)
+	}
+	fmt.Printf("Wrote the impact of %d functions to %s\n", len(report.Changed), impactOut)
+	return nil
+}
+
+// changedFunctions 返回命令行上指定的函数，或者 --since、--diff、--patch 的补丁修改过的函数
+func changedFunctions(graph *impact.Graph, directory string, names []string) ([]*impact.Func, error) {
+	if len(names) == 0 {
+		base, head := since, ""
+		if diffRange != "" {
+			var err error
+			if base, head, err = repoinfo.ParseDiffRange(diffRange); err != nil {
+				return nil, newConfigError(err)
+			}
+		}
+		patch, err := readPatch(directory, base, head)
+		if err != nil {
+			return nil, err
+		}
+		files, err := unidiff.Parse(patch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse patch: %v", err)
+		}
+		return impact.ChangedFuncs(graph, files), nil
+	}
+
+	var changed []*impact.Func
+	for _, name := range names {
+		funcs, err := graph.Resolve(name)
+		if err != nil {
+			return nil, newConfigError(err)
+		}
+		if len(funcs) > 1 {
+			var ids []string
+			for _, f := range funcs {
+				ids = append(ids, f.ID)
+			}
+			slog.Info("Function name matches several functions, using all of them", "name", name, "functions", ids)
+		}
+		changed = append(changed, funcs...)
+	}
+	return changed, nil
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	workflow_server "codetest/internal/usecase/workflow-server"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	projectFilter      string
+	projectGitURL      string
+	projectDesc        string
+	projectTags        []string
+	projectRemoveTags  []string
+	projectLanguage    string
+	projectLangVersion string
+	projectDeleteYes   bool
+)
+
+// projectsCmd 管理 workflow server 上的项目
+//
+//	go run entry/main.go projects list -a http://localhost:8080 --api-key xxx
+//	go run entry/main.go projects show task-mini-program --format json
+//	go run entry/main.go projects delete task-mini-program --yes
+var projectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "Manage projects on the workflow server",
+}
+
+var projectsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List projects",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := connectServer(cmd)
+		if err != nil {
+			return err
+		}
+		projects, err := apiClient.ListProjects(context.Background(), projectFilter)
+		if err != nil {
+			return err
+		}
+		if outputFormat == formatJSON {
+			return printJSON(cmd.OutOrStdout(), projects)
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tLANGUAGE\tVERSION\tTAGS\tGIT URL\tUPDATED")
+		for _, p := range projects {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", p.ID, p.Name, p.Language, p.LanguageVersion,
+				strings.Join(p.Tags, ","), p.GitUrl, p.UpdatedAt.Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	},
+}
+
+var projectsShowCmd = &cobra.Command{
+	Use:   "show <id|name>",
+	Short: "Show a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := connectServer(cmd)
+		if err != nil {
+			return err
+		}
+		project, err := findProject(context.Background(), apiClient, args[0])
+		if err != nil {
+			return err
+		}
+		return printProject(cmd.OutOrStdout(), project)
+	},
+}
+
+var projectsCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := connectServer(cmd)
+		if err != nil {
+			return err
+		}
+		project, err := apiClient.CreateProject(context.Background(), &workflow_server.Project{
+			Name:            args[0],
+			GitUrl:          projectGitURL,
+			Desc:            projectDesc,
+			Language:        projectLanguage,
+			LanguageVersion: projectLangVersion,
+			Tags:            workflow_server.MergeTags(nil, projectTags),
+		})
+		if err != nil {
+			return err
+		}
+		return printProject(cmd.OutOrStdout(), project)
+	},
+}
+
+var projectsUpdateCmd = &cobra.Command{
+	Use:   "update <id|name>",
+	Short: "Update the given fields of a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := connectServer(cmd)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		project, err := findProject(ctx, apiClient, args[0])
+		if err != nil {
+			return err
+		}
+
+		flags := cmd.Flags()
+		if flags.Changed("git-url") {
+			project.GitUrl = projectGitURL
+		}
+		if flags.Changed("desc") {
+			project.Desc = projectDesc
+		}
+		if flags.Changed("language") {
+			project.Language = projectLanguage
+		}
+		if flags.Changed("language-version") {
+			project.LanguageVersion = projectLangVersion
+		}
+		project.Tags = workflow_server.MergeTags(project.Tags, projectTags)
+		if err := apiClient.UpdateProject(ctx, project); err != nil {
+			return err
+		}
+		if len(projectRemoveTags) > 0 {
+			if project, err = apiClient.RemoveProjectTags(ctx, project.ID, projectRemoveTags...); err != nil {
+				return err
+			}
+		}
+		return printProject(cmd.OutOrStdout(), project)
+	},
+}
+
+var projectsDeleteCmd = &cobra.Command{
+	Use:   "delete <id|name>",
+	Short: "Delete a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := connectServer(cmd)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		project, err := findProject(ctx, apiClient, args[0])
+		if err != nil {
+			return err
+		}
+		if !projectDeleteYes {
+			ok, err := confirm(cmd, fmt.Sprintf("Delete project %s (id %d)?", project.Name, project.ID))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("deletion of project %s not confirmed, pass --yes to delete without prompting", project.Name)
+			}
+		}
+		if err := apiClient.DeleteProject(ctx, project.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted project %s (id %d)\n", project.Name, project.ID)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(projectsCmd)
+	projectsCmd.AddCommand(projectsListCmd, projectsShowCmd, projectsCreateCmd, projectsUpdateCmd, projectsDeleteCmd)
+	addServerFlags(projectsCmd, true)
+	addFormatFlag(projectsCmd)
+
+	projectsListCmd.Flags().StringVar(&projectFilter, "name", "", "Only list projects whose name matches")
+	for _, c := range []*cobra.Command{projectsCreateCmd, projectsUpdateCmd} {
+		c.Flags().StringVar(&projectGitURL, "git-url", "", "Git repository URL")
+		c.Flags().StringVar(&projectDesc, "desc", "", "Project description")
+		c.Flags().StringVar(&projectLanguage, "language", "", "Programming language")
+		c.Flags().StringVar(&projectLangVersion, "language-version", "", "Programming language version")
+		c.Flags().StringSliceVar(&projectTags, "tag", nil, "Tag to add (repeatable)")
+	}
+	projectsUpdateCmd.Flags().StringSliceVar(&projectRemoveTags, "remove-tag", nil, "Tag to remove (repeatable)")
+	projectsDeleteCmd.Flags().BoolVarP(&projectDeleteYes, "yes", "y", false, "Delete without asking for confirmation")
+}
+
+// findProject 按 ID 或名称查找项目，纯数字的参数视为 ID
+func findProject(ctx context.Context, apiClient *workflow_server.ApiClient, idOrName string) (*workflow_server.Project, error) {
+	if id, err := strconv.ParseUint(idOrName, 10, 64); err == nil {
+		return apiClient.GetProjectByID(ctx, uint(id))
+	}
+	return apiClient.FindProjectByName(ctx, idOrName)
+}
+
+// printProject 按 --format 输出单个项目
+func printProject(out io.Writer, p *workflow_server.Project) error {
+	if outputFormat == formatJSON {
+		return printJSON(out, p)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", p.ID)
+	fmt.Fprintf(w, "Name:\t%s\n", p.Name)
+	fmt.Fprintf(w, "Language:\t%s %s\n", p.Language, p.LanguageVersion)
+	fmt.Fprintf(w, "Git URL:\t%s\n", p.GitUrl)
+	fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(p.Tags, ", "))
+	fmt.Fprintf(w, "Status:\t%s\n", p.Status)
+	fmt.Fprintf(w, "Created:\t%s\n", p.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Updated:\t%s\n", p.UpdatedAt.Format("2006-01-02 15:04:05"))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if p.Desc != "" {
+		fmt.Fprintf(out, "\n%s\n", p.Desc)
+	}
+	return nil
+}
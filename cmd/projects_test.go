@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"codetest/internal/entity"
+	workflow_server "codetest/internal/usecase/workflow-server"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// fakeWorkflowServer 模拟 workflow server 的项目和代码片段接口，只接受 API key "api-key"
+type fakeWorkflowServer struct {
+	mutex    sync.Mutex
+	projects map[uint]workflow_server.Project
+	codes    map[int]entity.AICodeSnippet
+	nextID   uint
+}
+
+func newFakeWorkflowServer(t *testing.T) *fakeWorkflowServer {
+	fs := &fakeWorkflowServer{
+		projects: map[uint]workflow_server.Project{1: {ID: 1, Name: "demo", Language: "go", Tags: []string{"lang:go", "old"}}},
+		codes: map[int]entity.AICodeSnippet{
+			1: {ID: 1, ProjectName: "demo", FilePath: "main.go", Desc: "入口"},
+			2: {ID: 2, ProjectName: "demo", FilePath: "store/store.go", Desc: "存储"},
+			3: {ID: 3, ProjectName: "other", FilePath: "main.go"},
+		},
+		nextID: 1,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/projects", func(w http.ResponseWriter, r *http.Request) {
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		if r.Method == http.MethodPost {
+			var project workflow_server.Project
+			if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fs.nextID++
+			project.ID = fs.nextID
+			fs.projects[project.ID] = project
+			writeTestJSON(w, project)
+			return
+		}
+		list := []workflow_server.Project{}
+		for _, project := range fs.projects {
+			if strings.Contains(project.Name, r.URL.Query().Get("name")) {
+				list = append(list, project)
+			}
+		}
+		writeTestJSON(w, list)
+	})
+	mux.HandleFunc("/api/v1/projects/", func(w http.ResponseWriter, r *http.Request) {
+		var id uint
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/api/v1/projects/"), "%d", &id)
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		if _, ok := fs.projects[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			var project workflow_server.Project
+			if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fs.projects[id] = project
+			writeTestJSON(w, nil)
+		case http.MethodDelete:
+			delete(fs.projects, id)
+			writeTestJSON(w, nil)
+		default:
+			writeTestJSON(w, fs.projects[id])
+		}
+	})
+	mux.HandleFunc("/api/v1/codes", func(w http.ResponseWriter, r *http.Request) {
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		list := []entity.AICodeSnippet{}
+		for _, snippet := range fs.codes {
+			if snippet.ProjectName == r.URL.Query().Get("project_name") {
+				list = append(list, snippet)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		writeTestJSON(w, list)
+	})
+	mux.HandleFunc("/api/v1/codes/", func(w http.ResponseWriter, r *http.Request) {
+		var id int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/api/v1/codes/"), "%d", &id)
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		if _, ok := fs.codes[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(fs.codes, id)
+			writeTestJSON(w, nil)
+			return
+		}
+		writeTestJSON(w, fs.codes[id])
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer api-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	oldBasePath, oldAPIKey := apiBasePath, apiKey
+	apiBasePath, apiKey = server.URL, "api-key"
+	t.Cleanup(func() { apiBasePath, apiKey = oldBasePath, oldAPIKey })
+	return fs
+}
+
+func writeTestJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": data})
+}
+
+// runServerCmd 设置参数后直接执行子命令的 RunE，返回标准输出；执行后恢复参数，避免影响之后的调用
+func runServerCmd(t *testing.T, c *cobra.Command, stdin string, args []string, flags map[string]string) (string, error) {
+	t.Helper()
+	defer func() {
+		c.Flags().VisitAll(func(f *pflag.Flag) {
+			if slice, ok := f.Value.(pflag.SliceValue); ok {
+				_ = slice.Replace(nil)
+			} else {
+				_ = f.Value.Set(f.DefValue)
+			}
+			f.Changed = false
+		})
+		outputFormat = formatTable
+	}()
+	for name, value := range flags {
+		if name == "format" {
+			outputFormat = value
+			continue
+		}
+		for _, v := range strings.Split(value, ",") {
+			if err := c.Flags().Set(name, v); err != nil {
+				t.Fatalf("set --%s: %v", name, err)
+			}
+		}
+	}
+	var out, errOut bytes.Buffer
+	c.SetOut(&out)
+	c.SetErr(&errOut)
+	c.SetIn(strings.NewReader(stdin))
+	defer func() {
+		c.SetOut(nil)
+		c.SetErr(nil)
+		c.SetIn(nil)
+	}()
+	err := c.RunE(c, args)
+	return out.String(), err
+}
+
+func TestProjectsListCmd(t *testing.T) {
+	newFakeWorkflowServer(t)
+	out, err := runServerCmd(t, projectsListCmd, "", nil, map[string]string{"format": formatJSON})
+	if err != nil {
+		t.Fatalf("projects list: %v", err)
+	}
+	var projects []workflow_server.Project
+	if err := json.Unmarshal([]byte(out), &projects); err != nil || len(projects) != 1 || projects[0].Name != "demo" {
+		t.Errorf("projects = %+v, err %v, output:\n%s", projects, err, out)
+	}
+
+	out, err = runServerCmd(t, projectsListCmd, "", nil, nil)
+	if err != nil || !strings.Contains(out, "lang:go,old") {
+		t.Errorf("table output = %q, err %v", out, err)
+	}
+}
+
+func TestProjectsCreateCmd(t *testing.T) {
+	fs := newFakeWorkflowServer(t)
+	out, err := runServerCmd(t, projectsCreateCmd, "", []string{"api"}, map[string]string{"language": "go", "tag": "team:infra,team:infra"})
+	if err != nil {
+		t.Fatalf("projects create: %v", err)
+	}
+	created := fs.projects[2]
+	if created.Name != "api" || created.Language != "go" || strings.Join(created.Tags, ",") != "team:infra" {
+		t.Errorf("created project = %+v", created)
+	}
+	if !strings.Contains(out, "Name:") || !strings.Contains(out, "api") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestProjectsUpdateCmd(t *testing.T) {
+	fs := newFakeWorkflowServer(t)
+	out, err := runServerCmd(t, projectsUpdateCmd, "", []string{"demo"},
+		map[string]string{"desc": "任务小程序", "tag": "team:infra", "remove-tag": "old"})
+	if err != nil {
+		t.Fatalf("projects update: %v", err)
+	}
+	project := fs.projects[1]
+	if project.Desc != "任务小程序" || project.Language != "go" || strings.Join(project.Tags, ",") != "lang:go,team:infra" {
+		t.Errorf("updated project = %+v", project)
+	}
+	if !strings.Contains(out, "lang:go, team:infra") {
+		t.Errorf("output does not show the remaining tags:\n%s", out)
+	}
+}
+
+func TestProjectsDeleteCmd(t *testing.T) {
+	fs := newFakeWorkflowServer(t)
+	for _, answer := range []string{"", "n\n", "no\n"} {
+		if _, err := runServerCmd(t, projectsDeleteCmd, answer, []string{"demo"}, nil); err == nil || !strings.Contains(err.Error(), "--yes") {
+			t.Errorf("answer %q: err = %v, want a not confirmed error", answer, err)
+		}
+		if _, ok := fs.projects[1]; !ok {
+			t.Fatalf("answer %q deleted the project", answer)
+		}
+	}
+
+	out, err := runServerCmd(t, projectsDeleteCmd, "y\n", []string{"1"}, nil)
+	if err != nil || !strings.Contains(out, "Deleted project demo (id 1)") {
+		t.Fatalf("confirmed delete: output %q, err %v", out, err)
+	}
+	if _, ok := fs.projects[1]; ok {
+		t.Error("project still exists after a confirmed delete")
+	}
+
+	fs.projects[2] = workflow_server.Project{ID: 2, Name: "api"}
+	if _, err := runServerCmd(t, projectsDeleteCmd, "", []string{"api"}, map[string]string{"yes": "true"}); err != nil {
+		t.Fatalf("delete --yes: %v", err)
+	}
+	if _, ok := fs.projects[2]; ok {
+		t.Error("project still exists after delete --yes")
+	}
+}
+
+func TestSnippetsCmds(t *testing.T) {
+	fs := newFakeWorkflowServer(t)
+	if _, err := runServerCmd(t, snippetsListCmd, "", nil, nil); err == nil {
+		t.Error("snippets list without --project succeeded")
+	}
+	out, err := runServerCmd(t, snippetsListCmd, "", nil, map[string]string{"project": "demo", "format": formatJSON})
+	if err != nil {
+		t.Fatalf("snippets list: %v", err)
+	}
+	var snippets []entity.AICodeSnippet
+	if err := json.Unmarshal([]byte(out), &snippets); err != nil || len(snippets) != 2 {
+		t.Errorf("snippets = %+v, err %v", snippets, err)
+	}
+
+	out, err = runServerCmd(t, snippetsShowCmd, "", []string{"2"}, nil)
+	if err != nil || !strings.Contains(out, "store/store.go") {
+		t.Errorf("snippets show: output %q, err %v", out, err)
+	}
+
+	if _, err := runServerCmd(t, snippetsDeleteCmd, "", []string{"1", "x"}, nil); err == nil || len(fs.codes) != 3 {
+		t.Errorf("delete with an invalid id: err %v, %d snippets left", err, len(fs.codes))
+	}
+	out, err = runServerCmd(t, snippetsDeleteCmd, "", []string{"1", "3"}, nil)
+	if err != nil || len(fs.codes) != 1 || !strings.Contains(out, "Deleted snippet 3") {
+		t.Errorf("snippets delete: output %q, err %v, %d snippets left", out, err, len(fs.codes))
+	}
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"codetest/internal/usecase/prompt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	promptsDir     string
+	outputLanguage string
+	glossaryFile   string
+)
+
+// addPromptFlags 为命令添加提示词模板相关的参数
+func addPromptFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&promptsDir, "prompts-dir", "", "Directory with prompt templates overriding the embedded ones (<dir>/<lang>/<name>.tmpl)")
+	cmd.Flags().StringVar(&outputLanguage, "output-language", prompt.LanguageZh, "Output language of the AI results: zh|en")
+	cmd.Flags().StringVar(&glossaryFile, "glossary", "", "YAML file mapping project specific terms to their meaning")
+}
+
+// loadPromptSet 根据参数加载提示词模板
+func loadPromptSet() (*prompt.Set, error) {
+	glossary, err := prompt.LoadGlossary(glossaryFile)
+	if err != nil {
+		return nil, err
+	}
+	return prompt.NewSet(promptsDir, outputLanguage, glossary)
+}
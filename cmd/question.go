@@ -2,17 +2,19 @@ package cmd
 
 import (
 	"codetest/internal/usecase"
-	"codetest/internal/usecase/web_api"
+	"codetest/internal/usecase/repo"
+	"context"
 	"fmt"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
 )
 
 var summaryFilePath string
 
 // questionNodeCmd 定义了 file 节点的命令
 //
-//	go run entry/main.go question 请帮我分析一下这个项目主要是干什么的 -t sk-bZf8kBSewGAYqJ85CgDZzJtGyBO1AcBdA6OKdy0ntNkUtob6 -s /home/gw123/go/src/github.com/mytoolzone/task-mini-program/result/all.md
+//	go run entry/main.go question 请帮我分析一下这个项目主要是干什么的 -t sk-xxx -s /home/gw123/go/src/github.com/mytoolzone/task-mini-program/result/summary.md
 var questionNodeCmd = &cobra.Command{
 	Use:   "question [question]",
 	Short: "Ask a question and get an AI-generated answer about the file node usage",
@@ -31,28 +33,63 @@ var questionNodeCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(questionNodeCmd) // 将子命令添加到根命令
 	questionNodeCmd.Flags().StringVarP(&openAIToken, "token", "t", "", "API token for AI analysis (required)")
-	questionNodeCmd.Flags().StringVarP(&summaryFilePath, "summary-dir", "s", "./result/all.md", "总结文件输出地方")
+	addSecretFileFlags(questionNodeCmd.Flags(), "token")
+	questionNodeCmd.Flags().StringVarP(&summaryFilePath, "summary-dir", "s", "./result/summary.md", "analyze 生成的文件总结（输出目录中的 summary.md）")
+	questionNodeCmd.Flags().StringVar(&dbPath, "db", "", "Read the project summary from this SQLite analysis store instead of --summary-dir")
+	questionNodeCmd.Flags().StringVarP(&dir, "dir", "d", ".", "Source directory that was analyzed, used with --db")
+	addPromptFlags(questionNodeCmd)
 }
 
 // runFileNode 主要逻辑
 func runFileNode(token, question string) error {
-	llmClient := web_api.NewChatGPTClient(token)
-	//codeSummaryRepo := repo.NewCodeSummaryRepo(outputDir)
-	aiCode := usecase.NewAiCode(llmClient, nil)
+	prompts, err := loadPromptSet()
+	if err != nil {
+		return err
+	}
+
+	llmClient, _ := newLLMClient(token)
+	aiCode := usecase.NewAiCode(llmClient, prompts)
 
-	summary, err := os.ReadFile(summaryFilePath)
+	summary, err := loadQuestionSummary()
 	if err != nil {
-		fmt.Println("os.ReadFile(path) Error:", err)
 		return err
 	}
 	// 调用 AI 客户端以获取答案
-	answer, err := aiCode.AIQuestion(string(summary), question, "")
+	answer, err := aiCode.AIQuestion(context.Background(), summary, question, "")
 	if err != nil {
 		return fmt.Errorf("error: %v", err)
 	}
 
 	// 打印生成的答案
 	fmt.Println("AI 回复结果：")
-	fmt.Println(answer)
+	fmt.Println(strings.Join(answer, "\n"))
 	return nil
 }
+
+// loadQuestionSummary 返回回答问题使用的项目总结：指定 --db 时由数据库中的项目概览和文件总结组成，否则读取 --summary-dir
+func loadQuestionSummary() (string, error) {
+	if dbPath == "" {
+		summary, err := os.ReadFile(summaryFilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read summary: %v", err)
+		}
+		return string(summary), nil
+	}
+
+	store, err := openStore(dir)
+	if err != nil {
+		return "", err
+	}
+	defer store.CloseDB()
+	report, err := store.SummaryReport(context.Background())
+	if err != nil {
+		return "", err
+	}
+	var summary strings.Builder
+	if project, err := store.LoadProjectSummary(); err == nil && project.Overview != "" {
+		summary.WriteString(project.Overview)
+		summary.WriteString("\n\n")
+	}
+	summary.WriteString(repo.MarkdownSummary(report))
+	return summary.String(), nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/web_api"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayClient *usecase.ReplayClient // replay 时代替 ChatGPT 客户端
+	replayModel  string
+)
+
+// replayCmd 用 --trace-file 记录的 transcript 重新执行调用 LLM 的命令（analyze、question、review、impact，
+// 以及计算向量的 search --semantic 和 db embed），不访问网络，
+// 用于复现用户报告的问题。prompt 与记录不一致（例如源码或提示模板有变化）时对应的调用会失败。
+//
+//	go run entry/main.go analyze -d ../task-mini-program -o ./result --trace-file ./result/transcript.jsonl
+//	go run entry/main.go replay ./result/transcript.jsonl analyze -d ../task-mini-program -o /tmp/replay
+//	go run entry/main.go replay ./transcript.jsonl question 这个项目是做什么的 -s ./result/summary.md
+var replayCmd = &cobra.Command{
+	Use:   "replay <transcript> (analyze|question|review|impact|search|db) [flags]",
+	Short: "Re-run analyze, question, review, impact, search or db embed with the LLM responses from a transcript, without network access",
+	// 之后的参数交给被回放的命令解析
+	DisableFlagParsing: true,
+	SilenceErrors:      true,
+	SilenceUsage:       true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+			return cmd.Help()
+		}
+		if len(args) < 2 {
+			return fmt.Errorf("usage: %s", cmd.UseLine())
+		}
+		if target := args[1]; !slices.Contains(replayableCommands(), target) {
+			return fmt.Errorf("cannot replay %q, supported commands: %s", target, strings.Join(replayableCommands(), ", "))
+		}
+
+		transcript, err := usecase.LoadTranscript(args[0])
+		if err != nil {
+			return err
+		}
+		if len(transcript.Entries) == 0 {
+			return fmt.Errorf("transcript %s is empty", args[0])
+		}
+		replayClient = usecase.NewTranscriptReplayClient(transcript.Entries)
+		// 使用原来的运行配置的模型，缓存和检查点中的元数据才与原来的运行一致
+		replayModel = transcript.Model()
+		if replayModel == "" {
+			slog.Warn("Transcript has no header with the configured model, replaying with the default model", "model", web_api.DefaultChatGPTModel)
+		}
+		defer func() { replayClient, replayModel = nil, "" }()
+
+		rootCmd.SetArgs(args[1:])
+		defer rootCmd.SetArgs(nil)
+		return rootCmd.Execute()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+}
+
+// replayableCommands 可以回放的命令
+func replayableCommands() []string {
+	return []string{analyzeCmd.Name(), questionNodeCmd.Name(), reviewCmd.Name(), impactCmd.Name(), searchCmd.Name(), dbCmd.Name()}
+}
+
+// newLLMClient 创建 LLM 客户端并返回使用的模型名称，replay 时使用 transcript 的回放客户端
+func newLLMClient(token string) (usecase.LLMClient, string) {
+	if replayClient != nil {
+		model := replayModel
+		if model == "" {
+			model = web_api.DefaultChatGPTModel
+		}
+		return wrapLLMClient(replayClient, model), model
+	}
+	chatClient := web_api.NewChatGPTClient(token)
+	return wrapLLMClient(chatClient, chatClient.Model()), chatClient.Model()
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"codetest/internal/entity"
+	"codetest/internal/pkg/repoinfo"
+	"codetest/internal/pkg/unidiff"
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/docsite"
+	"codetest/internal/usecase/repo"
+	"codetest/internal/usecase/review"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	patchFile    string
+	reviewFormat string
+	reviewOut    string
+	maxCallers   int
+)
+
+// reviewCmd 让 LLM 评审一个补丁：补丁来自 --patch 或本地仓库中两个 git 引用之间的差异，
+// 评审时附上 analyze 保存的被修改文件和调用方的分析结果，不需要代码托管平台。
+//
+//	go run entry/main.go review main..feature -d ../task-mini-program -o ./result
+//	go run entry/main.go review main -d ../task-mini-program --format sarif --out review.sarif
+//	git diff --relative main | go run entry/main.go review --patch - -d ../task-mini-program
+var reviewCmd = &cobra.Command{
+	Use:   "review [base..head | base]",
+	Short: "Review a patch or the changes between git refs with the LLM and the saved analysis results",
+	Long: `Review a patch and report bugs, breaking API changes and missing tests with file:line anchors.
+
+The patch is either read from --patch (a unified diff, - for stdin, paths relative to --dir) or
+computed from the local git repository: base..head compares two refs, a single base compares
+the ref with the working tree. The saved analysis results of the changed files and of the files
+importing their packages are sent along with the patch.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 1) == (patchFile != "") {
+			return newConfigError(fmt.Errorf("pass either a git range or --patch"))
+		}
+		if reviewFormat != review.FormatMarkdown && reviewFormat != review.FormatSARIF {
+			return newConfigError(fmt.Errorf("unknown --format %q, expected %s or %s", reviewFormat, review.FormatMarkdown, review.FormatSARIF))
+		}
+		if maxCallers < 0 {
+			return newConfigError(fmt.Errorf("--max-callers must not be negative"))
+		}
+		return runReview(dir, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+	reviewCmd.Flags().StringVarP(&dir, "dir", "d", ".", "Source directory, inside the git repository when reviewing a range")
+	reviewCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "./result", "Directory of the analyze results")
+	reviewCmd.Flags().StringVar(&dbPath, "db", "", "Read the results from this SQLite analysis store instead of the output directory")
+	reviewCmd.Flags().StringVarP(&projectName, "project-name", "p", "", "Project name (defaults to the analyzed project)")
+	reviewCmd.Flags().StringVarP(&openAIToken, "token", "t", "", "API token for AI analysis (required)")
+	addSecretFileFlags(reviewCmd.Flags(), "token")
+	reviewCmd.Flags().StringVar(&patchFile, "patch", "", "Review this unified diff instead of a git range (- for stdin)")
+	reviewCmd.Flags().StringVar(&reviewFormat, "format", review.FormatMarkdown, "Output format: markdown or sarif")
+	reviewCmd.Flags().StringVar(&reviewOut, "out", "", "Write the review to this file instead of stdout")
+	reviewCmd.Flags().IntVar(&maxCallers, "max-callers", 20, "Maximum number of caller files sent as context, 0 for no limit")
+	addPromptFlags(reviewCmd)
+}
+
+// runReview 主要逻辑
+func runReview(directory string, args []string) error {
+	var base, head string
+	if len(args) == 1 {
+		base = args[0]
+		if strings.Contains(base, "..") {
+			var err error
+			if base, head, err = repoinfo.ParseDiffRange(base); err != nil {
+				return newConfigError(err)
+			}
+		}
+	}
+	patch, err := readPatch(directory, base, head)
+	if err != nil {
+		return err
+	}
+	files, err := unidiff.Parse(patch)
+	if err != nil {
+		return fmt.Errorf("failed to parse patch: %v", err)
+	}
+
+	var store docsite.ResultStore = repo.NewCodeSummaryRepo(outputDir, directory)
+	if dbPath != "" {
+		sqliteStore, err := openStore(directory)
+		if err != nil {
+			return err
+		}
+		defer sqliteStore.CloseDB()
+		store = sqliteStore
+	}
+	site, err := loadSite(directory, store)
+	if err != nil {
+		return err
+	}
+
+	var result entity.Review
+	if len(files) == 0 {
+		// 没有变化时仍然输出空的评审结果，方便在 CI 中统一处理
+		slog.Info("The patch has no changes, nothing to review")
+	} else {
+		prompts, err := loadPromptSet()
+		if err != nil {
+			return err
+		}
+		llmClient, _ := newLLMClient(openAIToken)
+		aiCode := usecase.NewAiCode(llmClient, prompts)
+
+		reviewCtx := review.BuildContext(site, files, maxCallers)
+		if len(reviewCtx.Changed) == 0 {
+			slog.Warn("None of the patched files were found in the source directory, paths in the patch must be relative to --dir", "dir", directory)
+		}
+		slog.Info("Reviewing patch", "files", len(files), "changed", len(reviewCtx.Changed), "callers", len(reviewCtx.Callers))
+
+		result, err = aiCode.ReviewPatch(context.Background(), site.Project, review.FormatPatch(files), reviewCtx.String())
+		if err != nil {
+			return err
+		}
+		for _, finding := range review.Anchor(&result, files, reviewCtx.Paths()) {
+			slog.Warn("Dropped a finding in a file that is neither patched nor a caller", "file", finding.File, "title", finding.Title)
+		}
+	}
+
+	data, err := review.Render(result, reviewFormat, site.Project)
+	if err != nil {
+		return err
+	}
+	if reviewOut == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(reviewOut, data, 0644); err != nil {
+		return fmt.Errorf("failed to write review: %v", err)
+	}
+	fmt.Printf("Wrote %d findings to %s\n", len(result.Findings), reviewOut)
+	return nil
+}
+
+// readPatch 读取 --patch 指定的补丁，或者在 directory 所在的仓库中生成 base 到 head 的补丁，head 为空时与工作区比较
+func readPatch(directory, base, head string) (string, error) {
+	if patchFile == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read patch from stdin: %v", err)
+		}
+		return string(data), nil
+	}
+	if patchFile != "" {
+		data, err := os.ReadFile(patchFile)
+		if err != nil {
+			return "", newConfigError(fmt.Errorf("failed to read patch: %v", err))
+		}
+		return string(data), nil
+	}
+	return repoinfo.UnifiedDiff(directory, base, head)
+}
@@ -1,16 +1,113 @@
 package cmd
 
 import (
+	"io"
+	"log"
+	"os"
+
+	"codetest/internal/pkg/config"
+	"codetest/internal/pkg/logger"
+	"codetest/internal/pkg/secret"
+	"codetest/internal/usecase"
+
 	"github.com/spf13/cobra"
 )
 
+var (
+	configFile string // --config 指定的项目配置文件
+	logLevel   string
+	logFormat  string
+	logFile    string
+	traceFile  string
+
+	runID      string    // 本次运行的关联 ID，写入每条日志、transcript 和运行报告
+	transcript io.Writer // --trace-file，按 JSON Lines 记录每次 LLM 调用的完整 prompt 和 response
+)
+
 // rootCmd 定义了主命令
 var rootCmd = &cobra.Command{
-	Use:   "code-analyzer",
-	Short: "Analyze and summarize code using AI",
+	Use:               "code-analyzer",
+	Short:             "Analyze and summarize code using AI",
+	PersistentPreRunE: applyConfig,
+}
+
+func init() {
+	// 子命令的 PersistentPreRunE（例如 --format 检查）与 applyConfig 都需要执行
+	cobra.EnableTraverseRunHooks = true
+	// 日志和命令的错误信息中隐藏 token、密码等保密内容
+	log.SetOutput(secret.NewWriter(os.Stderr))
+	rootCmd.SetErr(secret.NewWriter(os.Stderr))
+	// 错误由 main 输出并转换为退出码
+	rootCmd.SilenceErrors = true
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return newConfigError(err)
+	})
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "",
+		"Project config file (defaults to "+config.ProjectFileName+" in the source or current directory)")
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	flags.StringVar(&logFormat, "log-format", logger.FormatText, "Log format: text or json")
+	flags.StringVar(&logFile, "log-file", "", "Append logs to this file instead of stderr")
+	flags.StringVar(&traceFile, "trace-file", "", "Append a transcript of every LLM call (prompt, response, tokens) to this JSON lines file")
 }
 
 // Execute 启动命令行工具
 func Execute() error {
 	return rootCmd.Execute()
 }
+
+// applyConfig 用环境变量和配置文件填充命令行中没有设置的参数，读取保密参数并配置日志
+func applyConfig(cmd *cobra.Command, args []string) error {
+	// 参数解析成功后的错误不再输出用法
+	cmd.SilenceUsage = true
+	layers, err := configLayers(cmd)
+	if err != nil {
+		return newConfigError(err)
+	}
+	if _, err := config.Apply(cmd.Flags(), layers); err != nil {
+		return newConfigError(err)
+	}
+	if err := resolveSecrets(cmd); err != nil {
+		return newConfigError(err)
+	}
+	return newConfigError(setupLogging())
+}
+
+// setupLogging 按 --log-level、--log-format、--log-file 配置默认 logger，并打开 --trace-file 指定的 transcript
+func setupLogging() error {
+	runID = logger.NewRunID()
+	if err := setLogOutput(os.Stderr); err != nil {
+		return err
+	}
+	if traceFile == "" {
+		return nil
+	}
+	file, err := logger.OpenFile(traceFile)
+	if err != nil {
+		return err
+	}
+	transcript = secret.NewWriter(file)
+	return nil
+}
+
+// setLogOutput 没有指定 --log-file 时把日志写到 w，例如显示进度条期间的标准错误
+func setLogOutput(w io.Writer) error {
+	return logger.Setup(w, logger.Options{Level: logLevel, Format: logFormat, File: logFile}, runID)
+}
+
+// wrapLLMClient 在日志中记录 client 的每次调用，设置了 --trace-file 时同时写入 transcript，model 为 client 配置的模型
+func wrapLLMClient(client usecase.LLMClient, model string) usecase.LLMClient {
+	if transcript != nil {
+		client = usecase.NewTranscriptRecorder(client, transcript, runID, model)
+	}
+	return usecase.NewLoggingClient(client)
+}
+
+// configLayers 返回当前命令的配置层，项目配置文件在 --dir 指定的源码目录中查找
+func configLayers(cmd *cobra.Command) ([]config.Layer, error) {
+	sourceDir := ""
+	if flag := cmd.Flags().Lookup("dir"); flag != nil && flag.Changed {
+		sourceDir = flag.Value.String()
+	}
+	return config.Layers(os.Environ(), configFile, sourceDir, config.UserFile())
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"codetest/internal/usecase"
+	"codetest/internal/usecase/repo"
+	"codetest/internal/usecase/web_api"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchLimit int
+	semantic    bool
+)
+
+// searchCmd 在 SQLite 数据库中搜索文件和符号，--semantic 时按 db embed 计算的向量查找描述相近的文件
+//
+//	go run entry/main.go search Login -o ./result
+//	go run entry/main.go search 上传 --db ./result/analysis.db --format json
+//	go run entry/main.go search "用户登录后如何保存会话" --semantic -o ./result -t sk-xxx
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search files and symbols in the SQLite analysis store",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openStore(dir)
+		if err != nil {
+			return err
+		}
+		defer store.CloseDB()
+
+		ctx := context.Background()
+		var hits []repo.SearchHit
+		if semantic {
+			client, err := newEmbeddingClient(openAIToken)
+			if err != nil {
+				return err
+			}
+			response, err := client.Embed(usecase.WithStage(ctx, embedStage), args)
+			if err != nil {
+				return err
+			}
+			hits, err = store.SemanticSearch(ctx, web_api.EmbeddingModel, response.Vectors[0], searchLimit)
+			if err != nil {
+				return err
+			}
+		} else if hits, err = store.Search(ctx, args[0], searchLimit); err != nil {
+			return err
+		}
+		if outputFormat == formatJSON {
+			return printJSON(os.Stdout, hits)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if semantic {
+			fmt.Fprintln(w, "SCORE\tFILE\tDESCRIPTION")
+			for _, hit := range hits {
+				fmt.Fprintf(w, "%.3f\t%s\t%s\n", hit.Score, hit.Path, truncate(hit.Text, 60))
+			}
+			return w.Flush()
+		}
+		fmt.Fprintln(w, "KIND\tNAME\tFILE\tDESCRIPTION")
+		for _, hit := range hits {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", hit.Kind, truncate(hit.Name, 60), hit.Path, truncate(hit.Text, 60))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringVarP(&dir, "dir", "d", ".", "Source directory that was analyzed")
+	searchCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "./result", "Directory of the analyze results")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 20, "Maximum number of results")
+	searchCmd.Flags().BoolVar(&semantic, "semantic", false, "Rank files by embedding similarity to the query (run db embed first)")
+	searchCmd.Flags().StringVarP(&openAIToken, "token", "t", "", "API token for the embedding model, used with --semantic")
+	addSecretFileFlags(searchCmd.Flags(), "token")
+	addBudgetFlags(searchCmd)
+	addDBFlag(searchCmd.Flags())
+	addFormatFlag(searchCmd)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+
+	"codetest/internal/pkg/config"
+	"codetest/internal/pkg/secret"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// secretFileSuffix --token 等保密参数对应的 --token-file 参数的后缀
+const secretFileSuffix = "-file"
+
+// addSecretFileFlags 为保密参数注册 --<name>-file，从文件读取，- 表示标准输入
+func addSecretFileFlags(flags *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		flags.String(name+secretFileSuffix, "", "Read --"+name+" from a file instead of the command line (- for stdin)")
+	}
+}
+
+// resolveSecrets 读取保密参数：--<name>-file 优先于环境变量和配置文件，但不覆盖命令行上的 --<name>；
+// 值为 env:、file:、pass:、netrc: 引用时替换为引用的内容。
+// workflow server 的用户名、密码和 API key 都没有设置时从 .netrc 中查找 --api-base-path 的主机。
+// 读取到的保密内容都会登记到 secret 包，在日志和错误信息中隐藏。
+func resolveSecrets(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	for _, key := range config.Keys {
+		flag := flags.Lookup(key.Flag)
+		if !key.Secret || flag == nil {
+			continue
+		}
+		value := flag.Value.String()
+		if file := flags.Lookup(key.Flag + secretFileSuffix); file != nil && file.Value.String() != "" {
+			if flag.Changed {
+				return fmt.Errorf("--%s and --%s%s cannot be used together", key.Flag, key.Flag, secretFileSuffix)
+			}
+			value = secret.PrefixFile + file.Value.String()
+		}
+		resolved, err := secret.Resolve(value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %v", key.Name, err)
+		}
+		if err := flag.Value.Set(resolved); err != nil {
+			return err
+		}
+		secret.Register(resolved)
+	}
+
+	user, pass, base := flags.Lookup("username"), flags.Lookup("password"), flags.Lookup("api-base-path")
+	if user == nil || pass == nil || base == nil || user.Value.String() != "" || pass.Value.String() != "" {
+		return nil
+	}
+	if key := flags.Lookup("api-key"); key != nil && key.Value.String() != "" {
+		return nil
+	}
+	u, err := url.Parse(base.Value.String())
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	// .netrc 不存在或没有对应主机时保持为空，由命令报告缺少认证信息
+	login, password, err := secret.LookupNetrc(secret.NetrcFile(), u.Hostname())
+	if err != nil || login == "" || password == "" {
+		return nil
+	}
+	secret.Register(password)
+	if err := user.Value.Set(login); err != nil {
+		return err
+	}
+	return pass.Value.Set(password)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	workflow_server "codetest/internal/usecase/workflow-server"
+
+	"github.com/spf13/cobra"
+)
+
+// 支持的输出格式
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+var outputFormat string
+
+// addServerFlags 注册连接 workflow server 需要的参数，persistent 为 true 时子命令也可以使用
+func addServerFlags(cmd *cobra.Command, persistent bool) {
+	flags := cmd.Flags()
+	if persistent {
+		flags = cmd.PersistentFlags()
+	}
+	flags.StringVarP(&username, "username", "u", "", "Username for authentication")
+	flags.StringVarP(&password, "password", "w", "", "Password for authentication")
+	flags.StringVar(&apiKey, "api-key", "", "API key for authentication instead of username and password")
+	addSecretFileFlags(flags, "password", "api-key")
+	flags.StringVarP(&apiBasePath, "api-base-path", "a", "", "Base API URL for the server (required)")
+}
+
+// addFormatFlag 注册 --format 参数，并在执行子命令前检查格式
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&outputFormat, "format", formatTable, "Output format: table or json")
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if outputFormat != formatTable && outputFormat != formatJSON {
+			return fmt.Errorf("unknown format %q, supported formats: %s, %s", outputFormat, formatTable, formatJSON)
+		}
+		return nil
+	}
+}
+
+// connectServer 创建已认证的 workflow server 客户端
+func connectServer(cmd *cobra.Command) (*workflow_server.ApiClient, error) {
+	if apiBasePath == "" || (apiKey == "" && (username == "" || password == "")) {
+		return nil, fmt.Errorf("apiBasePath and either apiKey or username and password are required")
+	}
+	return newApiClient(context.Background())
+}
+
+// printJSON 以缩进的 JSON 输出到 out
+func printJSON(out io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %v", err)
+	}
+	fmt.Fprintln(out, string(data))
+	return nil
+}
+
+// confirm 在标准输入上询问是否继续，只有回答 y 或 yes 时返回 true，没有输入时视为否定
+func confirm(cmd *cobra.Command, question string) (bool, error) {
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s [y/N] ", question)
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read confirmation: %v", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// parseID 解析命令行中的数字 ID
+func parseID(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid id %q", arg)
+	}
+	return id, nil
+}
+
+// truncate 截断过长的文本，用于表格输出
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
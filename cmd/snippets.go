@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var snippetsProject string
+
+// snippetsCmd 查看和删除 analyze 上传到 workflow server 的代码片段
+//
+//	go run entry/main.go snippets list --project task-mini-program
+//	go run entry/main.go snippets show 42 --format json
+var snippetsCmd = &cobra.Command{
+	Use:   "snippets",
+	Short: "Inspect the code snippets uploaded to the workflow server",
+}
+
+var snippetsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the snippets of a project",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if snippetsProject == "" {
+			return fmt.Errorf("--project is required")
+		}
+		apiClient, err := connectServer(cmd)
+		if err != nil {
+			return err
+		}
+		snippets, err := apiClient.ListCodes(context.Background(), snippetsProject)
+		if err != nil {
+			return err
+		}
+		if outputFormat == formatJSON {
+			return printJSON(cmd.OutOrStdout(), snippets)
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tFILE\tLANGUAGE\tHASH\tDESCRIPTION")
+		for _, s := range snippets {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", s.ID, s.FilePath, s.Language,
+				truncate(s.ContentHash, 12), truncate(strings.Join(strings.Fields(s.Desc), " "), 60))
+		}
+		return w.Flush()
+	},
+}
+
+var snippetsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a snippet with its analysis",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID(args[0])
+		if err != nil {
+			return err
+		}
+		apiClient, err := connectServer(cmd)
+		if err != nil {
+			return err
+		}
+		s, err := apiClient.GetCode(context.Background(), id)
+		if err != nil {
+			return err
+		}
+		if outputFormat == formatJSON {
+			return printJSON(cmd.OutOrStdout(), s)
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "ID:\t%d\n", s.ID)
+		fmt.Fprintf(w, "Project:\t%s\n", s.ProjectName)
+		fmt.Fprintf(w, "File:\t%s\n", s.FilePath)
+		fmt.Fprintf(w, "Language:\t%s %s\n", s.Language, s.LanguageVersion)
+		fmt.Fprintf(w, "Content hash:\t%s\n", s.ContentHash)
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(s.Tags, ", "))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "\n%s\n\n%s\n", strings.TrimSpace(s.Desc), s.Snippet)
+		return nil
+	},
+}
+
+var snippetsDeleteCmd = &cobra.Command{
+	Use:   "delete <id>...",
+	Short: "Delete snippets",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids := make([]int, 0, len(args))
+		for _, arg := range args {
+			id, err := parseID(arg)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		apiClient, err := connectServer(cmd)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := apiClient.DeleteCode(context.Background(), id); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted snippet %d\n", id)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snippetsCmd)
+	snippetsCmd.AddCommand(snippetsListCmd, snippetsShowCmd, snippetsDeleteCmd)
+	addServerFlags(snippetsCmd, true)
+	addFormatFlag(snippetsCmd)
+	snippetsListCmd.Flags().StringVar(&snippetsProject, "project", "", "Project name (required)")
+}
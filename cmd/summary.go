@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"codetest/internal/usecase/repo"
+
+	"github.com/spf13/cobra"
+)
+
+// summaryCmd 不调用 LLM，用输出目录中保存的分析结果重新生成文件总结
+//
+//	go run entry/main.go summary -d ../task-mini-program -o ./result --format markdown,json,yaml
+var summaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Regenerate the file summary from saved analysis results",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := repo.ValidateSummaryFormats(summaryFormats); err != nil {
+			return err
+		}
+		return runSummary(dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(summaryCmd)
+	summaryCmd.Flags().StringVarP(&dir, "dir", "d", ".", "Source directory that was analyzed")
+	summaryCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "./result", "Directory of the analyze results")
+	summaryCmd.Flags().StringVarP(&projectName, "project-name", "p", "", "Project name used as the title (defaults to the analyzed project)")
+	summaryCmd.Flags().StringSliceVar(&summaryFormats, "format", []string{repo.SummaryFormatMarkdown}, "Summary formats: markdown, json, yaml (repeatable)")
+}
+
+// runSummary 主要逻辑
+func runSummary(directory string) error {
+	store := repo.NewCodeSummaryRepo(outputDir, directory)
+	store.SummaryFormats = summaryFormats
+
+	// 分层总结中的包总结会合并到文件总结中，没有分层总结时只包含文件
+	summary, err := store.LoadProjectSummary()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if projectName != "" {
+		summary.Name = projectName
+	}
+	if err := store.RebuildSummary(summary); err != nil {
+		return err
+	}
+	fmt.Printf("Regenerated summary in %s\n", outputDir)
+	return nil
+}
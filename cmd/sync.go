@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	workflow_server "codetest/internal/usecase/workflow-server"
+
+	"github.com/spf13/cobra"
+)
+
+var outboxFile string
+
+// syncCmd 重新上传 analyze 时上传失败、保存在 outbox 中的代码片段
+//
+//	go run entry/main.go sync -o ./result -a http://localhost:8080 --api-key xxx
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Upload the snippets left in the outbox by a previous analyze run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if outboxFile == "" {
+			outboxFile = filepath.Join(outputDir, outboxFileName)
+		}
+		return runSync(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "./result", "Directory of the analyze results that holds the outbox")
+	syncCmd.Flags().StringVar(&outboxFile, "outbox", "", "Outbox file (defaults to <output-dir>/"+outboxFileName+")")
+	addServerFlags(syncCmd, false)
+}
+
+// runSync 主要逻辑
+func runSync(cmd *cobra.Command) error {
+	outbox := workflow_server.NewOutbox(outboxFile)
+	snippets, err := outbox.Load()
+	if err != nil {
+		return err
+	}
+	if len(snippets) == 0 {
+		fmt.Println("Outbox is empty, nothing to sync")
+		return nil
+	}
+
+	apiClient, err := connectServer(cmd)
+	if err != nil {
+		return err
+	}
+	sent, err := outbox.Replay(context.Background(), apiClient, workflow_server.UploadBatchSize)
+	fmt.Printf("Uploaded %d of %d snippets\n", sent, len(snippets))
+	if err != nil {
+		return fmt.Errorf("%d snippets remain in %s: %v", len(snippets)-sent, outboxFile, err)
+	}
+	return nil
+}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"codetest/cmd"
+	"codetest/internal/pkg/secret"
 	"fmt"
+	"os"
 )
 
 func main() {
 	err := cmd.Execute()
 	if err != nil {
-		fmt.Println("cmd 执行失败", err)
-		return
+		fmt.Fprintln(os.Stderr, "cmd 执行失败", secret.Redact(err.Error()))
+		os.Exit(cmd.ExitCode(err))
 	}
 }
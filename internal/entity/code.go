@@ -1,5 +1,10 @@
 package entity
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
 // AICodeSnippet represents a code snippet record in the database
 type AICodeSnippet struct {
 	ID              int      `gorm:"primaryKey;autoIncrement" json:"id"`                    // 唯一标识符
@@ -13,4 +18,28 @@ type AICodeSnippet struct {
 	Snippet         string   `gorm:"type:text;not null" json:"snippet"`                     // 代码片段内容
 	Desc            string   `gorm:"type:varchar(4096);default:NULL" json:"desc"`           // 代码片段解释
 	CodeRaw         string   `gorm:"type:text;default:NULL" json:"code_raw"`                // 原始代码文件 	// 版本号 	// 软删除时间 (可选)
+	ContentHash     string   `gorm:"type:varchar(64);default:NULL" json:"content_hash"`     // 原始代码的 sha256，用于跳过未变化的文件
+}
+
+// ResultMeta 与分析结果一起保存的元数据
+type ResultMeta struct {
+	Model          string `yaml:"model" json:"model"`
+	PromptVersion  string `yaml:"prompt_version" json:"prompt_version"`
+	OutputLanguage string `yaml:"output_language" json:"output_language"`
+}
+
+// FileResult 单个文件的分析结果，交给 Sink 保存
+type FileResult struct {
+	ProjectName string     // 所属项目
+	Path        string     // 代码文件路径
+	Code        string     // 原始代码
+	Raw         string     // LLM 返回的 YAML
+	Parsed      ParsedYAML // 解析后的结果
+	Meta        ResultMeta // 提示词版本等元数据
+}
+
+// ContentHash 计算代码内容的 sha256，用于判断文件是否变化
+func ContentHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,6 @@
+package entity
+
+import "errors"
+
+// ErrUnauthorized LLM 或 workflow server 拒绝了提供的 token、API key 或用户名密码
+var ErrUnauthorized = errors.New("unauthorized")
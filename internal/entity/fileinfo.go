@@ -1,5 +1,10 @@
 package entity
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Step1FileInfo struct {
 	File        string
 	Why         string
@@ -38,3 +43,37 @@ type ParsedYAML struct {
 	//Structs             []Struct   `yaml:"structs"`
 	//Methods             []Method   `yaml:"methods"`
 }
+
+type Interface struct {
+	Name    string   `yaml:"name"`
+	Methods []Method `yaml:"methods"`
+}
+
+type APIEndpoint struct {
+	Name          string   `yaml:"name"`
+	RequestParams []string `yaml:"request_params"`
+	Response      []string `yaml:"response"`
+	RequestMethod string   `yaml:"request_method"`
+}
+
+// FileAnalysis 与文件分析提示词输出格式一致的完整结构
+type FileAnalysis struct {
+	ParsedYAML   `yaml:",inline"`
+	Constants    []Constant    `yaml:"constants"`
+	Structs      []Struct      `yaml:"structs"`
+	Interfaces   []Interface   `yaml:"interfaces"`
+	Methods      []Method      `yaml:"methods"`
+	APIEndpoints []APIEndpoint `yaml:"api_endpoints"`
+}
+
+// SummaryEntry 返回文件在项目总结中的条目
+func SummaryEntry(path string, parsed *ParsedYAML) string {
+	var strBuilder strings.Builder
+	strBuilder.WriteString(fmt.Sprintf("文件名: %s\n", path))
+	strBuilder.WriteString(fmt.Sprintf("功能: %s\n", parsed.FileDescription))
+	strBuilder.WriteString(fmt.Sprintf("包名: %s\n", parsed.FileInfo.PackageName))
+	strBuilder.WriteString("依赖导入项目: ")
+	strBuilder.WriteString(strings.Join(parsed.FileInfo.Imports, ","))
+	strBuilder.WriteString("\n---\n")
+	return strBuilder.String()
+}
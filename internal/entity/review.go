@@ -0,0 +1,33 @@
+package entity
+
+// 代码评审发现的问题类别
+const (
+	ReviewBug         = "bug"
+	ReviewAPIBreak    = "api_break"
+	ReviewMissingTest = "missing_test"
+	ReviewOther       = "other"
+)
+
+// 代码评审问题的严重程度
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// ReviewFinding 代码评审发现的一个问题，File 为相对源码目录的路径，Line 为修改后文件的行号，未知时为 0
+type ReviewFinding struct {
+	Category   string `yaml:"category" json:"category"`
+	Severity   string `yaml:"severity" json:"severity"`
+	File       string `yaml:"file" json:"file"`
+	Line       int    `yaml:"line" json:"line,omitempty"`
+	Title      string `yaml:"title" json:"title"`
+	Detail     string `yaml:"detail" json:"detail,omitempty"`
+	Suggestion string `yaml:"suggestion" json:"suggestion,omitempty"`
+}
+
+// Review 对一个补丁的评审结果，与评审提示词的输出格式一致
+type Review struct {
+	Summary  string          `yaml:"summary" json:"summary"`
+	Findings []ReviewFinding `yaml:"findings" json:"findings"`
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// FileFailure 分析失败的文件
+type FileFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// FailureReport 一次 analyze 中分析失败的文件，保存在输出目录的 failures.json 中，供 --retry-failed 使用
+type FailureReport struct {
+	RunID     string        `json:"run_id,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Failures  []FileFailure `json:"failures"`
+}
@@ -0,0 +1,37 @@
+package entity
+
+// PackageSummary 一个包（目录）的总结
+type PackageSummary struct {
+	Path    string   `json:"path" yaml:"path"`
+	Files   []string `json:"files" yaml:"files"`
+	Summary string   `json:"summary" yaml:"summary"`
+}
+
+// ProjectSummary 由文件总结逐层汇总得到的项目总结
+type ProjectSummary struct {
+	Name        string           `json:"name" yaml:"name"`
+	Overview    string           `json:"overview" yaml:"overview"`       // 完整的项目概览：架构、入口、主要流程
+	Description string           `json:"description" yaml:"description"` // 简洁的项目描述，用于 Project.Desc
+	Packages    []PackageSummary `json:"packages" yaml:"packages"`
+}
+
+// FileSummary 文件总结中的一个文件
+type FileSummary struct {
+	Path        string   `json:"path" yaml:"path"` // 相对源码根目录的路径
+	Description string   `json:"description" yaml:"description"`
+	PackageName string   `json:"package_name" yaml:"package_name"`
+	Imports     []string `json:"imports" yaml:"imports"`
+}
+
+// PackageFiles 文件总结中的一个包（目录）
+type PackageFiles struct {
+	Path    string        `json:"path" yaml:"path"`                           // 相对源码根目录的路径，根目录为 "."
+	Summary string        `json:"summary,omitempty" yaml:"summary,omitempty"` // 包总结，没有时为空
+	Files   []FileSummary `json:"files" yaml:"files"`
+}
+
+// FileSummaryReport 由保存的文件分析结果重新生成的文件总结，包和文件都按路径排序
+type FileSummaryReport struct {
+	Project  string         `json:"project" yaml:"project"`
+	Packages []PackageFiles `json:"packages" yaml:"packages"`
+}
@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+// LLMResponse 一次 LLM 调用的回复及 token 用量
+type LLMResponse struct {
+	Content          string `yaml:"content" json:"content"`
+	Model            string `yaml:"model,omitempty" json:"model,omitempty"`
+	PromptTokens     int    `yaml:"prompt_tokens,omitempty" json:"prompt_tokens,omitempty"`
+	CompletionTokens int    `yaml:"completion_tokens,omitempty" json:"completion_tokens,omitempty"`
+}
+
+// EmbeddingResponse 一次向量请求的结果，Vectors 与请求的文本一一对应
+type EmbeddingResponse struct {
+	Vectors      [][]float32 `yaml:"vectors" json:"vectors"`
+	Model        string      `yaml:"model,omitempty" json:"model,omitempty"`
+	PromptTokens int         `yaml:"prompt_tokens,omitempty" json:"prompt_tokens,omitempty"`
+}
+
+// TokenUsage 汇总的 token 用量和费用
+type TokenUsage struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Add 累加一次调用的用量
+func (u *TokenUsage) Add(promptTokens, completionTokens int, cost float64) {
+	u.Calls++
+	u.PromptTokens += promptTokens
+	u.CompletionTokens += completionTokens
+	u.Cost += cost
+}
+
+// UsageReport 一次运行的用量报告，按文件、阶段、模型分别汇总
+type UsageReport struct {
+	RunID          string                `json:"run_id,omitempty"` // 与本次运行的日志关联
+	Commit         string                `json:"commit,omitempty"` // 分析的提交 SHA
+	StartedAt      time.Time             `json:"started_at"`
+	FinishedAt     time.Time             `json:"finished_at"`
+	Currency       string                `json:"currency"`
+	Budget         float64               `json:"budget,omitempty"`
+	BudgetExceeded bool                  `json:"budget_exceeded"`
+	Total          TokenUsage            `json:"total"`
+	ByStage        map[string]TokenUsage `json:"by_stage"`
+	ByModel        map[string]TokenUsage `json:"by_model"`
+	ByFile         map[string]TokenUsage `json:"by_file"`
+}
@@ -0,0 +1,261 @@
+// Package config 按优先级合并命令行参数、环境变量、项目配置文件、用户配置文件和默认值。
+//
+// 优先级从高到低：
+//  1. 命令行参数
+//  2. CODEANALYSIS_<KEY> 环境变量，例如 CODEANALYSIS_OUTPUT_DIR
+//  3. 项目配置文件：--config 指定的文件，否则为源码目录或当前目录下的 .codeanalysis.yaml，
+//     兼容旧版本的 ./config/config.yaml
+//  4. 用户配置文件：<用户配置目录>/codeanalysis/config.yaml，Linux 上为 ~/.config/codeanalysis/config.yaml
+//  5. 命令行参数的默认值
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"codetest/internal/pkg/secret"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量的前缀
+const EnvPrefix = "CODEANALYSIS_"
+
+// ProjectFileName 项目配置文件名
+const ProjectFileName = ".codeanalysis.yaml"
+
+// LegacyFile 旧版本默认的配置文件
+const LegacyFile = "./config/config.yaml"
+
+// 值的来源
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceDefault = "default"
+)
+
+// Key 一个配置项，Name 为配置文件中的键，Flag 为对应的命令行参数
+type Key struct {
+	Name    string
+	Flag    string
+	Default string // 用于 config show，命令行参数的默认值以各命令注册的为准
+	Secret  bool   // 输出时隐藏
+}
+
+// Env 返回配置项对应的环境变量名
+func (k Key) Env() string {
+	return EnvPrefix + strings.ToUpper(k.Name)
+}
+
+// Keys 所有支持的配置项
+var Keys = []Key{
+	{Name: "dir", Flag: "dir", Default: "."},
+	{Name: "output_dir", Flag: "output-dir", Default: "./result"},
+	{Name: "project_name", Flag: "project-name"},
+	{Name: "project_id", Flag: "project-id", Default: "0"},
+	{Name: "language", Flag: "language"},
+	{Name: "language_version", Flag: "language-version"},
+	{Name: "openai_token", Flag: "token", Secret: true},
+	{Name: "api_base_path", Flag: "api-base-path"},
+	{Name: "username", Flag: "username"},
+	{Name: "password", Flag: "password", Secret: true},
+	{Name: "api_key", Flag: "api-key", Secret: true},
+	{Name: "budget", Flag: "budget", Default: "0"},
+	{Name: "price_table", Flag: "price-table"},
+	{Name: "sink", Flag: "sink", Default: "local"},
+	{Name: "summary_format", Flag: "summary-format", Default: "markdown"},
+	{Name: "db", Flag: "db"},
+	{Name: "prompts_dir", Flag: "prompts-dir"},
+	{Name: "output_language", Flag: "output-language", Default: "zh"},
+	{Name: "glossary", Flag: "glossary"},
+	{Name: "log_level", Flag: "log-level", Default: "info"},
+	{Name: "log_format", Flag: "log-format", Default: "text"},
+	{Name: "log_file", Flag: "log-file"},
+	{Name: "trace_file", Flag: "trace-file"},
+}
+
+// Layer 一层配置，Values 的 key 为配置项名称
+type Layer struct {
+	Source string // 例如 env 或配置文件的路径
+	Values map[string]string
+}
+
+// Value 配置项的最终取值及来源
+type Value struct {
+	Key    Key
+	Value  string
+	Source string
+}
+
+// Display 返回用于输出的值，保密的配置项只显示是否设置，env:、file: 等引用原样显示
+func (v Value) Display() string {
+	if v.Key.Secret && v.Value != "" && !secret.IsReference(v.Value) {
+		return secret.Mask
+	}
+	return v.Value
+}
+
+// EnvLayer 从环境变量读取配置，environ 的格式与 os.Environ 相同
+func EnvLayer(environ []string) Layer {
+	layer := Layer{Source: SourceEnv, Values: map[string]string{}}
+	env := map[string]string{}
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	for _, key := range Keys {
+		if v, ok := env[key.Env()]; ok {
+			layer.Values[key.Name] = v
+		}
+	}
+	return layer
+}
+
+// FileLayer 读取 YAML 配置文件，文件不存在时返回 os.ErrNotExist
+func FileLayer(path string) (Layer, error) {
+	layer := Layer{Source: path, Values: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return layer, err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return layer, fmt.Errorf("failed to decode config file %s: %v", path, err)
+	}
+
+	known := map[string]bool{}
+	for _, key := range Keys {
+		known[key.Name] = true
+	}
+	for name, value := range raw {
+		if !known[name] {
+			return layer, fmt.Errorf("unknown key %q in config file %s", name, path)
+		}
+		switch v := value.(type) {
+		case nil:
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			layer.Values[name] = strings.Join(items, ",")
+		case float64:
+			layer.Values[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			layer.Values[name] = fmt.Sprint(v)
+		}
+	}
+	return layer, nil
+}
+
+// UserFile 返回用户配置文件的路径
+func UserFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "codeanalysis", "config.yaml")
+}
+
+// ProjectFile 返回项目配置文件的路径：explicit 不为空时直接使用，否则依次查找
+// sourceDir 和当前目录下的 .codeanalysis.yaml，以及旧版本的 ./config/config.yaml。找不到时返回空。
+func ProjectFile(explicit, sourceDir string) string {
+	if explicit != "" {
+		return explicit
+	}
+	candidates := []string{filepath.Join(sourceDir, ProjectFileName), ProjectFileName, LegacyFile}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Layers 按优先级从高到低返回环境变量、项目配置文件和用户配置文件三层配置。
+// explicit 为 --config 指定的文件，必须存在；其他配置文件不存在时跳过。
+func Layers(environ []string, explicit, sourceDir, userFile string) ([]Layer, error) {
+	env := EnvLayer(environ)
+	layers := []Layer{env}
+	if sourceDir == "" {
+		sourceDir = env.Values["dir"]
+	}
+	if sourceDir == "" {
+		sourceDir = "."
+	}
+	for _, path := range []string{ProjectFile(explicit, sourceDir), userFile} {
+		if path == "" {
+			continue
+		}
+		layer, err := FileLayer(path)
+		if errors.Is(err, os.ErrNotExist) && path != explicit {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file: %v", err)
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// Apply 对 flags 中没有在命令行设置的参数，按 layers 的顺序使用第一个有值的配置，
+// 返回命令支持的每个配置项的最终取值及来源
+func Apply(flags *pflag.FlagSet, layers []Layer) ([]Value, error) {
+	var values []Value
+	for _, key := range Keys {
+		flag := flags.Lookup(key.Flag)
+		if flag == nil {
+			continue
+		}
+		value := Value{Key: key, Source: SourceDefault}
+		if flag.Changed {
+			value.Source = SourceFlag
+		} else {
+			for _, layer := range layers {
+				v, ok := layer.Values[key.Name]
+				if !ok {
+					continue
+				}
+				if err := flag.Value.Set(v); err != nil {
+					return nil, fmt.Errorf("invalid %s from %s: %v", key.Name, layer.Source, err)
+				}
+				value.Source = layer.Source
+				break
+			}
+		}
+		value.Value = flagString(flag)
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Resolve 不依赖命令行参数，返回所有配置项在 layers 下的最终取值，用于 config show
+func Resolve(layers []Layer) []Value {
+	values := make([]Value, 0, len(Keys))
+	for _, key := range Keys {
+		value := Value{Key: key, Value: key.Default, Source: SourceDefault}
+		for _, layer := range layers {
+			if v, ok := layer.Values[key.Name]; ok {
+				value.Value, value.Source = v, layer.Source
+				break
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// flagString 返回参数的值，列表参数以逗号分隔
+func flagString(flag *pflag.Flag) string {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		return strings.Join(slice.GetSlice(), ",")
+	}
+	return flag.Value.String()
+}
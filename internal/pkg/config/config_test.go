@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyPrecedence(t *testing.T) {
+	source := t.TempDir()
+	project := filepath.Join(source, ProjectFileName)
+	user := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, project, "output_dir: ./project\nproject_name: demo\nsink: [local, sqlite]\nopenai_token: sk-project\n")
+	writeConfig(t, user, "output_dir: ./user\nproject_name: user\nlanguage: go\nbudget: 1.5\n")
+
+	layers, err := Layers([]string{"CODEANALYSIS_PROJECT_NAME=env", "OTHER=1"}, "", source, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := pflag.NewFlagSet("analyze", pflag.ContinueOnError)
+	var outputDir, projectName, language, token, prompts string
+	var budget float64
+	var sinks []string
+	flags.StringVar(&outputDir, "output-dir", "./result", "")
+	flags.StringVar(&projectName, "project-name", "", "")
+	flags.StringVar(&language, "language", "", "")
+	flags.StringVar(&token, "token", "", "")
+	flags.StringVar(&prompts, "prompts-dir", "", "")
+	flags.Float64Var(&budget, "budget", 0, "")
+	flags.StringSliceVar(&sinks, "sink", []string{"local"}, "")
+	if err := flags.Parse([]string{"--output-dir", "./flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := Apply(flags, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputDir != "./flag" || projectName != "env" || language != "go" || budget != 1.5 ||
+		strings.Join(sinks, ",") != "local,sqlite" || token != "sk-project" || prompts != "" {
+		t.Errorf("applied %q %q %q %v %v %q %q", outputDir, projectName, language, budget, sinks, token, prompts)
+	}
+
+	sources := map[string]string{}
+	for _, v := range values {
+		sources[v.Key.Name] = v.Source
+		if v.Key.Name == "openai_token" && v.Display() != "******" {
+			t.Errorf("secret is displayed as %q", v.Display())
+		}
+	}
+	want := map[string]string{
+		"output_dir":   SourceFlag,
+		"project_name": SourceEnv,
+		"openai_token": project,
+		"language":     user,
+		"prompts_dir":  SourceDefault,
+	}
+	for name, source := range want {
+		if sources[name] != source {
+			t.Errorf("source of %s = %q, want %q", name, sources[name], source)
+		}
+	}
+	if _, ok := sources["dir"]; ok {
+		t.Error("keys without a flag in the command must be skipped")
+	}
+}
+
+func TestLayersErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Layers(nil, filepath.Join(dir, "missing.yaml"), dir, ""); err == nil {
+		t.Error("a missing --config file must be an error")
+	}
+	if _, err := Layers(nil, "", dir, filepath.Join(dir, "missing.yaml")); err != nil {
+		t.Errorf("a missing user file is skipped, got %v", err)
+	}
+
+	typo := filepath.Join(dir, "typo.yaml")
+	writeConfig(t, typo, "ouput_dir: ./result\n")
+	if _, err := Layers(nil, typo, dir, ""); err == nil || !strings.Contains(err.Error(), "ouput_dir") {
+		t.Errorf("unknown key error = %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad.yaml")
+	writeConfig(t, bad, "budget: lots\n")
+	layers, err := Layers(nil, bad, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := pflag.NewFlagSet("analyze", pflag.ContinueOnError)
+	flags.Float64("budget", 0, "")
+	if _, err := Apply(flags, layers); err == nil || !strings.Contains(err.Error(), bad) {
+		t.Errorf("invalid value error = %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	values := Resolve([]Layer{{Source: SourceEnv, Values: map[string]string{"sink": "sqlite"}}})
+	if len(values) != len(Keys) {
+		t.Fatalf("got %d values", len(values))
+	}
+	for _, v := range values {
+		switch v.Key.Name {
+		case "sink":
+			if v.Value != "sqlite" || v.Source != SourceEnv {
+				t.Errorf("sink = %+v", v)
+			}
+		case "output_dir":
+			if v.Value != "./result" || v.Source != SourceDefault {
+				t.Errorf("output_dir = %+v", v)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	committed := filepath.Join(dir, "committed.yaml")
+	private := filepath.Join(dir, "private.yaml")
+	writeConfig(t, committed, "openai_token: sk-plain\npassword: env:VALIDATE_TEST_PASSWORD\napi_key: file:-\n")
+	writeConfig(t, private, "openai_token: sk-plain\n")
+	if err := os.Chmod(private, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VALIDATE_TEST_PASSWORD", "secret")
+
+	layers, err := Layers([]string{"CODEANALYSIS_OPENAI_TOKEN=sk-env"}, committed, dir, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := func(path string) string {
+		if path == committed {
+			return "tracked"
+		}
+		return ""
+	}
+	warnings := Validate(layers, status)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], committed) || !strings.Contains(warnings[0], "openai_token") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	writeConfig(t, committed, "password: env:VALIDATE_TEST_MISSING\n")
+	layers, _ = Layers(nil, committed, dir, "")
+	if warnings := Validate(layers, status); len(warnings) != 1 || !strings.Contains(warnings[0], "cannot be resolved") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	value := Value{Key: Key{Name: "password", Secret: true}, Value: "pass:workflow"}
+	if value.Display() != "pass:workflow" {
+		t.Errorf("references are not secret, got %q", value.Display())
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	dir := t.TempDir()
+	// 测试中拼出 key，避免测试文件本身包含像 key 的字符串
+	key := "sk-" + strings.Repeat("aB3", 16)
+	writeConfig(t, filepath.Join(dir, "cmd", "question.go"), "package cmd\n\n// question -t "+key+" -s summary.md\n")
+	writeConfig(t, filepath.Join(dir, "readme.md"), "go run entry/main.go analyze -t sk-xxx\n")
+	writeConfig(t, filepath.Join(dir, "Makefile"), "TOKEN := sk-proj-"+strings.Repeat("x", 30)+"\n")
+
+	warnings := ScanKeys(dir, []string{"cmd/question.go", "readme.md", "Makefile", "missing.go"})
+	if len(warnings) != 2 || !strings.HasPrefix(warnings[0], "cmd/question.go:3:") || !strings.HasPrefix(warnings[1], "Makefile:1:") {
+		t.Errorf("warnings = %q", warnings)
+	}
+	for _, warning := range warnings {
+		if strings.Contains(warning, key) {
+			t.Errorf("warning repeats the key: %q", warning)
+		}
+	}
+}
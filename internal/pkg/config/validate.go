@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"codetest/internal/pkg/repoinfo"
+	"codetest/internal/pkg/secret"
+)
+
+// Validate 检查配置中的保密项，返回警告：
+// 配置文件中明文保存的保密项所在文件已提交到 git、可能被提交或其他用户可读，
+// 以及无法读取的 env:、file:、pass:、netrc: 引用。gitStatus 为 nil 时使用 repoinfo.GitFileStatus。
+func Validate(layers []Layer, gitStatus func(path string) string) []string {
+	if gitStatus == nil {
+		gitStatus = repoinfo.GitFileStatus
+	}
+	var warnings []string
+	for _, layer := range layers {
+		var plain []string
+		for _, key := range Keys {
+			value, ok := layer.Values[key.Name]
+			if !key.Secret || !ok || value == "" {
+				continue
+			}
+			if !secret.IsReference(value) {
+				plain = append(plain, key.Name)
+				continue
+			}
+			// 标准输入只能读取一次，留给真正执行的命令
+			if value == secret.PrefixFile+"-" {
+				continue
+			}
+			if _, err := secret.Resolve(value); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %s cannot be resolved: %v", layer.Source, key.Name, err))
+			}
+		}
+		if len(plain) == 0 || layer.Source == SourceEnv {
+			continue
+		}
+		sort.Strings(plain)
+		keys := strings.Join(plain, ", ")
+		switch gitStatus(layer.Source) {
+		case repoinfo.FileTracked:
+			warnings = append(warnings, fmt.Sprintf("%s: %s stored in plain text in a file committed to git; "+
+				"remove it from the file and rotate it, then use an environment variable or an env:, file:, pass: or netrc: reference", layer.Source, keys))
+		case repoinfo.FileUntracked:
+			warnings = append(warnings, fmt.Sprintf("%s: %s stored in plain text in a file that is not ignored by git and may be committed; "+
+				"use an env:, file:, pass: or netrc: reference or add the file to .gitignore", layer.Source, keys))
+		default:
+			if info, err := os.Stat(layer.Source); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+				warnings = append(warnings, fmt.Sprintf("%s: %s stored in plain text in a file readable by other users; run chmod 600 %s",
+					layer.Source, keys, layer.Source))
+			}
+		}
+	}
+	return warnings
+}
+
+// maxScanSize 超过这个大小的文件不检查，避免读取数据文件
+const maxScanSize = 1 << 20
+
+// apiKeyPattern 匹配 OpenAI 等服务的 API key，sk-xxx 这样的占位符太短不会匹配
+var apiKeyPattern = regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{20,}`)
+
+// ScanKeys 检查 dir 中的 files（相对 dir 的路径，例如 repoinfo.GitFiles 的结果）是否包含明文 API key，
+// 每一处返回一条带文件名和行号的警告。无法读取和过大的文件跳过
+func ScanKeys(dir string, files []string) []string {
+	var warnings []string
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxScanSize {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxScanSize)
+		for line := 1; scanner.Scan(); line++ {
+			if apiKeyPattern.MatchString(scanner.Text()) {
+				warnings = append(warnings, fmt.Sprintf("%s:%d: looks like an API key in a file that is or may be committed to git; "+
+					"replace it with a placeholder such as sk-xxx and rotate the key", name, line))
+			}
+		}
+		file.Close()
+	}
+	return warnings
+}
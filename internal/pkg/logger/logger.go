@@ -1,45 +1,99 @@
+// Package logger 基于 log/slog 配置整个工具使用的日志。
+//
+// Setup 之后 slog 的默认 logger 和标准库 log 都写到同一个输出，每条日志都带有本次运行的 run_id，
+// 登记过的保密内容会被隐藏。
 package logger
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"sync"
+	"path/filepath"
+	"strings"
+
+	"codetest/internal/pkg/secret"
+)
+
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
-// Logger 结构体表示日志记录器
-type Logger struct {
-	logFile *os.File
-	mutex   sync.Mutex
+// RunIDKey 关联同一次运行的日志、trace 和报告的属性名
+const RunIDKey = "run_id"
+
+// Options 日志配置
+type Options struct {
+	Level  string // debug、info、warn、error
+	Format string // text 或 json
+	File   string // 为空时写到标准错误
 }
 
-// NewLogger 创建新的 Logger 实例
-func NewLogger(filePath string) (*Logger, error) {
-	logFile, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %v", err)
+// ParseLevel 解析日志级别
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("unknown log level %q, supported levels: debug, info, warn, error", level)
 	}
-
-	return &Logger{
-		logFile: logFile,
-	}, nil
+	return l, nil
 }
 
-// LogDetail 记录详细日志
-func (l *Logger) LogDetail(text string) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
+// NewRunID 生成本次运行的关联 ID
+func NewRunID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
 
-	if _, err := l.logFile.WriteString(text + "\n"); err != nil {
-		fmt.Printf("Failed to write log: %v\n", err)
+// New 创建写到 w 的 logger，写入前隐藏保密内容
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	handlerOptions := &slog.HandlerOptions{Level: level}
+	w = secret.NewWriter(w)
+	switch strings.ToLower(opts.Format) {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(w, handlerOptions)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOptions)), nil
 	}
+	return nil, fmt.Errorf("unknown log format %q, supported formats: %s, %s", opts.Format, FormatText, FormatJSON)
 }
 
-// Close 关闭日志文件
-func (l *Logger) Close() {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
+// Setup 按 opts 创建 logger 并设为 slog 的默认 logger，所有日志都带有 runID。
+// opts.File 为空时写到 w，通常为标准错误。
+func Setup(w io.Writer, opts Options, runID string) error {
+	if opts.File != "" {
+		file, err := OpenFile(opts.File)
+		if err != nil {
+			return err
+		}
+		w = file
+	}
+	l, err := New(w, opts)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(l.With(RunIDKey, runID))
+	return nil
+}
 
-	if err := l.logFile.Close(); err != nil {
-		fmt.Printf("Failed to close log file: %v\n", err)
+// OpenFile 以追加方式打开日志文件，目录不存在时自动创建
+func OpenFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %v", err)
 	}
+	return file, nil
 }
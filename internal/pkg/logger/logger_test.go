@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"codetest/internal/pkg/secret"
+)
+
+func TestNew(t *testing.T) {
+	secret.Register("sk-logger-test")
+
+	var buf bytes.Buffer
+	l, err := New(&buf, Options{Level: "warn", Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = l.With(RunIDKey, "run-1")
+	l.Info("hidden")
+	l.Warn("login failed", "error", "invalid token sk-logger-test")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if record["msg"] != "login failed" || record[RunIDKey] != "run-1" || record["error"] != "invalid token "+secret.Mask {
+		t.Errorf("record = %v", record)
+	}
+
+	buf.Reset()
+	if l, err = New(&buf, Options{Level: "DEBUG"}); err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("text", "file", "a.go")
+	if !strings.Contains(buf.String(), "level=DEBUG msg=text file=a.go") {
+		t.Errorf("text log = %q", buf.String())
+	}
+
+	if _, err := New(&buf, Options{Level: "loud"}); err == nil {
+		t.Error("unknown level must be an error")
+	}
+	if _, err := New(&buf, Options{Level: "info", Format: "xml"}); err == nil {
+		t.Error("unknown format must be an error")
+	}
+}
@@ -0,0 +1,169 @@
+// Package progress 在终端中显示长时间任务的进度条，输出不是终端时改为定期写日志。
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// barWidth 进度条的字符宽度
+const barWidth = 30
+
+// Bar 进度条，Update 之间写入的日志通过 Writer 输出，避免和进度条混在同一行
+type Bar struct {
+	w           io.Writer
+	interactive bool
+	total       int
+	start       time.Time
+	now         func() time.Time
+
+	mutex      sync.Mutex
+	done       int
+	tokens     int
+	lastLogged int // 非终端时上一次写日志的进度（百分比）
+	drawn      bool
+}
+
+// IsTerminal 判断 f 是否为终端
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// New 创建 total 个任务的进度条，w 为终端时显示进度条，否则每完成 10% 写一条日志
+func New(w io.Writer, total int) *Bar {
+	file, ok := w.(*os.File)
+	return &Bar{
+		w:           w,
+		interactive: ok && IsTerminal(file),
+		total:       total,
+		start:       time.Now(),
+		now:         time.Now,
+	}
+}
+
+// Interactive 是否在终端中显示进度条
+func (b *Bar) Interactive() bool {
+	return b.interactive
+}
+
+// Update 更新已完成的任务数和已用的 token 数
+func (b *Bar) Update(done, tokens int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.done, b.tokens = done, tokens
+	if b.interactive {
+		b.draw()
+		return
+	}
+	if b.total == 0 {
+		return
+	}
+	percent := done * 100 / b.total
+	if percent/10 > b.lastLogged/10 || done == b.total {
+		b.lastLogged = percent
+		slog.Info("Progress", "done", done, "total", b.total, "files_per_minute", fmt.Sprintf("%.1f", b.rate()),
+			"eta", b.eta().String(), "tokens", tokens)
+	}
+}
+
+// Finish 结束进度条，之后的输出从新的一行开始
+func (b *Bar) Finish() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.interactive && b.drawn {
+		fmt.Fprintln(b.w)
+		b.drawn = false
+	}
+}
+
+// Line 返回进度条的内容，例如 [=====>    ] 12/40 30% | 6.0 files/min | ETA 4m40s | 12.3k tokens
+func (b *Bar) Line() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.line()
+}
+
+func (b *Bar) line() string {
+	percent := 100
+	if b.total > 0 {
+		percent = b.done * 100 / b.total
+	}
+	filled := percent * barWidth / 100
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	eta := "--"
+	if b.done > 0 {
+		eta = b.eta().String()
+	}
+	return fmt.Sprintf("[%s] %d/%d %3d%% | %.1f files/min | ETA %s | %s tokens",
+		bar, b.done, b.total, percent, b.rate(), eta, formatCount(b.tokens))
+}
+
+// rate 每分钟完成的任务数
+func (b *Bar) rate() float64 {
+	elapsed := b.now().Sub(b.start).Minutes()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(b.done) / elapsed
+}
+
+// eta 按目前的速度估算剩余时间
+func (b *Bar) eta() time.Duration {
+	if b.done == 0 || b.done >= b.total {
+		return 0
+	}
+	elapsed := b.now().Sub(b.start)
+	remaining := time.Duration(float64(elapsed) / float64(b.done) * float64(b.total-b.done))
+	return remaining.Round(time.Second)
+}
+
+func (b *Bar) draw() {
+	fmt.Fprintf(b.w, "\r\033[K%s", b.line())
+	b.drawn = true
+}
+
+// Writer 返回写入前清除进度条、写入后重新绘制的 io.Writer，用于在进度条显示期间输出日志
+func (b *Bar) Writer(w io.Writer) io.Writer {
+	if !b.interactive {
+		return w
+	}
+	return barWriter{bar: b, w: w}
+}
+
+type barWriter struct {
+	bar *Bar
+	w   io.Writer
+}
+
+func (bw barWriter) Write(p []byte) (int, error) {
+	bw.bar.mutex.Lock()
+	defer bw.bar.mutex.Unlock()
+	if bw.bar.drawn {
+		fmt.Fprint(bw.bar.w, "\r\033[K")
+	}
+	n, err := bw.w.Write(p)
+	if bw.bar.drawn {
+		bw.bar.draw()
+	}
+	return n, err
+}
+
+// formatCount 把较大的数字缩写为 12.3k、4.5M
+func formatCount(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return fmt.Sprint(n)
+}
@@ -0,0 +1,38 @@
+package progress
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBarLine(t *testing.T) {
+	var buf bytes.Buffer
+	bar := New(&buf, 40)
+	if bar.Interactive() {
+		t.Fatal("a buffer is not a terminal")
+	}
+	now := bar.start
+	bar.now = func() time.Time { return now }
+
+	if got := bar.Line(); got != "[>                             ] 0/40   0% | 0.0 files/min | ETA -- | 0 tokens" {
+		t.Errorf("empty line = %q", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	bar.Update(10, 12345)
+	if got := bar.Line(); got != "[=======>                      ] 10/40  25% | 5.0 files/min | ETA 6m0s | 12.3k tokens" {
+		t.Errorf("line = %q", got)
+	}
+
+	bar.Update(40, 2_500_000)
+	if got := bar.Line(); got != "[==============================] 40/40 100% | 20.0 files/min | ETA 0s | 2.5M tokens" {
+		t.Errorf("finished line = %q", got)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("a non-interactive bar must not draw, got %q", buf.String())
+	}
+	if bar.Writer(&buf) != &buf {
+		t.Error("a non-interactive bar returns the writer unchanged")
+	}
+}
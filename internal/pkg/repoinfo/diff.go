@@ -0,0 +1,141 @@
+package repoinfo
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// git 中文件的变化
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+	ChangeRenamed  = "renamed"
+)
+
+// Change 两个版本之间一个文件的变化
+type Change struct {
+	Status  string // ChangeAdded 等
+	Path    string // 相对 Diff 的 dir 的路径（使用 /），删除的文件为原来的路径
+	OldPath string // 重命名前的路径，只有 ChangeRenamed 有
+}
+
+// ParseDiffRange 解析 --diff 的 base..head
+func ParseDiffRange(value string) (base, head string, err error) {
+	base, head, ok := strings.Cut(value, "..")
+	if !ok || base == "" || head == "" || strings.HasPrefix(head, ".") {
+		return "", "", fmt.Errorf("invalid diff range %q, want base..head", value)
+	}
+	return base, head, nil
+}
+
+// ResolveCommit 返回 dir 所在仓库中 ref 对应的提交 SHA
+func ResolveCommit(dir, ref string) (string, error) {
+	return runGit(dir, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+}
+
+// HeadCommit 返回 dir 所在仓库当前的提交 SHA，不在仓库中或还没有提交时返回空字符串
+func HeadCommit(dir string) string {
+	return gitOutput(dir, "rev-parse", "--verify", "HEAD")
+}
+
+// Dirty 判断 dir 下已跟踪的文件是否有未提交的修改。未跟踪的文件不算，输出目录常常在源码目录中
+func Dirty(dir string) (bool, error) {
+	out, err := runGit(dir, "status", "--porcelain", "--untracked-files=no", "--", ".")
+	if err != nil {
+		return false, err
+	}
+	return out != "", nil
+}
+
+// Diff 返回 dir 下 base 到 head 之间变化的文件，只包含 dir 中的文件。
+// head 为空时与工作区比较，未提交的修改和未被忽略的新文件也算作变化。
+func Diff(dir, base, head string) ([]Change, error) {
+	args := []string{"diff", "--name-status", "-z", "-M", "--relative", base}
+	if head != "" {
+		args = append(args, head)
+	}
+	args = append(args, "--")
+	out, err := runGit(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := parseNameStatus(out)
+	if err != nil {
+		return nil, err
+	}
+	if head != "" {
+		return changes, nil
+	}
+
+	untracked, err := runGit(dir, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(untracked, "\x00") {
+		if path != "" {
+			changes = append(changes, Change{Status: ChangeAdded, Path: path})
+		}
+	}
+	return changes, nil
+}
+
+// UnifiedDiff 返回 dir 下 base 到 head 之间的统一格式补丁，路径相对 dir。
+// head 为空时与工作区比较，未跟踪的新文件不在补丁中。
+func UnifiedDiff(dir, base, head string) (string, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff", "-M", "--relative", base}
+	if head != "" {
+		args = append(args, head)
+	}
+	return runGit(dir, append(args, "--")...)
+}
+
+// parseNameStatus 解析 git diff --name-status -z 的输出：状态和路径以 NUL 分隔，重命名和复制有两个路径
+func parseNameStatus(out string) ([]Change, error) {
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	var changes []Change
+	for i := 0; i < len(fields); i++ {
+		status := fields[i]
+		if status == "" {
+			continue
+		}
+		paths := 1
+		if status[0] == 'R' || status[0] == 'C' {
+			paths = 2
+		}
+		if i+paths >= len(fields) {
+			return nil, fmt.Errorf("unexpected git diff output near %q", status)
+		}
+		path := fields[i+paths]
+		switch status[0] {
+		case 'A', 'C':
+			changes = append(changes, Change{Status: ChangeAdded, Path: path})
+		case 'M', 'T':
+			changes = append(changes, Change{Status: ChangeModified, Path: path})
+		case 'D':
+			changes = append(changes, Change{Status: ChangeDeleted, Path: path})
+		case 'R':
+			changes = append(changes, Change{Status: ChangeRenamed, Path: path, OldPath: fields[i+1]})
+		default:
+			return nil, fmt.Errorf("unsupported git diff status %q for %s", status, path)
+		}
+		i += paths
+	}
+	return changes, nil
+}
+
+// runGit 在 dir 中执行 git 命令，返回去掉首尾空白的输出，失败时错误中包含 git 的错误信息
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s failed: %v", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,125 @@
+package repoinfo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// git 在 root 中执行 git 命令，提交时使用固定的作者
+func git(t *testing.T, root string, args ...string) {
+	t.Helper()
+	args = append([]string{"-C", root, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"README.md":      "demo\n",
+		"src/keep.go":    "package src\n",
+		"src/edit.go":    "package src\n",
+		"src/remove.go":  "package src\n\nfunc Remove() {}\n",
+		"src/rename.go":  "package src\n\nfunc Rename() {}\n",
+		"other/skip.go":  "package other\n",
+		"src/.gitignore": "ignored.go\n",
+	})
+	git(t, root, "init", "-q")
+	git(t, root, "add", ".")
+	git(t, root, "commit", "-q", "-m", "base")
+	base := HeadCommit(root)
+	if base == "" {
+		t.Fatal("HeadCommit must return the commit")
+	}
+
+	writeFiles(t, root, map[string]string{"src/edit.go": "package src\n\nfunc Edit() {}\n", "src/add.go": "package src\n\nfunc Add() {}\n", "other/skip.go": "package other\n\n"})
+	os.Remove(filepath.Join(root, "src/remove.go"))
+	git(t, root, "mv", "src/rename.go", "src/renamed.go")
+	git(t, root, "add", "-A")
+	git(t, root, "commit", "-q", "-m", "change")
+	head, err := ResolveCommit(root, "HEAD")
+	if err != nil || head == base {
+		t.Fatalf("ResolveCommit = %q, %v", head, err)
+	}
+
+	changes, err := Diff(filepath.Join(root, "src"), base, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	want := []Change{
+		{Status: ChangeAdded, Path: "add.go"},
+		{Status: ChangeModified, Path: "edit.go"},
+		{Status: ChangeDeleted, Path: "remove.go"},
+		{Status: ChangeRenamed, Path: "renamed.go", OldPath: "rename.go"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v", changes)
+	}
+
+	patch, err := UnifiedDiff(filepath.Join(root, "src"), base, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(patch, "diff --git a/edit.go b/edit.go") || !strings.Contains(patch, "+func Edit() {}") || strings.Contains(patch, "skip.go") {
+		t.Errorf("patch = %s", patch)
+	}
+
+	if dirty, err := Dirty(root); err != nil || dirty {
+		t.Errorf("Dirty after commit = %v, %v", dirty, err)
+	}
+
+	// 与工作区比较时包括未提交的修改和新文件，不包括被忽略的文件
+	writeFiles(t, root, map[string]string{"src/keep.go": "package src\n\n", "src/new.go": "package src\n", "src/ignored.go": "package src\n"})
+	changes, err = Diff(filepath.Join(root, "src"), head, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Change{{Status: ChangeModified, Path: "keep.go"}, {Status: ChangeAdded, Path: "new.go"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("working tree changes = %+v", changes)
+	}
+	if dirty, err := Dirty(filepath.Join(root, "src")); err != nil || !dirty {
+		t.Errorf("Dirty with uncommitted changes = %v, %v", dirty, err)
+	}
+	if dirty, err := Dirty(filepath.Join(root, "other")); err != nil || dirty {
+		t.Errorf("Dirty of an unchanged directory = %v, %v", dirty, err)
+	}
+
+	if _, err := Diff(root, "missing-ref", ""); err == nil {
+		t.Error("an unknown ref must be an error")
+	}
+}
+
+func TestParseDiffRange(t *testing.T) {
+	if base, head, err := ParseDiffRange("main..feature/x"); err != nil || base != "main" || head != "feature/x" {
+		t.Errorf("ParseDiffRange = %q %q %v", base, head, err)
+	}
+	for _, value := range []string{"main", "..head", "main..", "main...head"} {
+		if _, _, err := ParseDiffRange(value); err == nil {
+			t.Errorf("%q must be invalid", value)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// NetrcFile 返回 .netrc 文件的路径：环境变量 NETRC，否则为用户目录下的 .netrc（Windows 上为 _netrc）
+func NetrcFile() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// LookupNetrc 返回 .netrc 中 host 对应的用户名和密码，没有 host 时使用 default 条目
+func LookupNetrc(path, host string) (login, password string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read netrc: %v", err)
+	}
+
+	type entry struct{ login, password string }
+	var (
+		found, fallback *entry
+		current         *entry
+	)
+	fields := strings.Fields(string(data))
+	for i := 0; i < len(fields); i++ {
+		next := func() string {
+			if i+1 < len(fields) {
+				i++
+				return fields[i]
+			}
+			return ""
+		}
+		switch fields[i] {
+		case "machine":
+			current = nil
+			if next() == host && found == nil {
+				found = &entry{}
+				current = found
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &entry{}
+				current = fallback
+			}
+		case "login":
+			if v := next(); current != nil {
+				current.login = v
+			}
+		case "password":
+			if v := next(); current != nil {
+				current.password = v
+			}
+		case "account":
+			next()
+		case "macdef":
+			// 宏定义到空行结束，Fields 已经丢掉了空行，之后的内容无法区分，直接停止解析
+			i = len(fields)
+		}
+	}
+	if found == nil {
+		found = fallback
+	}
+	if found == nil {
+		return "", "", fmt.Errorf("no entry for %s in %s", host, path)
+	}
+	return found.login, found.password, nil
+}
@@ -0,0 +1,65 @@
+package secret
+
+import (
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minRedactLength 短于这个长度的值不隐藏，避免把日志中普通的短字符串替换掉
+const minRedactLength = 4
+
+// Mask 替换保密内容的字符串
+const Mask = "******"
+
+var (
+	mutex    sync.RWMutex
+	secrets  []string
+	replacer = strings.NewReplacer()
+)
+
+// Register 登记需要在日志中隐藏的保密内容
+func Register(values ...string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, v := range values {
+		if len(v) < minRedactLength || slices.Contains(secrets, v) {
+			continue
+		}
+		secrets = append(secrets, v)
+	}
+	// 先替换长的值，避免一个值是另一个值的一部分时留下残余
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	pairs := make([]string, 0, len(secrets)*2)
+	for _, v := range secrets {
+		pairs = append(pairs, v, Mask)
+	}
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact 隐藏 text 中登记过的保密内容
+func Redact(text string) string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return replacer.Replace(text)
+}
+
+// redactWriter 写入前隐藏保密内容
+type redactWriter struct {
+	w io.Writer
+}
+
+// NewWriter 返回写入前隐藏保密内容的 io.Writer，用于 log.SetOutput 等
+func NewWriter(w io.Writer) io.Writer {
+	return redactWriter{w: w}
+}
+
+// Write 写入隐藏后的内容，返回原始长度
+func (r redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,107 @@
+// Package secret 读取 token、密码等保密配置，并在日志中隐藏它们。
+//
+// 保密配置的值可以是下面几种引用，避免把明文写进命令行历史或配置文件：
+//
+//	env:NAME     读取环境变量 NAME
+//	file:PATH    读取文件 PATH 的第一行，PATH 为 - 时读取标准输入
+//	pass:NAME    读取密码目录（默认 <用户配置目录>/codeanalysis/secrets）下文件 NAME 的第一行
+//	netrc:HOST   读取 .netrc 中 machine HOST 的 password
+//
+// 其他值按明文处理。
+package secret
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 引用的前缀
+const (
+	PrefixEnv   = "env:"
+	PrefixFile  = "file:"
+	PrefixPass  = "pass:"
+	PrefixNetrc = "netrc:"
+)
+
+// StoreDirEnv 指定密码目录的环境变量
+const StoreDirEnv = "CODEANALYSIS_SECRETS_DIR"
+
+// Stdin 读取标准输入的来源，测试时可以替换
+var Stdin io.Reader = os.Stdin
+
+// IsReference 判断 value 是否为引用
+func IsReference(value string) bool {
+	for _, prefix := range []string{PrefixEnv, PrefixFile, PrefixPass, PrefixNetrc} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve 返回 value 引用的保密内容，value 不是引用时原样返回
+func Resolve(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, PrefixEnv):
+		name := strings.TrimPrefix(value, PrefixEnv)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(value, PrefixFile):
+		return ReadFile(strings.TrimPrefix(value, PrefixFile))
+	case strings.HasPrefix(value, PrefixPass):
+		name := strings.TrimPrefix(value, PrefixPass)
+		if name == "" || strings.Contains(name, "..") {
+			return "", fmt.Errorf("invalid secret name %q", name)
+		}
+		return ReadFile(filepath.Join(StoreDir(), filepath.FromSlash(name)))
+	case strings.HasPrefix(value, PrefixNetrc):
+		host := strings.TrimPrefix(value, PrefixNetrc)
+		_, password, err := LookupNetrc(NetrcFile(), host)
+		return password, err
+	}
+	return value, nil
+}
+
+// ReadFile 读取文件的第一行并去掉首尾空白，path 为 - 时读取标准输入
+func ReadFile(path string) (string, error) {
+	var r io.Reader
+	if path == "-" {
+		r = Stdin
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %v", err)
+		}
+		defer file.Close()
+		r = file
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read secret from %s: %v", path, err)
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", fmt.Errorf("secret in %s is empty", path)
+	}
+	return line, nil
+}
+
+// StoreDir 返回密码目录
+func StoreDir() string {
+	if dir := os.Getenv(StoreDirEnv); dir != "" {
+		return dir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "secrets"
+	}
+	return filepath.Join(dir, "codeanalysis", "secrets")
+}
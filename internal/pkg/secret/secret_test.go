@@ -0,0 +1,70 @@
+package secret
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SECRET_TEST_TOKEN", "sk-env")
+	t.Setenv(StoreDirEnv, filepath.Join(dir, "store"))
+	netrc := filepath.Join(dir, "netrc")
+	t.Setenv("NETRC", netrc)
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "token"), "  sk-file\nignored\n")
+	write(filepath.Join(dir, "store", "openai", "token"), "sk-pass\nurl: https://platform.openai.com\n")
+	write(netrc, "machine example.com login admin password p@ss\ndefault login anon password guest\n")
+
+	tests := map[string]string{
+		"plain":                               "plain",
+		"env:SECRET_TEST_TOKEN":               "sk-env",
+		"file:" + filepath.Join(dir, "token"): "sk-file",
+		"pass:openai/token":                   "sk-pass",
+		"netrc:example.com":                   "p@ss",
+		"netrc:other.com":                     "guest",
+	}
+	for ref, want := range tests {
+		if got, err := Resolve(ref); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	for _, ref := range []string{"env:SECRET_TEST_MISSING", "file:" + filepath.Join(dir, "missing"), "pass:../token"} {
+		if _, err := Resolve(ref); err == nil {
+			t.Errorf("Resolve(%q) should fail", ref)
+		}
+	}
+
+	Stdin = strings.NewReader("sk-stdin\n")
+	defer func() { Stdin = os.Stdin }()
+	if got, err := Resolve("file:-"); err != nil || got != "sk-stdin" {
+		t.Errorf("stdin = %q, %v", got, err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	Register("sk-abcdef", "sk-abcdef-long", "ab", "")
+	if got := Redact("token sk-abcdef-long and sk-abcdef, ab"); got != "token ****** and ******, ab" {
+		t.Errorf("Redact = %q", got)
+	}
+
+	var buf bytes.Buffer
+	logger := log.New(NewWriter(&buf), "", 0)
+	logger.Printf("request failed: invalid key sk-abcdef")
+	if got := buf.String(); got != "request failed: invalid key ******\n" {
+		t.Errorf("log line = %q", got)
+	}
+}
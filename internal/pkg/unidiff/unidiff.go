@@ -0,0 +1,252 @@
+// Package unidiff 解析 git diff 或 diff -u 输出的统一格式补丁
+package unidiff
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 行的类型
+const (
+	LineContext = ' '
+	LineAdded   = '+'
+	LineDeleted = '-'
+)
+
+// 文件的变化
+const (
+	StatusAdded    = "added"
+	StatusModified = "modified"
+	StatusDeleted  = "deleted"
+	StatusRenamed  = "renamed"
+)
+
+// devNull 新增或删除的文件在补丁中的另一侧路径
+const devNull = "/dev/null"
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// File 补丁中一个文件的变化
+type File struct {
+	OldPath string // 新增的文件为空
+	NewPath string // 删除的文件为空
+	Binary  bool
+	Hunks   []Hunk
+}
+
+// Hunk 以 @@ 开头的一段变化
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Section            string // @@ 之后的内容，git 会填入所在的函数
+	Lines              []Line
+}
+
+// Line 一行变化，OldLine 和 NewLine 为行号，不存在的一侧为 0
+type Line struct {
+	Kind    byte // LineContext、LineAdded 或 LineDeleted
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// Path 返回文件当前的路径，删除的文件返回原来的路径
+func (f File) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Status 返回文件的变化，StatusAdded 等
+func (f File) Status() string {
+	switch {
+	case f.OldPath == "":
+		return StatusAdded
+	case f.NewPath == "":
+		return StatusDeleted
+	case f.OldPath != f.NewPath:
+		return StatusRenamed
+	}
+	return StatusModified
+}
+
+// InHunk 判断新文件的第 line 行是否在某段变化中（包括上下文行）
+func (f File) InHunk(line int) bool {
+	for _, hunk := range f.Hunks {
+		if line >= hunk.NewStart && line < hunk.NewStart+hunk.NewLines {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse 解析统一格式的补丁，支持 git diff 的扩展头（重命名、二进制文件等）以及 diff -u 的输出。
+// 路径去掉 git 的 a/、b/ 前缀。
+func Parse(patch string) ([]File, error) {
+	var files []File
+	var file *File
+	gitHeader := false // 当前文件以 diff --git 开头，路径带 a/、b/ 前缀
+
+	scanner := bufio.NewScanner(strings.NewReader(patch))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		lineNo++
+		return strings.TrimSuffix(scanner.Text(), "\r"), true
+	}
+	flush := func() {
+		if file != nil {
+			files = append(files, *file)
+			file = nil
+		}
+	}
+
+	for {
+		text, ok := next()
+		if !ok {
+			break
+		}
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			flush()
+			file = &File{}
+			gitHeader = true
+			if oldPath, newPath, ok := parseGitHeader(strings.TrimPrefix(text, "diff --git ")); ok {
+				file.OldPath, file.NewPath = oldPath, newPath
+			}
+		case strings.HasPrefix(text, "--- "):
+			// diff -u 的输出没有 diff --git 头，--- 开始一个新文件
+			if file == nil || len(file.Hunks) > 0 {
+				flush()
+				file = &File{}
+				gitHeader = false
+			}
+			file.OldPath = patchPath(strings.TrimPrefix(text, "--- "), "a/", gitHeader)
+		case strings.HasPrefix(text, "+++ ") && file != nil:
+			file.NewPath = patchPath(strings.TrimPrefix(text, "+++ "), "b/", gitHeader)
+			if !gitHeader {
+				// diff -u 时两侧都有 a/、b/ 前缀才去掉
+				stripPrefixes(file)
+			}
+		case strings.HasPrefix(text, "new file mode") && file != nil:
+			file.OldPath = ""
+		case strings.HasPrefix(text, "deleted file mode") && file != nil:
+			file.NewPath = ""
+		case strings.HasPrefix(text, "rename from ") && file != nil:
+			file.OldPath = strings.TrimPrefix(text, "rename from ")
+		case strings.HasPrefix(text, "rename to ") && file != nil:
+			file.NewPath = strings.TrimPrefix(text, "rename to ")
+		case strings.HasPrefix(text, "Binary files ") && file != nil:
+			file.Binary = true
+		case strings.HasPrefix(text, "@@ "):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk without a file header", lineNo)
+			}
+			hunk, err := parseHunkHeader(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			oldLine, newLine := hunk.OldStart, hunk.NewStart
+			for oldLeft, newLeft := hunk.OldLines, hunk.NewLines; oldLeft > 0 || newLeft > 0; {
+				body, ok := next()
+				if !ok {
+					return nil, fmt.Errorf("line %d: hunk of %s ends early", lineNo, file.Path())
+				}
+				if strings.HasPrefix(body, `\`) { // \ No newline at end of file
+					continue
+				}
+				kind := byte(LineContext)
+				if body != "" {
+					kind = body[0]
+					body = body[1:]
+				}
+				switch kind {
+				case LineContext:
+					hunk.Lines = append(hunk.Lines, Line{Kind: kind, Text: body, OldLine: oldLine, NewLine: newLine})
+					oldLine, newLine, oldLeft, newLeft = oldLine+1, newLine+1, oldLeft-1, newLeft-1
+				case LineAdded:
+					hunk.Lines = append(hunk.Lines, Line{Kind: kind, Text: body, NewLine: newLine})
+					newLine, newLeft = newLine+1, newLeft-1
+				case LineDeleted:
+					hunk.Lines = append(hunk.Lines, Line{Kind: kind, Text: body, OldLine: oldLine})
+					oldLine, oldLeft = oldLine+1, oldLeft-1
+				default:
+					return nil, fmt.Errorf("line %d: unexpected line in hunk of %s: %q", lineNo, file.Path(), body)
+				}
+				if oldLeft < 0 || newLeft < 0 {
+					return nil, fmt.Errorf("line %d: hunk of %s is longer than its header", lineNo, file.Path())
+				}
+			}
+			file.Hunks = append(file.Hunks, hunk)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patch: %v", err)
+	}
+	flush()
+	return files, nil
+}
+
+// parseHunkHeader 解析 @@ -l,s +l,s @@ section
+func parseHunkHeader(text string) (Hunk, error) {
+	match := hunkHeaderRegex.FindStringSubmatch(text)
+	if match == nil {
+		return Hunk{}, fmt.Errorf("invalid hunk header %q", text)
+	}
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	oldStart, _ := strconv.Atoi(match[1])
+	newStart, _ := strconv.Atoi(match[3])
+	return Hunk{
+		OldStart: oldStart,
+		OldLines: count(match[2]),
+		NewStart: newStart,
+		NewLines: count(match[4]),
+		Section:  match[5],
+	}, nil
+}
+
+// parseGitHeader 从 diff --git a/x b/y 中取出路径，路径中有空格时无法区分，返回 false，由之后的 ---、+++ 或 rename 行确定
+func parseGitHeader(rest string) (string, string, bool) {
+	parts := strings.Split(rest, " ")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "a/") || !strings.HasPrefix(parts[1], "b/") {
+		return "", "", false
+	}
+	return parts[0][2:], parts[1][2:], true
+}
+
+// patchPath 解析 ---、+++ 行中的路径：/dev/null 返回空，去掉 diff -u 附加的时间戳，git 补丁去掉 prefix
+func patchPath(text, prefix string, gitHeader bool) string {
+	if path, _, ok := strings.Cut(text, "\t"); ok {
+		text = path
+	}
+	if text == devNull {
+		return ""
+	}
+	if gitHeader {
+		return strings.TrimPrefix(text, prefix)
+	}
+	return text
+}
+
+// stripPrefixes 两侧路径分别以 a/、b/ 开头时去掉前缀
+func stripPrefixes(file *File) {
+	oldOK := file.OldPath == "" || strings.HasPrefix(file.OldPath, "a/")
+	newOK := file.NewPath == "" || strings.HasPrefix(file.NewPath, "b/")
+	if oldOK && newOK && (file.OldPath != "" || file.NewPath != "") {
+		file.OldPath = strings.TrimPrefix(file.OldPath, "a/")
+		file.NewPath = strings.TrimPrefix(file.NewPath, "b/")
+	}
+}
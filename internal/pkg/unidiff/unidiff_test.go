@@ -0,0 +1,125 @@
+package unidiff
+
+import (
+	"slices"
+	"testing"
+)
+
+const gitPatch = `diff --git a/store/store.go b/store/store.go
+index 1111111..2222222 100644
+--- a/store/store.go
++++ b/store/store.go
+@@ -3,6 +3,7 @@ import "sync"
+ type Store struct {
+ 	mu sync.Mutex
+-	data map[string]string
++	data  map[string]string
++	count int
+ }
+
+ func New() *Store {
+@@ -20 +21 @@ func (s *Store) Get(key string) string {
+--- removed line that looks like a header
++	return s.data[key]
+diff --git a/old.go b/renamed.go
+similarity index 100%
+rename from old.go
+rename to renamed.go
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+index 3333333..0000000
+--- a/gone.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package store
+-
+diff --git a/logo.png b/logo.png
+new file mode 100644
+index 0000000..4444444
+Binary files /dev/null and b/logo.png differ
+diff --git a/main.go b/main.go
+new file mode 100644
+--- /dev/null
++++ b/main.go
+@@ -0,0 +1,2 @@
++package main
++func main() {}
+\ No newline at end of file
+`
+
+func TestParse(t *testing.T) {
+	files, err := Parse(gitPatch)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Status()+" "+f.Path())
+	}
+	want := []string{"modified store/store.go", "renamed renamed.go", "deleted gone.go", "added logo.png", "added main.go"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("files = %q, want %q", got, want)
+	}
+
+	store := files[0]
+	if len(store.Hunks) != 2 || store.Hunks[0].Section != `import "sync"` {
+		t.Fatalf("hunks = %+v", store.Hunks)
+	}
+	if lines := addedLines(store); !slices.Equal(lines, []int{5, 6, 21}) {
+		t.Errorf("added lines = %v, want [5 6 21]", lines)
+	}
+	if removed := store.Hunks[1].Lines[0]; removed.Kind != LineDeleted || removed.OldLine != 20 {
+		t.Errorf("removed line = %+v", removed)
+	}
+	if !store.InHunk(9) || store.InHunk(10) || !store.InHunk(21) {
+		t.Errorf("InHunk does not follow the hunk ranges")
+	}
+	if files[1].OldPath != "old.go" || len(files[1].Hunks) != 0 {
+		t.Errorf("rename = %+v", files[1])
+	}
+	if !files[3].Binary {
+		t.Errorf("logo.png should be binary")
+	}
+	if lines := addedLines(files[4]); !slices.Equal(lines, []int{1, 2}) {
+		t.Errorf("main.go added lines = %v", lines)
+	}
+}
+
+func TestParsePlainDiff(t *testing.T) {
+	files, err := Parse("--- a/x.go\t2024-01-01 10:00:00\n+++ b/x.go\t2024-01-02 10:00:00\n@@ -1 +1 @@\n-a\n+b\n" +
+		"--- y.go\n+++ y.go\n@@ -1,0 +2 @@\n+c\n")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(files) != 2 || files[0].Path() != "x.go" || files[1].Path() != "y.go" {
+		t.Fatalf("files = %+v", files)
+	}
+	if lines := addedLines(files[1]); !slices.Equal(lines, []int{2}) {
+		t.Errorf("y.go added lines = %v", lines)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, patch := range map[string]string{
+		"no file":   "@@ -1 +1 @@\n-a\n+b\n",
+		"truncated": "--- a/x.go\n+++ b/x.go\n@@ -1,3 +1,3 @@\n a\n",
+		"bad line":  "--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n?a\n",
+	} {
+		if _, err := Parse(patch); err == nil {
+			t.Errorf("%s: Parse succeeded", name)
+		}
+	}
+}
+
+// addedLines 返回新增或修改后的行号
+func addedLines(f File) []int {
+	var lines []int
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			if line.Kind == LineAdded {
+				lines = append(lines, line.NewLine)
+			}
+		}
+	}
+	return lines
+}
@@ -2,46 +2,79 @@ package usecase
 
 import (
 	"codetest/internal/entity"
+	"codetest/internal/usecase/prompt"
 	"context"
 	"fmt"
-	"gopkg.in/yaml.v3"
+	"log/slog"
 	"os"
+	"reflect"
 	"regexp"
 	"strings"
 )
 
 // aiCodeUseCase 处理与 AI 相关的用例
 type aiCodeUseCase struct {
-	client    LLMClient
-	logger    Logger
-	apiClient ApiClient
+	client  LLMClient
+	prompts *prompt.Set
 }
 
-// NewAiCode 创建新的 aiCodeUseCase
-func NewAiCode(client LLMClient, apiClient ApiClient) AICodeUseCase {
+// NewAiCode 创建新的 aiCodeUseCase，prompts 为 nil 时使用内嵌的默认模板
+func NewAiCode(client LLMClient, prompts *prompt.Set) AICodeUseCase {
+	if prompts == nil {
+		prompts = prompt.Default()
+	}
 	return &aiCodeUseCase{
-		client:    client,
-		apiClient: apiClient,
+		client:  client,
+		prompts: prompts,
 	}
 }
 
+// PromptVersion 返回指定模板的版本号
+func (uc *aiCodeUseCase) PromptVersion(name string) string {
+	return uc.prompts.Version(name)
+}
+
+// FileAnalysisPrompt 返回分析文件时发送给 LLM 的提示词，不调用 LLM
+func (uc *aiCodeUseCase) FileAnalysisPrompt(filename, code string) (string, error) {
+	return uc.prompts.Render(prompt.FileAnalysis, prompt.Data{Filename: filename, Code: code})
+}
+
 // AIAnalysisCode 进行代码分析
-func (uc *aiCodeUseCase) AIAnalysisCode(filename, code string) (string, entity.ParsedYAML, error) {
-	response, err := uc.client.GetResponse(buildFileAnalysisPrompt(filename, code))
+func (uc *aiCodeUseCase) AIAnalysisCode(ctx context.Context, filename, code string) (string, entity.ParsedYAML, error) {
+	ctx = WithFile(ctx, filename)
+	response, err := askLLM(ctx, uc.client, uc.prompts, prompt.FileAnalysis, prompt.Data{Filename: filename, Code: code})
 	if err != nil {
 		return "", entity.ParsedYAML{}, err
 	}
 
-	response = cleanYAMLResponse(response)
 	var parsedData entity.ParsedYAML
-	if err = yaml.Unmarshal([]byte(response), &parsedData); err != nil {
-		fmt.Println("Error parsing YAML:", err)
-		return response, parsedData, nil
+	response, err = unmarshalLLMYAML(ctx, uc.client, uc.prompts, response, analysisShape, &parsedData)
+	if err != nil {
+		return response, parsedData, fmt.Errorf("failed to parse analysis of %s: %w", filename, err)
 	}
 
 	return response, parsedData, nil
 }
 
+// askLLM 渲染模板并调用 LLM，调用在 context 中标记为模板对应的阶段
+func askLLM(ctx context.Context, client LLMClient, prompts *prompt.Set, name string, data prompt.Data) (string, error) {
+	p, err := prompts.Render(name, data)
+	if err != nil {
+		return "", err
+	}
+	response, err := client.GetResponse(WithStage(ctx, name), p)
+	if err != nil {
+		return "", err
+	}
+	return response.Content, nil
+}
+
+// analysisShape 文件分析结果的 YAML 结构
+var analysisShape = shapeOf(reflect.TypeOf(entity.FileAnalysis{}))
+
+// step1Shape 问题关联文件列表的 YAML 结构
+var step1Shape = shapeOf(reflect.TypeOf([]*entity.Step1FileInfo{}))
+
 // cleanYAMLResponse 清理 YAML 响应中的格式问题
 func cleanYAMLResponse(response string) string {
 	response = strings.TrimSpace(response)
@@ -68,98 +101,83 @@ func cleanYAMLResponse(response string) string {
 	}
 
 	regex := regexp.MustCompile(`- (\w+): \*(.*)`)
-	return regex.ReplaceAllString(response, `\$1: '*\$2'`)
+	return regex.ReplaceAllString(response, `- $1: '*$2'`)
 }
 
-// AIQuestion 处理问题并返回相关文件
-func (uc *aiCodeUseCase) AIQuestion(summaryContent, question, helpInfo string) ([]string, error) {
-	step1Response, err := uc.client.GetResponse(buildQuestionRelFilesPrompt(question, summaryContent))
+// AIQuestion 处理问题并返回最终答案
+func (uc *aiCodeUseCase) AIQuestion(ctx context.Context, summaryContent, question, helpInfo string) ([]string, error) {
+	step1Response, err := askLLM(ctx, uc.client, uc.prompts, prompt.QuestionRelFiles, prompt.Data{Question: question, Summary: summaryContent})
 	if err != nil {
 		return nil, err
 	}
 
-	step1FileInfos, err := parseStep1FileInfos(step1Response)
+	step1FileInfos, err := parseStep1FileInfos(ctx, uc.client, uc.prompts, step1Response)
 	if err != nil {
 		return nil, err
 	}
 
-	logFileInfo(uc.logger, step1FileInfos)
+	logFileInfo(ctx, step1FileInfos)
 
 	for _, fileInfo := range step1FileInfos {
-		if err := analyzeFile(uc.client, uc.logger, question, fileInfo); err != nil {
+		if err := analyzeFile(ctx, uc.client, uc.prompts, question, fileInfo); err != nil {
 			return nil, err
 		}
 	}
 
-	return summarizeFinalAnswer(uc.client, question, helpInfo, step1FileInfos)
+	return summarizeFinalAnswer(ctx, uc.client, uc.prompts, question, helpInfo, step1FileInfos)
 }
 
 // parseStep1FileInfos 从 YAML 响应中解析文件信息
-func parseStep1FileInfos(response string) ([]*entity.Step1FileInfo, error) {
-	response = strings.TrimSpace(strings.TrimPrefix(strings.TrimSuffix(response, "```"), "```yaml"))
+func parseStep1FileInfos(ctx context.Context, client LLMClient, prompts *prompt.Set, response string) ([]*entity.Step1FileInfo, error) {
 	var fileInfos []*entity.Step1FileInfo
-	if err := yaml.Unmarshal([]byte(response), &fileInfos); err != nil {
-		fmt.Println("Error parsing Step1FileInfo YAML:", err)
-		fmt.Println(response)
+	response, err := unmarshalLLMYAML(ctx, client, prompts, response, step1Shape, &fileInfos)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to parse the files related to the question", "error", err)
+		slog.DebugContext(ctx, "Unparsed response", "response", response)
 		return nil, err
 	}
 	return fileInfos, nil
 }
 
-// logFileInfo 记录文件信息
-func logFileInfo(client Logger, fileInfos []*entity.Step1FileInfo) {
-	client.LogDetail("----------问题关联的文件列表-------------")
-	fmt.Println("----------问题关联的文件列表-------------")
+// logFileInfo 记录问题关联的文件
+func logFileInfo(ctx context.Context, fileInfos []*entity.Step1FileInfo) {
 	for _, info := range fileInfos {
-		client.LogDetail(info.File)
-		client.LogDetail(info.Why)
-		fmt.Println(info.File, info.Why)
+		slog.InfoContext(ctx, "File related to the question", "file", info.File, "why", info.Why)
 	}
 }
 
 // analyzeFile 分析指定文件的内容
-func analyzeFile(client LLMClient, logger Logger, question string, fileInfo *entity.Step1FileInfo) error {
+func analyzeFile(ctx context.Context, client LLMClient, prompts *prompt.Set, question string, fileInfo *entity.Step1FileInfo) error {
 	fileContent, err := os.ReadFile(fileInfo.File)
 	if err != nil {
 		return err
 	}
 
-	prompt := buildQuestionRelFilesParsePrompt(question, "", fileInfo.File, string(fileContent))
-	response, err := client.GetResponse(prompt)
+	response, err := askLLM(WithFile(ctx, fileInfo.File), client, prompts, prompt.QuestionRelFilesParse, prompt.Data{
+		Question: question,
+		Filename: fileInfo.File,
+		Code:     string(fileContent),
+	})
 	if err != nil {
 		return err
 	}
 
-	logger.LogDetail("######## 局部答案分析结果 ########")
-	logger.LogDetail(fileInfo.File)
-	logger.LogDetail(response)
 	fileInfo.ParseResult = response
-	fmt.Println("分析", fileInfo.File, "完成")
+	slog.InfoContext(ctx, "Analyzed file for the question", "file", fileInfo.File)
 	return nil
 }
 
 // summarizeFinalAnswer 总结最终答案
-func summarizeFinalAnswer(client LLMClient, question, helpInfo string, fileInfos []*entity.Step1FileInfo) ([]string, error) {
-	answerPromptBuilder := buildFinalAnswerPrompt(question, helpInfo)
-	for _, info := range fileInfos {
-		answerPromptBuilder.WriteString(info.ParseResult)
-	}
-
-	fmt.Println("开始总结答案")
-	response, err := client.GetResponse(answerPromptBuilder.String())
+func summarizeFinalAnswer(ctx context.Context, client LLMClient, prompts *prompt.Set, question, helpInfo string, fileInfos []*entity.Step1FileInfo) ([]string, error) {
+	slog.InfoContext(ctx, "Summarizing the answer")
+	response, err := askLLM(ctx, client, prompts, prompt.FinalAnswer, prompt.Data{
+		Question: question,
+		HelpInfo: helpInfo,
+		Files:    fileInfos,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Println(response)
-	return nil, nil
-}
-
-func (uc *aiCodeUseCase) UploadCodeInfo(ctx context.Context, data entity.AICodeSnippet) error {
-	info, err := uc.apiClient.UploadCodeInfo(ctx, data)
-	if err != nil {
-		return err
-	}
-	fmt.Println(info)
-	return nil
+	return []string{response}, nil
 }
@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codetest/internal/usecase/prompt"
+)
+
+var update = flag.Bool("update", false, "re-record the golden LLM files from the scripted responses")
+
+// llmForTest 返回测试使用的 LLM 客户端。
+// 使用 -update 时用脚本化的回复重新录制 golden 文件，否则从 golden 文件回放；
+// 提示词模板发生变化时回放会因为找不到记录而失败，需要审查变化后重新录制。
+func llmForTest(t *testing.T, scripted []string) LLMClient {
+	t.Helper()
+	golden := filepath.Join("testdata", "golden", strings.ReplaceAll(t.Name(), "/", "_")+".yaml")
+	if *update {
+		recorder := NewRecordingClient(&stubLLMClient{responses: scripted})
+		t.Cleanup(func() {
+			if err := recorder.Save(golden); err != nil {
+				t.Errorf("save golden file: %v", err)
+			}
+		})
+		return recorder
+	}
+
+	client, err := NewReplayClient(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to record it)", err)
+	}
+	return client
+}
+
+const storeAnalysis = "```yaml\n" + `file_description: |
+  实现线程安全的内存存储，导出 Store、NewStore、ErrNotFound 和 MaxItems。
+file_info:
+  file_name: store.go
+  package_name: store
+  imports:
+    - errors
+    - sync
+constants:
+  - name: MaxItems
+    value: 1024
+    description: 存储的最大记录数
+structs:
+  - name: Store
+    fields:
+      - 'mutex: sync.Mutex'
+      - 'items: map[string]string'
+    methods:
+      - name: Get
+        params:
+          - key string
+        return_values:
+          - string
+          - error
+        description: 读取记录
+      - name: Put
+        params:
+          - key string
+          - value string
+        description: 写入记录
+methods:
+  - name: NewStore
+    return_values:
+      - '*Store'
+    description: 创建新的 Store
+` + "```"
+
+func TestAIAnalysisCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		scripted    []string
+		wantPackage string
+		wantImports []string
+		wantErr     bool
+	}{
+		{
+			name:        "clean_yaml",
+			scripted:    []string{storeAnalysis},
+			wantPackage: "store",
+			wantImports: []string{"errors", "sync"},
+		},
+		{
+			name: "local_repair",
+			scripted: []string{"分析结果如下：\n```yaml\nfile_description: 内存存储: 支持并发读写\nfile_info:\n" +
+				"\tfile_name: store.go\n\tpackage_name: store\n\timports:\n\t- errors\n\t- sync\n```"},
+			wantPackage: "store",
+			wantImports: []string{"errors", "sync"},
+		},
+		{
+			name:        "llm_repair",
+			scripted:    []string{"file_info: {package_name: store, imports: [errors", storeAnalysis},
+			wantPackage: "store",
+			wantImports: []string{"errors", "sync"},
+		},
+		{
+			name:     "unrepairable",
+			scripted: []string{"file_info: [", "还是无法修复: [", "仍然无法修复: ["},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewAiCode(llmForTest(t, tt.scripted), prompt.Default())
+			response, parsed, err := uc.AIAnalysisCode(context.Background(), "testdata/pipeline/store.go", readSource(t, "store.go"))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AIAnalysisCode: %v", err)
+			}
+			if parsed.FileInfo.PackageName != tt.wantPackage {
+				t.Errorf("package_name = %q, want %q", parsed.FileInfo.PackageName, tt.wantPackage)
+			}
+			if strings.Join(parsed.FileInfo.Imports, ",") != strings.Join(tt.wantImports, ",") {
+				t.Errorf("imports = %v, want %v", parsed.FileInfo.Imports, tt.wantImports)
+			}
+			if strings.Contains(response, "```") {
+				t.Errorf("saved response still contains the code fence: %q", response)
+			}
+		})
+	}
+}
+
+func TestAIQuestion(t *testing.T) {
+	tests := []struct {
+		name       string
+		scripted   []string
+		wantAnswer string
+		wantErr    bool
+	}{
+		{
+			name: "two_files",
+			scripted: []string{
+				"```yaml\n- file: testdata/pipeline/store.go\n  why: 实现存储\n- file: testdata/pipeline/handler.go\n  why: 'HTTP 入口: 调用 Store.Get'\n```",
+				"Store.Get 加锁后读取 map，不存在时返回 ErrNotFound。",
+				"Handler.ServeHTTP 从 query 中读取 key 并调用 Store.Get。",
+				"ServeHTTP -> Store.Get -> map 查找",
+			},
+			wantAnswer: "ServeHTTP -> Store.Get -> map 查找",
+		},
+		{
+			name:     "missing_file",
+			scripted: []string{"- file: testdata/pipeline/missing.go\n  why: 不存在的文件"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewAiCode(llmForTest(t, tt.scripted), prompt.Default())
+			answer, err := uc.AIQuestion(context.Background(), "store.go: 内存存储\nhandler.go: HTTP 入口", "读取记录的调用链是什么？", "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", answer)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AIQuestion: %v", err)
+			}
+			if len(answer) != 1 || answer[0] != tt.wantAnswer {
+				t.Errorf("answer = %q, want %q", answer, tt.wantAnswer)
+			}
+		})
+	}
+}
+
+func TestEvaluateAnalysis(t *testing.T) {
+	cases, err := LoadEvalCases(filepath.Join("testdata", "eval", "cases.yaml"))
+	if err != nil {
+		t.Fatalf("LoadEvalCases: %v", err)
+	}
+
+	handlerAnalysis := "file_description: 通过 HTTP 暴露 Store\nfile_info:\n  package_name: store\n  imports:\n    - fmt\n" +
+		"structs:\n  - name: Handler\n    methods:\n      - name: ServeHTTP\n"
+	uc := NewAiCode(llmForTest(t, []string{storeAnalysis, handlerAnalysis}), prompt.Default())
+	results := EvaluateAnalysis(context.Background(), uc, uc.PromptVersion(prompt.FileAnalysis), cases)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	store, handler := results[0], results[1]
+	if store.Err != nil || store.Score != 1 {
+		t.Errorf("store result = %+v, want a perfect score", store)
+	}
+	if handler.Err != nil || !handler.PackageOK {
+		t.Fatalf("handler result = %+v", handler)
+	}
+	if strings.Join(handler.MissingImports, ",") != "net/http" || strings.Join(handler.MissingSymbols, ",") != "NewHandler" {
+		t.Errorf("missing imports = %v, missing symbols = %v", handler.MissingImports, handler.MissingSymbols)
+	}
+	if handler.Score >= 1 {
+		t.Errorf("handler score = %v, want < 1", handler.Score)
+	}
+}
+
+func readSource(t *testing.T, name string) string {
+	t.Helper()
+	return readFile(t, filepath.Join("testdata", "pipeline", name))
+}
@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"codetest/internal/entity"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CheckpointFileName analyze 的进度保存在输出目录下的这个文件中，运行完成后删除
+const CheckpointFileName = "checkpoint.jsonl"
+
+// CheckpointConfig 影响分析结果的运行参数，--resume 时必须与保存的一致
+type CheckpointConfig struct {
+	Dir            string   `json:"dir"`
+	ProjectName    string   `json:"project_name"`
+	Model          string   `json:"model"`
+	PromptVersion  string   `json:"prompt_version"`
+	OutputLanguage string   `json:"output_language"`
+	Sinks          []string `json:"sinks"`
+	RetryFailed    bool     `json:"retry_failed,omitempty"`
+	Since          string   `json:"since,omitempty"`
+	DiffRange      string   `json:"diff,omitempty"`
+	Commit         string   `json:"commit,omitempty"` // 分析的提交，HEAD 变化后不能继续
+}
+
+// Diff 返回与 other 不同的参数名称，相同时返回空
+func (c CheckpointConfig) Diff(other CheckpointConfig) []string {
+	var diff []string
+	for name, same := range map[string]bool{
+		"dir":             c.Dir == other.Dir,
+		"project_name":    c.ProjectName == other.ProjectName,
+		"model":           c.Model == other.Model,
+		"prompt_version":  c.PromptVersion == other.PromptVersion,
+		"output_language": c.OutputLanguage == other.OutputLanguage,
+		"sinks":           slices.Equal(c.Sinks, other.Sinks),
+		"retry_failed":    c.RetryFailed == other.RetryFailed,
+		"since":           c.Since == other.Since,
+		"diff":            c.DiffRange == other.DiffRange,
+		"commit":          c.Commit == other.Commit,
+	} {
+		if !same {
+			diff = append(diff, name)
+		}
+	}
+	slices.Sort(diff)
+	return diff
+}
+
+// CheckpointEntry 一个已处理完的文件，成功和使用缓存的文件保存分析结果，汇总时不需要重新分析
+type CheckpointEntry struct {
+	Path   string            `json:"path"`
+	Status string            `json:"status"`
+	Error  string            `json:"error,omitempty"`
+	Raw    string            `json:"raw,omitempty"`
+	Meta   entity.ResultMeta `json:"meta"`
+}
+
+// Result 返回保存的分析结果
+func (e CheckpointEntry) Result(projectName string) (entity.FileResult, error) {
+	result := entity.FileResult{ProjectName: projectName, Path: e.Path, Raw: e.Raw, Meta: e.Meta}
+	if err := yaml.Unmarshal([]byte(e.Raw), &result.Parsed); err != nil {
+		return result, fmt.Errorf("failed to parse checkpointed result of %s: %v", e.Path, err)
+	}
+	return result, nil
+}
+
+// checkpointHeader checkpoint 的第一行
+type checkpointHeader struct {
+	RunID     string           `json:"run_id"`
+	CreatedAt time.Time        `json:"created_at"`
+	Config    CheckpointConfig `json:"config"`
+	Files     []string         `json:"files"`
+}
+
+// Checkpoint 一次 analyze 的进度：第一行为运行参数和要处理的全部文件，之后每处理完一个文件追加一行。
+// 只追加写入，进程在写入时退出最多丢失最后一行。
+type Checkpoint struct {
+	RunID     string
+	CreatedAt time.Time
+	Config    CheckpointConfig
+	Files     []string
+
+	path      string
+	mutex     sync.Mutex
+	file      *os.File
+	completed map[string]CheckpointEntry
+}
+
+// CheckpointExists 判断 dir 中是否有未完成的运行
+func CheckpointExists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, CheckpointFileName))
+	return err == nil
+}
+
+// CreateCheckpoint 在 dir 中创建新的 checkpoint，覆盖之前的
+func CreateCheckpoint(dir, runID string, config CheckpointConfig, files []string) (*Checkpoint, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
+	}
+	path := filepath.Join(dir, CheckpointFileName)
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint: %v", err)
+	}
+	c := &Checkpoint{
+		RunID:     runID,
+		CreatedAt: time.Now().UTC(),
+		Config:    config,
+		Files:     files,
+		path:      path,
+		file:      file,
+		completed: map[string]CheckpointEntry{},
+	}
+	if err := c.append(checkpointHeader{RunID: c.RunID, CreatedAt: c.CreatedAt, Config: config, Files: files}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// OpenCheckpoint 读取 dir 中的 checkpoint 并打开以继续追加
+func OpenCheckpoint(dir string) (*Checkpoint, error) {
+	path := filepath.Join(dir, CheckpointFileName)
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no unfinished run in %s to resume: %w", dir, err)
+		}
+		return nil, fmt.Errorf("failed to open checkpoint: %v", err)
+	}
+
+	c := &Checkpoint{path: path, file: file, completed: map[string]CheckpointEntry{}}
+	reader := bufio.NewReader(file)
+	var offset int64 // 最后一个完整行之后的位置
+	for line := 0; ; line++ {
+		text, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			file.Close()
+			return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+		}
+		// 没有换行的最后一行是写入时中断留下的，丢弃
+		if !strings.HasSuffix(text, "\n") {
+			break
+		}
+		if line == 0 {
+			var header checkpointHeader
+			if err := json.Unmarshal([]byte(text), &header); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("invalid checkpoint header in %s: %v", path, err)
+			}
+			c.RunID, c.CreatedAt, c.Config, c.Files = header.RunID, header.CreatedAt, header.Config, header.Files
+		} else {
+			var entry CheckpointEntry
+			if err := json.Unmarshal([]byte(text), &entry); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("invalid checkpoint entry at %s:%d: %v", path, line+1, err)
+			}
+			c.completed[entry.Path] = entry
+		}
+		offset += int64(len(text))
+	}
+	if offset == 0 {
+		file.Close()
+		return nil, fmt.Errorf("checkpoint %s is empty", path)
+	}
+	// 截掉中断的最后一行，之后从这里继续追加
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to repair checkpoint: %v", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open checkpoint: %v", err)
+	}
+	return c, nil
+}
+
+// Completed 返回已处理完的文件，按 Files 的顺序
+func (c *Checkpoint) Completed() []CheckpointEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var entries []CheckpointEntry
+	for _, path := range c.Files {
+		if entry, ok := c.completed[path]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Pending 返回还没有处理完的文件，按 Files 的顺序
+func (c *Checkpoint) Pending() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var pending []string
+	for _, path := range c.Files {
+		if _, ok := c.completed[path]; !ok {
+			pending = append(pending, path)
+		}
+	}
+	return pending
+}
+
+// Record 记录一个已处理完的文件并立即写入磁盘
+func (c *Checkpoint) Record(entry CheckpointEntry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.append(entry); err != nil {
+		return err
+	}
+	c.completed[entry.Path] = entry
+	return nil
+}
+
+// append 写入一行并同步到磁盘，调用方持有锁或 c 还没有共享
+func (c *Checkpoint) append(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %v", err)
+	}
+	if _, err := c.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	if err := c.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync checkpoint: %v", err)
+	}
+	return nil
+}
+
+// Close 关闭 checkpoint 文件，保留其中的进度
+func (c *Checkpoint) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.file.Close()
+}
+
+// Remove 关闭并删除 checkpoint，在运行完成后调用
+func (c *Checkpoint) Remove() error {
+	c.Close()
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %v", err)
+	}
+	return nil
+}
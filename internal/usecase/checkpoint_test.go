@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	config := CheckpointConfig{Dir: "src", Model: "gpt-4o", PromptVersion: "v1", Sinks: []string{"local"}}
+	files := []string{"a.go", "b.go", "c.go"}
+	checkpoint, err := CreateCheckpoint(dir, "run1", config, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.Record(CheckpointEntry{Path: "b.go", Status: FileSucceeded, Raw: "file_description: b\n", Meta: entity.ResultMeta{Model: "gpt-4o"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.Record(CheckpointEntry{Path: "a.go", Status: FileFailed, Error: "timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Close()
+
+	// 模拟写入最后一行时进程退出
+	file, err := os.OpenFile(filepath.Join(dir, CheckpointFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"path":"c.go","sta`)
+	file.Close()
+
+	if !CheckpointExists(dir) {
+		t.Fatal("checkpoint must exist")
+	}
+	resumed, err := OpenCheckpoint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.RunID != "run1" || len(resumed.Config.Diff(config)) != 0 || !slices.Equal(resumed.Files, files) {
+		t.Errorf("header = %s %+v %v", resumed.RunID, resumed.Config, resumed.Files)
+	}
+	if pending := resumed.Pending(); !slices.Equal(pending, []string{"c.go"}) {
+		t.Errorf("pending = %v", pending)
+	}
+	completed := resumed.Completed()
+	if len(completed) != 2 || completed[0].Path != "a.go" || completed[0].Error != "timeout" {
+		t.Fatalf("completed = %+v", completed)
+	}
+	result, err := completed[1].Result("demo")
+	if err != nil || result.Parsed.FileDescription != "b" || result.ProjectName != "demo" || result.Meta.Model != "gpt-4o" {
+		t.Errorf("result = %+v, %v", result, err)
+	}
+
+	// 截掉中断的行后可以继续追加
+	if err := resumed.Record(CheckpointEntry{Path: "c.go", Status: FileCached, Raw: "file_description: c\n"}); err != nil {
+		t.Fatal(err)
+	}
+	resumed.Close()
+	again, err := OpenCheckpoint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := again.Pending(); len(pending) != 0 {
+		t.Errorf("pending = %v", pending)
+	}
+	if err := again.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if CheckpointExists(dir) {
+		t.Error("checkpoint must be removed")
+	}
+	if _, err := OpenCheckpoint(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want not exist", err)
+	}
+}
+
+func TestCheckpointConfigDiff(t *testing.T) {
+	config := CheckpointConfig{Dir: "src", Model: "gpt-4o", PromptVersion: "v1", Sinks: []string{"local"}}
+	other := config
+	other.Model, other.Sinks = "qwen", []string{"local", "sqlite"}
+	if diff := config.Diff(other); !slices.Equal(diff, []string{"model", "sinks"}) {
+		t.Errorf("diff = %v", diff)
+	}
+}
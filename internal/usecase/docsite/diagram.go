@@ -0,0 +1,183 @@
+package docsite
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SVG 依赖图的布局参数（像素）
+const (
+	nodeHeight   = 28
+	nodePadding  = 10 // 文字两侧的留白
+	charWidth    = 7  // 一个 ASCII 字符的大致宽度，中日韩字符按两个计算
+	layerGap     = 60 // 相邻两层之间的水平距离
+	nodeGap      = 14 // 同一层相邻节点之间的垂直距离
+	diagramInset = 10
+)
+
+// depGraph 包依赖图，边从引用方指向被引用的包
+type depGraph struct {
+	nodes     []*Package
+	edges     [][2]*Package
+	highlight *Package // 不为空时突出显示该包
+}
+
+// overviewGraph 首页的包依赖图，包太多或没有依赖时返回 nil
+func (s *Site) overviewGraph() *depGraph {
+	if len(s.Packages) < 2 || len(s.Packages) > maxOverviewNodes {
+		return nil
+	}
+	g := &depGraph{nodes: s.Packages}
+	for _, pkg := range s.Packages {
+		for _, target := range pkg.Imports {
+			g.edges = append(g.edges, [2]*Package{pkg, target})
+		}
+	}
+	if len(g.edges) == 0 {
+		return nil
+	}
+	return g
+}
+
+// packageGraph 包页面的局部依赖图：引用这个包的包 -> 这个包 -> 它引用的包，没有依赖时返回 nil
+func packageGraph(pkg *Package) *depGraph {
+	if len(pkg.Imports) == 0 && len(pkg.Importers) == 0 {
+		return nil
+	}
+	g := &depGraph{highlight: pkg}
+	seen := map[*Package]bool{}
+	for _, node := range append(append([]*Package{pkg}, pkg.Importers...), pkg.Imports...) {
+		if !seen[node] {
+			seen[node] = true
+			g.nodes = append(g.nodes, node)
+		}
+	}
+	for _, importer := range pkg.Importers {
+		g.edges = append(g.edges, [2]*Package{importer, pkg})
+	}
+	for _, target := range pkg.Imports {
+		g.edges = append(g.edges, [2]*Package{pkg, target})
+	}
+	return g
+}
+
+// mermaid 生成 Mermaid flowchart，供 Markdown 站点使用
+func (g *depGraph) mermaid() string {
+	ids := map[*Package]string{}
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for _, pkg := range g.nodes {
+		ids[pkg] = fmt.Sprintf("p%d", len(ids))
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[pkg], strings.ReplaceAll(pkg.Dir, `"`, "'"))
+	}
+	for _, edge := range g.edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", ids[edge[0]], ids[edge[1]])
+	}
+	if g.highlight != nil {
+		fmt.Fprintf(&b, "  style %s stroke-width:3px\n", ids[g.highlight])
+	}
+	return b.String()
+}
+
+// box SVG 中一个节点的位置
+type box struct {
+	x, y, width int
+}
+
+// svg 按层从左到右排列节点生成 SVG：引用方在左，被引用的包在右，每个节点链接到包页面。
+// from 为嵌入 SVG 的页面路径，用于生成相对链接。
+func (g *depGraph) svg(from, ext string) string {
+	layers := g.layers()
+	boxes := map[*Package]box{}
+	x, height := diagramInset, 0
+	for _, layer := range layers {
+		width := 0
+		for _, pkg := range layer {
+			width = max(width, labelWidth(pkg.Dir))
+		}
+		y := diagramInset
+		for _, pkg := range layer {
+			boxes[pkg] = box{x: x, y: y, width: width}
+			y += nodeHeight + nodeGap
+		}
+		height = max(height, y-nodeGap+diagramInset)
+		x += width + layerGap
+	}
+	width := x - layerGap + diagramInset
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" class="diagram" width="%d" height="%d" viewBox="0 0 %d %d" role="img">`+"\n", width, height, width, height)
+	b.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M0,0 L10,5 L0,10 z"/></marker></defs>` + "\n")
+	for _, edge := range g.edges {
+		from, to := boxes[edge[0]], boxes[edge[1]]
+		x1, y1 := from.x+from.width, from.y+nodeHeight/2
+		x2, y2 := to.x, to.y+nodeHeight/2
+		mid := (x1 + x2) / 2
+		fmt.Fprintf(&b, `<path class="edge" d="M%d,%d C%d,%d %d,%d %d,%d" marker-end="url(#arrow)"/>`+"\n", x1, y1, mid, y1, mid, y2, x2, y2)
+	}
+	for _, pkg := range g.nodes {
+		node := boxes[pkg]
+		class := "node"
+		if pkg == g.highlight {
+			class += " highlight"
+		}
+		link, err := filepath.Rel(path.Dir(from), packagePage(pkg, ext))
+		if err != nil {
+			link = packagePage(pkg, ext)
+		}
+		fmt.Fprintf(&b, `<a href="%s"><rect class="%s" x="%d" y="%d" width="%d" height="%d" rx="4"/><text x="%d" y="%d">%s</text></a>`+"\n",
+			html.EscapeString(filepath.ToSlash(link)), class, node.x, node.y, node.width, nodeHeight,
+			node.x+node.width/2, node.y+nodeHeight/2, html.EscapeString(pkg.Dir))
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// layers 把节点分层：每个包位于所有引用方的右侧（最长路径分层），同一层按路径排序。
+// Go 的包不会循环引用，出现环时最多调整 len(nodes) 轮。
+func (g *depGraph) layers() [][]*Package {
+	layer := map[*Package]int{}
+	for _, pkg := range g.nodes {
+		layer[pkg] = 0
+	}
+	for round := 0; round < len(g.nodes); round++ {
+		changed := false
+		for _, edge := range g.edges {
+			if layer[edge[1]] <= layer[edge[0]] {
+				layer[edge[1]] = layer[edge[0]] + 1
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var layers [][]*Package
+	for _, pkg := range g.nodes {
+		for len(layers) <= layer[pkg] {
+			layers = append(layers, nil)
+		}
+		layers[layer[pkg]] = append(layers[layer[pkg]], pkg)
+	}
+	for _, nodes := range layers {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Dir < nodes[j].Dir })
+	}
+	return layers
+}
+
+// labelWidth 节点的宽度，中日韩等宽字符按两个 ASCII 字符计算
+func labelWidth(label string) int {
+	units := 0
+	for _, r := range label {
+		units++
+		if r >= 0x2E80 {
+			units++
+		}
+	}
+	return units*charWidth + 2*nodePadding
+}
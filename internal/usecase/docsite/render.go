@@ -0,0 +1,215 @@
+package docsite
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// 站点格式
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+)
+
+// maxOverviewNodes 首页依赖图最多包含的包数，超过时只在包页面中显示局部依赖图
+const maxOverviewNodes = 60
+
+//go:embed templates
+var templates embed.FS
+
+// page 渲染一个页面所需的数据
+type page struct {
+	Site    *Site
+	Path    string // 页面相对站点根目录的路径
+	Ext     string // 页面扩展名，用于生成链接
+	Title   string
+	Package *Package
+	File    *File
+	Diagram string            // Markdown 站点的 Mermaid 依赖图，为空时不显示
+	SVG     htmltemplate.HTML // HTML 站点的 SVG 依赖图，生成站点时渲染，不需要联网加载脚本
+}
+
+// namedPage 页面及渲染它的模板名
+type namedPage struct {
+	name string
+	page page
+}
+
+// SearchEntry 搜索索引中的一项
+type SearchEntry struct {
+	Title string `json:"title"`
+	Kind  string `json:"kind"` // package、file 或符号种类
+	URL   string `json:"url"`  // 相对站点根目录的链接
+	Text  string `json:"text"`
+}
+
+// Write 按 format 把站点写入 dir
+func (s *Site) Write(dir, format string) error {
+	switch format {
+	case FormatHTML:
+		return s.WriteHTML(dir)
+	case FormatMarkdown:
+		return s.WriteMarkdown(dir)
+	default:
+		return fmt.Errorf("unknown site format %q, expected %s or %s", format, FormatHTML, FormatMarkdown)
+	}
+}
+
+// WriteHTML 生成 HTML 站点，可以直接用浏览器打开 index.html
+func (s *Site) WriteHTML(dir string) error {
+	tmpl, err := htmltemplate.New("html.tmpl").Funcs(htmltemplate.FuncMap(s.funcs())).ParseFS(templates, "templates/html.tmpl")
+	if err != nil {
+		return fmt.Errorf("failed to parse html templates: %v", err)
+	}
+	if err := s.writePages(dir, "html", tmpl.ExecuteTemplate); err != nil {
+		return err
+	}
+	for _, asset := range []string{"style.css", "search.js"} {
+		data, err := templates.ReadFile("templates/" + asset)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, asset), data); err != nil {
+			return err
+		}
+	}
+	return s.writeSearchIndex(dir, "html")
+}
+
+// WriteMarkdown 生成 Markdown 站点，依赖图使用 mermaid 代码块
+func (s *Site) WriteMarkdown(dir string) error {
+	tmpl, err := template.New("markdown.tmpl").Funcs(s.funcs()).ParseFS(templates, "templates/markdown.tmpl")
+	if err != nil {
+		return fmt.Errorf("failed to parse markdown templates: %v", err)
+	}
+	if err := s.writePages(dir, "md", tmpl.ExecuteTemplate); err != nil {
+		return err
+	}
+	return s.writeSearchIndex(dir, "md")
+}
+
+// writePages 渲染首页、包页面和文件页面
+func (s *Site) writePages(dir, ext string, execute func(io.Writer, string, any) error) error {
+	pages := []namedPage{{"index", page{Site: s, Path: "index." + ext, Title: s.Project}}}
+	graphs := []*depGraph{s.overviewGraph()}
+	for _, pkg := range s.Packages {
+		pages = append(pages, namedPage{"package", page{Site: s, Path: packagePage(pkg, ext), Title: pkg.Dir, Package: pkg}})
+		graphs = append(graphs, packageGraph(pkg))
+	}
+	for _, file := range s.Files {
+		pages = append(pages, namedPage{"file", page{Site: s, Path: filePage(file, ext), Title: file.Path, Package: file.Package, File: file}})
+	}
+
+	for i, p := range pages {
+		p.page.Ext = ext
+		if i < len(graphs) && graphs[i] != nil {
+			if ext == "html" {
+				p.page.SVG = htmltemplate.HTML(graphs[i].svg(p.page.Path, ext))
+			} else {
+				p.page.Diagram = graphs[i].mermaid()
+			}
+		}
+		var buf bytes.Buffer
+		if err := execute(&buf, p.name, p.page); err != nil {
+			return fmt.Errorf("failed to render %s: %v", p.page.Path, err)
+		}
+		if err := writeFile(filepath.Join(dir, filepath.FromSlash(p.page.Path)), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSearchIndex 写入 search_index.json，HTML 站点另外写入 search_index.js 以便通过 file:// 打开时也能搜索
+func (s *Site) writeSearchIndex(dir, ext string) error {
+	data, err := json.MarshalIndent(s.SearchIndex(ext), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(dir, "search_index.json"), data); err != nil {
+		return err
+	}
+	if ext != "html" {
+		return nil
+	}
+	return writeFile(filepath.Join(dir, "search_index.js"), append([]byte("window.SEARCH_INDEX = "), append(data, ";\n"...)...))
+}
+
+// SearchIndex 返回包、文件和符号的搜索索引，链接使用扩展名 ext
+func (s *Site) SearchIndex(ext string) []SearchEntry {
+	var entries []SearchEntry
+	for _, pkg := range s.Packages {
+		entries = append(entries, SearchEntry{Title: pkg.Dir, Kind: "package", URL: packagePage(pkg, ext), Text: firstLine(pkg.Summary)})
+	}
+	for _, file := range s.Files {
+		url := filePage(file, ext)
+		entries = append(entries, SearchEntry{Title: file.Path, Kind: "file", URL: url, Text: firstLine(file.Description)})
+		for _, sym := range file.Symbols {
+			entries = append(entries, SearchEntry{Title: sym.Name, Kind: sym.Kind, URL: url + "#" + sym.Anchor(), Text: file.Path})
+		}
+	}
+	return entries
+}
+
+// funcs 模板函数，HTML 和 Markdown 模板共用
+func (s *Site) funcs() template.FuncMap {
+	return template.FuncMap{
+		// rel 返回从页面 from 到页面 to 的相对链接
+		"rel": func(from, to string) string {
+			rel, err := filepath.Rel(path.Dir(from), to)
+			if err != nil {
+				return to
+			}
+			return filepath.ToSlash(rel)
+		},
+		// root 返回从页面 from 到站点根目录的相对前缀，例如 "../../"
+		"root": func(from string) string {
+			return strings.Repeat("../", strings.Count(from, "/"))
+		},
+		"list":        func(values ...any) []any { return values },
+		"packagePage": packagePage,
+		"filePage":    filePage,
+		"cell": func(text string) string {
+			return strings.ReplaceAll(strings.Join(strings.Fields(text), " "), "|", `\|`)
+		},
+		"firstLine": firstLine,
+	}
+}
+
+// packagePage 包页面的路径，根目录的包使用 _root
+func packagePage(pkg *Package, ext string) string {
+	dir := pkg.Dir
+	if dir == "." {
+		dir = "_root"
+	}
+	return "packages/" + dir + "/index." + ext
+}
+
+// filePage 文件页面的路径
+func filePage(file *File, ext string) string {
+	return "files/" + file.Path + "." + ext
+}
+
+func firstLine(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+func writeFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0644)
+}
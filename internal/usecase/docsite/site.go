@@ -0,0 +1,222 @@
+// Package docsite 把 analyze 保存的分析结果生成静态文档站点（HTML 或 Markdown）。
+// 站点包含项目概览、每个包和每个文件一个页面、符号表、包之间的引用关系、依赖图和搜索索引。
+package docsite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"codetest/internal/entity"
+	"codetest/internal/pkg/repoinfo"
+	"codetest/internal/usecase/web_api"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ResultStore 读取 analyze 保存的分析结果
+type ResultStore interface {
+	LoadAIResult(path string) (string, entity.ResultMeta, error)
+	LoadProjectSummary() (entity.ProjectSummary, error)
+}
+
+// Site 文档站点的数据
+type Site struct {
+	Project     string
+	ModulePath  string
+	Overview    string
+	Description string
+	Packages    []*Package // 按目录排序
+	Files       []*File    // 按路径排序
+}
+
+// Package 一个包（目录）
+type Package struct {
+	Dir        string // 相对源码目录的路径，根目录为 "."
+	Name       string // Go 包名
+	ImportPath string
+	Summary    string
+	Files      []*File
+	Imports    []*Package // 引用的项目内的包
+	Importers  []*Package // 引用这个包的项目内的包
+	External   []string   // 引用的项目外的包
+}
+
+// File 一个源码文件
+type File struct {
+	Path          string // 相对源码目录的路径
+	Package       *Package
+	Description   string
+	Analysis      string // 保存的分析结果 YAML，没有分析结果时为空
+	PromptVersion string
+	Symbols       []Symbol
+	Imports       []string
+}
+
+// Symbol 文件中声明的符号
+type Symbol struct {
+	Kind      string // struct、method、interface、func、const、var
+	Name      string
+	Signature string
+}
+
+// Anchor 返回符号在文件页面中的锚点
+func (s Symbol) Anchor() string {
+	return "sym-" + strings.NewReplacer(".", "-", " ", "-").Replace(s.Name)
+}
+
+// Load 读取 sourceDir 下的源码文件（paths 为 analyze 时遍历到的路径）及其分析结果，构建站点数据。
+// 文件没有分析结果时仍然会生成页面，只包含符号表。
+func Load(project, sourceDir string, paths []string, store ResultStore) (*Site, error) {
+	site := &Site{Project: project}
+	summary, err := store.LoadProjectSummary()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	site.Overview, site.Description = summary.Overview, summary.Description
+	summaries := map[string]string{}
+	for _, pkg := range summary.Packages {
+		summaries[pkg.Path] = pkg.Summary
+	}
+
+	moduleRoot, modulePath, hasModule := repoinfo.FindGoModule(sourceDir)
+	site.ModulePath = modulePath
+	absSource, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := web_api.NewParser()
+	packages := map[string]*Package{}
+	for _, walked := range paths {
+		rel, err := filepath.Rel(sourceDir, walked)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %v", walked, err)
+		}
+		file := &File{Path: filepath.ToSlash(rel)}
+
+		parsed, err := parser.ParseByFile(walked)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", walked, err)
+		}
+		file.Symbols = symbolsOf(parsed)
+		file.Imports = parsed.Imports
+
+		if raw, meta, err := store.LoadAIResult(walked); err == nil {
+			file.Analysis, file.PromptVersion = raw, meta.PromptVersion
+			var analysis entity.ParsedYAML
+			if err := yaml.Unmarshal([]byte(raw), &analysis); err == nil {
+				file.Description = strings.TrimSpace(analysis.FileDescription)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to load analysis of %s: %v", walked, err)
+		}
+
+		dir := path.Dir(file.Path)
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = &Package{Dir: dir, Name: parsed.PackageName, Summary: summaries[filepath.Dir(walked)]}
+			if hasModule {
+				if modRel, err := filepath.Rel(moduleRoot, filepath.Join(absSource, dir)); err == nil && !strings.HasPrefix(modRel, "..") {
+					pkg.ImportPath = path.Join(modulePath, filepath.ToSlash(modRel))
+				}
+			}
+			packages[dir] = pkg
+			site.Packages = append(site.Packages, pkg)
+		}
+		file.Package = pkg
+		pkg.Files = append(pkg.Files, file)
+		site.Files = append(site.Files, file)
+	}
+
+	sort.Slice(site.Packages, func(i, j int) bool { return site.Packages[i].Dir < site.Packages[j].Dir })
+	sort.Slice(site.Files, func(i, j int) bool { return site.Files[i].Path < site.Files[j].Path })
+	site.linkPackages()
+	return site, nil
+}
+
+// linkPackages 根据文件的 import 建立包之间的引用关系
+func (s *Site) linkPackages() {
+	byImportPath := map[string]*Package{}
+	for _, pkg := range s.Packages {
+		if pkg.ImportPath != "" {
+			byImportPath[pkg.ImportPath] = pkg
+		}
+	}
+
+	for _, pkg := range s.Packages {
+		sort.Slice(pkg.Files, func(i, j int) bool { return pkg.Files[i].Path < pkg.Files[j].Path })
+		internal := map[*Package]bool{}
+		external := map[string]bool{}
+		for _, file := range pkg.Files {
+			for _, importPath := range file.Imports {
+				if target, ok := byImportPath[importPath]; ok && target != pkg {
+					internal[target] = true
+				} else if !ok {
+					external[importPath] = true
+				}
+			}
+		}
+		for target := range internal {
+			pkg.Imports = append(pkg.Imports, target)
+			target.Importers = append(target.Importers, pkg)
+		}
+		for importPath := range external {
+			pkg.External = append(pkg.External, importPath)
+		}
+		sort.Strings(pkg.External)
+	}
+	for _, pkg := range s.Packages {
+		sortPackages(pkg.Imports)
+		sortPackages(pkg.Importers)
+	}
+}
+
+func sortPackages(packages []*Package) {
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Dir < packages[j].Dir })
+}
+
+// symbolsOf 把解析结果转换为按种类和名称排序的符号列表
+func symbolsOf(result *web_api.ParseResult) []Symbol {
+	var symbols []Symbol
+	for name, info := range result.Structs {
+		symbols = append(symbols, Symbol{Kind: "struct", Name: name, Signature: strings.Join(info.Fields, "; ")})
+		for _, method := range info.Methods {
+			symbols = append(symbols, Symbol{Kind: "method", Name: name + "." + declName(method), Signature: method})
+		}
+	}
+	for name, methods := range result.Interfaces {
+		symbols = append(symbols, Symbol{Kind: "interface", Name: name, Signature: strings.Join(methods, "; ")})
+	}
+	for _, fn := range result.ExportedFunc {
+		symbols = append(symbols, Symbol{Kind: "func", Name: declName(fn), Signature: fn})
+	}
+	for _, constant := range result.Constants {
+		symbols = append(symbols, Symbol{Kind: "const", Name: declName(constant), Signature: constant})
+	}
+	for _, v := range result.ExportedVar {
+		symbols = append(symbols, Symbol{Kind: "var", Name: declName(v), Signature: v})
+	}
+
+	order := map[string]int{"interface": 0, "struct": 1, "method": 1, "func": 2, "const": 3, "var": 4}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if order[symbols[i].Kind] != order[symbols[j].Kind] {
+			return order[symbols[i].Kind] < order[symbols[j].Kind]
+		}
+		return symbols[i].Name < symbols[j].Name
+	})
+	return symbols
+}
+
+// declName 从 "Name(params) (results)" 或 "Name = value" 中取出名称
+func declName(decl string) string {
+	fields := strings.FieldsFunc(decl, func(r rune) bool { return r == '(' || r == '=' || r == ' ' })
+	if len(fields) == 0 {
+		return decl
+	}
+	return fields[0]
+}
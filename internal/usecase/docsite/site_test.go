@@ -0,0 +1,206 @@
+package docsite
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+// fakeStore 按文件路径返回固定的分析结果
+type fakeStore struct {
+	results map[string]string
+	summary entity.ProjectSummary
+}
+
+func (s *fakeStore) LoadAIResult(path string) (string, entity.ResultMeta, error) {
+	raw, ok := s.results[path]
+	if !ok {
+		return "", entity.ResultMeta{}, os.ErrNotExist
+	}
+	return raw, entity.ResultMeta{PromptVersion: "v1"}, nil
+}
+
+func (s *fakeStore) LoadProjectSummary() (entity.ProjectSummary, error) {
+	return s.summary, nil
+}
+
+func writeSource(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func loadTestSite(t *testing.T) *Site {
+	t.Helper()
+	dir := t.TempDir()
+	writeSource(t, dir, "go.mod", "module example.com/demo\n\ngo 1.22\n")
+	mainFile := writeSource(t, dir, "main.go", `package main
+
+import (
+	"fmt"
+
+	"example.com/demo/store"
+)
+
+func main() { fmt.Println(store.New()) }
+`)
+	storeFile := writeSource(t, dir, "store/store.go", `package store
+
+// Store 存储
+type Store struct {
+	Items []string
+}
+
+// Add 添加
+func (s *Store) Add(item string) { s.Items = append(s.Items, item) }
+
+// New 创建 Store
+func New() *Store { return &Store{} }
+`)
+
+	store := &fakeStore{
+		results: map[string]string{storeFile: "file_description: 内存存储\npackage_name: store\n"},
+		summary: entity.ProjectSummary{
+			Name:     "demo",
+			Overview: "演示项目",
+			Packages: []entity.PackageSummary{{Path: filepath.Join(dir, "store"), Summary: "存储相关"}},
+		},
+	}
+	site, err := Load("demo", dir, []string{mainFile, storeFile}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return site
+}
+
+func TestLoad(t *testing.T) {
+	site := loadTestSite(t)
+
+	if site.ModulePath != "example.com/demo" || site.Overview != "演示项目" {
+		t.Fatalf("site = %+v", site)
+	}
+	if len(site.Packages) != 2 || site.Packages[0].Dir != "." || site.Packages[1].Dir != "store" {
+		t.Fatalf("packages = %+v", site.Packages)
+	}
+	root, store := site.Packages[0], site.Packages[1]
+	if store.ImportPath != "example.com/demo/store" || store.Summary != "存储相关" {
+		t.Errorf("store package = %+v", store)
+	}
+	if len(root.Imports) != 1 || root.Imports[0] != store || len(store.Importers) != 1 || store.Importers[0] != root {
+		t.Errorf("root imports %v, store importers %v", root.Imports, store.Importers)
+	}
+	if len(root.External) != 1 || root.External[0] != "fmt" {
+		t.Errorf("external = %v", root.External)
+	}
+
+	file := store.Files[0]
+	if file.Description != "内存存储" || file.PromptVersion != "v1" {
+		t.Errorf("file = %+v", file)
+	}
+	var names []string
+	for _, sym := range file.Symbols {
+		names = append(names, sym.Kind+" "+sym.Name)
+	}
+	if got := strings.Join(names, ", "); got != "struct Store, method Store.Add, func New" {
+		t.Errorf("symbols = %s", got)
+	}
+}
+
+func TestWriteSite(t *testing.T) {
+	site := loadTestSite(t)
+
+	for _, format := range []string{FormatHTML, FormatMarkdown} {
+		t.Run(format, func(t *testing.T) {
+			ext := map[string]string{FormatHTML: "html", FormatMarkdown: "md"}[format]
+			out := t.TempDir()
+			if err := site.Write(out, format); err != nil {
+				t.Fatal(err)
+			}
+
+			pkgPage, err := os.ReadFile(filepath.Join(out, "packages", "store", "index."+ext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			diagram := map[string]string{FormatHTML: `<rect class="node highlight"`, FormatMarkdown: "graph LR"}[format]
+			for _, want := range []string{"../../files/store/store.go." + ext, "../_root/index." + ext, diagram, "存储相关"} {
+				if !strings.Contains(string(pkgPage), want) {
+					t.Errorf("package page missing %q:\n%s", want, pkgPage)
+				}
+			}
+
+			if format == FormatHTML && strings.Contains(string(pkgPage), "https://") {
+				t.Errorf("html page loads a remote resource:\n%s", pkgPage)
+			}
+
+			filePage, err := os.ReadFile(filepath.Join(out, "files", "store", "store.go."+ext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{"sym-Store-Add", "内存存储", "../../packages/store/index." + ext} {
+				if !strings.Contains(string(filePage), want) {
+					t.Errorf("file page missing %q:\n%s", want, filePage)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(out, "search_index.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var index []SearchEntry
+			if err := json.Unmarshal(data, &index); err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, entry := range index {
+				if entry.Title == "Store.Add" && entry.URL == "files/store/store.go."+ext+"#sym-Store-Add" {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("search index missing Store.Add: %s", data)
+			}
+		})
+	}
+
+	if err := site.Write(t.TempDir(), "pdf"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestDiagramLayers(t *testing.T) {
+	api, store, util := &Package{Dir: "api"}, &Package{Dir: "store"}, &Package{Dir: "util"}
+	api.Imports = []*Package{store, util}
+	store.Imports = []*Package{util}
+	store.Importers = []*Package{api}
+	site := &Site{Packages: []*Package{api, store, util}}
+
+	// util 同时被 api 和 store 引用，排在 store 右侧
+	var got []string
+	for _, layer := range site.overviewGraph().layers() {
+		var dirs []string
+		for _, pkg := range layer {
+			dirs = append(dirs, pkg.Dir)
+		}
+		got = append(got, strings.Join(dirs, ","))
+	}
+	if strings.Join(got, " | ") != "api | store | util" {
+		t.Errorf("layers = %q", got)
+	}
+
+	svg := packageGraph(store).svg("packages/store/index.html", "html")
+	for _, want := range []string{`<a href="../api/index.html">`, `<rect class="node highlight"`, `marker-end="url(#arrow)"`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("svg missing %q:\n%s", want, svg)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// 基于 search_index.js 中的 window.SEARCH_INDEX 做简单的子串搜索
+(function () {
+  var input = document.getElementById("search");
+  var list = document.getElementById("search-results");
+  var root = document.body.getAttribute("data-root") || "";
+  var index = window.SEARCH_INDEX || [];
+
+  input.addEventListener("input", function () {
+    var query = input.value.trim().toLowerCase();
+    list.innerHTML = "";
+    if (!query) {
+      return;
+    }
+    var matches = index.filter(function (entry) {
+      return entry.title.toLowerCase().indexOf(query) >= 0 || entry.text.toLowerCase().indexOf(query) >= 0;
+    }).slice(0, 50);
+    matches.forEach(function (entry) {
+      var item = document.createElement("li");
+      var kind = document.createElement("span");
+      kind.className = "kind";
+      kind.textContent = entry.kind;
+      var link = document.createElement("a");
+      link.href = root + entry.url;
+      link.textContent = entry.title;
+      item.appendChild(kind);
+      item.appendChild(link);
+      list.appendChild(item);
+    });
+  });
+})();
@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"codetest/internal/entity"
+)
+
+// ErrEmbeddingUnsupported LLM 客户端不能计算向量
+var ErrEmbeddingUnsupported = errors.New("LLM client does not support embeddings")
+
+// Embedder 可以计算文本向量的 LLM 客户端。UsageMeter、LoggingClient、TranscriptRecorder 等包装客户端
+// 都实现了它，在内层客户端支持时转发调用，所以向量请求与其他 LLM 调用一样计费、记录和回放
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) (entity.EmbeddingResponse, error)
+}
+
+// Embed 用 client 计算 texts 的向量，client 没有实现 Embedder 时返回 ErrEmbeddingUnsupported
+func Embed(ctx context.Context, client LLMClient, texts []string) (entity.EmbeddingResponse, error) {
+	embedder, ok := client.(Embedder)
+	if !ok {
+		return entity.EmbeddingResponse{}, ErrEmbeddingUnsupported
+	}
+	return embedder.Embed(ctx, texts)
+}
+
+// EmbeddingPrompt 向量请求在 transcript 中记录的 prompt，回放时按它的哈希匹配记录
+func EmbeddingPrompt(texts []string) string {
+	data, _ := json.Marshal(texts)
+	return "embed:" + string(data)
+}
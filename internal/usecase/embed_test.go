@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+// stubEmbedder 返回每段文本长度组成的向量
+type stubEmbedder struct {
+	stubLLMClient
+	calls int
+}
+
+func (s *stubEmbedder) Embed(ctx context.Context, texts []string) (entity.EmbeddingResponse, error) {
+	s.calls++
+	response := entity.EmbeddingResponse{Model: "text-embedding-3-small", PromptTokens: 1000}
+	for _, text := range texts {
+		response.Vectors = append(response.Vectors, []float32{float32(len(text))})
+	}
+	return response, nil
+}
+
+func TestEmbedThroughWrappedClients(t *testing.T) {
+	var transcript bytes.Buffer
+	inner := &stubEmbedder{}
+	client := NewLoggingClient(NewTranscriptRecorder(inner, &transcript, "run-1", "gpt-4o-mini"))
+	meter := NewUsageMeter(client, DefaultPrices(), 0, "text-embedding-3-small")
+
+	ctx := WithStage(context.Background(), "embed")
+	response, err := meter.Embed(ctx, []string{"a.go", "store/b.go"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(response.Vectors) != 2 || response.Vectors[1][0] != 10 {
+		t.Errorf("vectors = %v", response.Vectors)
+	}
+	if usage := meter.Report().ByStage["embed"]; usage.Calls != 1 || usage.PromptTokens != 1000 || usage.Cost != 0.00002 {
+		t.Errorf("embed usage = %+v", usage)
+	}
+
+	// transcript 中的记录可以回放，不再调用内层客户端
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	if err := os.WriteFile(path, transcript.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := NewTranscriptReplayClient(loaded.Entries)
+	replayed, err := Embed(ctx, NewLoggingClient(replay), []string{"a.go", "store/b.go"})
+	if err != nil || len(replayed.Vectors) != 2 || replayed.Vectors[0][0] != 4 || inner.calls != 1 {
+		t.Errorf("replayed = %+v, err %v, inner calls %d", replayed, err, inner.calls)
+	}
+	if _, err := Embed(ctx, replay, []string{"other"}); err == nil {
+		t.Error("replaying unrecorded texts succeeded")
+	}
+
+	if _, err := Embed(ctx, NewLoggingClient(&stubLLMClient{}), []string{"a"}); !errors.Is(err, ErrEmbeddingUnsupported) {
+		t.Errorf("err = %v, want ErrEmbeddingUnsupported", err)
+	}
+}
+
+func TestEmbedStopsBeforeBudget(t *testing.T) {
+	inner := &stubEmbedder{}
+	meter := NewUsageMeter(inner, PriceTable{"text-embedding-3-small": {Prompt: 1e6}}, 0.5, "text-embedding-3-small")
+	if _, err := meter.Embed(context.Background(), []string{"package main\n\nfunc main() {}\n"}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if inner.calls != 0 {
+		t.Errorf("inner calls = %d, want 0", inner.calls)
+	}
+}
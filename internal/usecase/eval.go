@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"codetest/internal/entity"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EvalCase 评估集中的一个已标注文件
+type EvalCase struct {
+	File        string   `yaml:"file"`
+	PackageName string   `yaml:"package_name"`
+	Imports     []string `yaml:"imports"`
+	Symbols     []string `yaml:"symbols"`
+}
+
+// EvalResult 单个文件的评估结果
+type EvalResult struct {
+	File           string
+	PromptVersion  string
+	PackageOK      bool
+	ImportRecall   float64
+	SymbolRecall   float64
+	MissingImports []string
+	MissingSymbols []string
+	Score          float64
+	Err            error
+}
+
+// LoadEvalCases 读取评估集
+func LoadEvalCases(file string) ([]EvalCase, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Cases []EvalCase `yaml:"cases"`
+	}
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	return set.Cases, nil
+}
+
+// EvaluateAnalysis 对评估集中的每个文件运行代码分析，并与标注对比打分。
+// 分数为包名是否正确、导入召回率、导出符号召回率三项的平均值。
+func EvaluateAnalysis(ctx context.Context, uc AICodeUseCase, promptVersion string, cases []EvalCase) []EvalResult {
+	results := make([]EvalResult, 0, len(cases))
+	for _, c := range cases {
+		result := EvalResult{File: c.File, PromptVersion: promptVersion}
+
+		code, err := os.ReadFile(c.File)
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		response, parsed, err := uc.AIAnalysisCode(ctx, c.File, string(code))
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+
+		var full entity.FileAnalysis
+		_ = yaml.Unmarshal([]byte(response), &full)
+		full.ParsedYAML = parsed
+
+		result.PackageOK = c.PackageName == "" || c.PackageName == parsed.FileInfo.PackageName
+		result.ImportRecall, result.MissingImports = recall(c.Imports, importSet(parsed.FileInfo.Imports))
+		result.SymbolRecall, result.MissingSymbols = recall(c.Symbols, symbolSet(full))
+
+		packageScore := 0.0
+		if result.PackageOK {
+			packageScore = 1
+		}
+		result.Score = (packageScore + result.ImportRecall + result.SymbolRecall) / 3
+		results = append(results, result)
+	}
+	return results
+}
+
+// recall 计算期望项在 found 中的召回率，没有期望项时为 1
+func recall(expected []string, found func(string) bool) (float64, []string) {
+	if len(expected) == 0 {
+		return 1, nil
+	}
+	var missing []string
+	for _, item := range expected {
+		if !found(item) {
+			missing = append(missing, item)
+		}
+	}
+	sort.Strings(missing)
+	return float64(len(expected)-len(missing)) / float64(len(expected)), missing
+}
+
+// importSet 导入路径可能带引号或别名，统一比较路径本身
+func importSet(imports []string) func(string) bool {
+	set := map[string]bool{}
+	for _, imp := range imports {
+		fields := strings.Fields(strings.Trim(imp, `'"`))
+		if len(fields) > 0 {
+			set[strings.Trim(fields[len(fields)-1], `'"`)] = true
+		}
+	}
+	return func(path string) bool { return set[path] }
+}
+
+// symbolSet 收集分析结果中出现的符号名称，功能描述中提到的名称也算在内
+func symbolSet(analysis entity.FileAnalysis) func(string) bool {
+	set := map[string]bool{}
+	add := func(name string) {
+		name, _, _ = strings.Cut(strings.TrimSpace(name), "(")
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		set[strings.TrimSpace(name)] = true
+	}
+	for _, c := range analysis.Constants {
+		add(c.Name)
+	}
+	for _, s := range analysis.Structs {
+		add(s.Name)
+		for _, m := range s.Methods {
+			add(m.Name)
+		}
+	}
+	for _, i := range analysis.Interfaces {
+		add(i.Name)
+	}
+	for _, m := range analysis.Methods {
+		add(m.Name)
+	}
+	return func(name string) bool {
+		return set[name] || strings.Contains(analysis.FileDescription, name)
+	}
+}
@@ -0,0 +1,17 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"codetest/internal/usecase/prompt"
+)
+
+// ExplainImpact 让 LLM 根据影响分析结果说明变化的风险，report 为影响分析的文本
+func (uc *aiCodeUseCase) ExplainImpact(ctx context.Context, projectName, report string) (string, error) {
+	response, err := askLLM(ctx, uc.client, uc.prompts, prompt.ImpactRisk, prompt.Data{Project: projectName, Summary: report})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response), nil
+}
@@ -0,0 +1,315 @@
+// Package impact 根据 Go 源码的调用关系分析函数变化的影响范围：受影响的包、导出的 API 和测试
+package impact
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codetest/internal/pkg/repoinfo"
+)
+
+// Func 一个函数或方法
+type Func struct {
+	ID       string // <包>.<函数> 或 <包>.<类型>.<方法>，包为 import 路径，不在 Go 模块中时为相对目录
+	Package  string
+	Name     string // 函数名，方法为 <类型>.<方法>
+	File     string // 相对源码目录的路径
+	Line     int
+	EndLine  int
+	Exported bool // 函数名导出，方法的接收者类型也导出
+	Test     bool // _test.go 中的 Test、Benchmark、Fuzz 和 Example 函数
+}
+
+// Graph 项目内函数之间的引用关系，只根据语法分析，没有类型信息：
+// 包名限定的调用和同一个包中的函数可以准确解析，x.Method() 形式的调用
+// 匹配调用方所在包以及它 import 的项目内的包中所有同名的方法，结果可能偏多。
+type Graph struct {
+	Funcs      map[string]*Func
+	PkgNames   map[string]string          // 包路径 -> 包名
+	PkgDirs    map[string]string          // 包路径 -> 相对源码目录的目录
+	callees    map[string]map[string]bool // 函数 -> 它引用的函数
+	callers    map[string]map[string]bool // 函数 -> 引用它的函数
+	byFile     map[string][]*Func
+	methods    map[string]map[string][]string // 包路径 -> 方法名 -> 方法 ID
+	topLevel   map[string]map[string]string   // 包路径 -> 函数名 -> 函数 ID
+	fileImport map[string][]string            // 文件 -> import 的项目内的包
+}
+
+// parsedFile 解析后的文件和它所在的包
+type parsedFile struct {
+	rel     string
+	pkg     string
+	file    *ast.File
+	imports map[string]string // 文件中的包名 -> import 路径
+}
+
+// LoadGraph 解析 paths 中的 Go 文件（包括测试文件）建立引用关系，paths 为 sourceDir 下的路径，有语法错误的文件记录警告后跳过
+func LoadGraph(sourceDir string, paths []string) (*Graph, error) {
+	moduleRoot, modulePath, hasModule := repoinfo.FindGoModule(sourceDir)
+	absSource, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Graph{
+		Funcs:      map[string]*Func{},
+		PkgNames:   map[string]string{},
+		PkgDirs:    map[string]string{},
+		callees:    map[string]map[string]bool{},
+		callers:    map[string]map[string]bool{},
+		byFile:     map[string][]*Func{},
+		methods:    map[string]map[string][]string{},
+		topLevel:   map[string]map[string]string{},
+		fileImport: map[string][]string{},
+	}
+	fset := token.NewFileSet()
+	var files []*parsedFile
+	for _, walked := range paths {
+		rel, err := filepath.Rel(sourceDir, walked)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %v", walked, err)
+		}
+		rel = filepath.ToSlash(rel)
+		// 有语法错误的文件（例如正在编辑中）不参与分析，不影响其他文件
+		file, err := parser.ParseFile(fset, walked, nil, parser.SkipObjectResolution)
+		if err != nil {
+			slog.Warn("Skipping a file that does not parse", "file", rel, "error", err)
+			continue
+		}
+
+		dir := path.Dir(rel)
+		pkg := dir
+		if hasModule {
+			if modRel, err := filepath.Rel(moduleRoot, filepath.Join(absSource, dir)); err == nil && !strings.HasPrefix(modRel, "..") {
+				pkg = path.Join(modulePath, filepath.ToSlash(modRel))
+			}
+		}
+		// 外部测试包 x_test 与 x 在同一目录，单独作为一个包
+		if strings.HasSuffix(file.Name.Name, "_test") {
+			pkg += "_test"
+		} else {
+			g.PkgNames[pkg] = file.Name.Name
+		}
+		g.PkgDirs[pkg] = dir
+		files = append(files, &parsedFile{rel: rel, pkg: pkg, file: file})
+	}
+
+	// 先登记所有函数，再解析引用
+	for _, pf := range files {
+		for _, decl := range pf.file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			g.addFunc(pf, fset, fn)
+		}
+	}
+	for _, pf := range files {
+		pf.imports = map[string]string{}
+		for _, spec := range pf.file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			if _, ok := g.PkgNames[importPath]; !ok {
+				continue // 只关心项目内的包
+			}
+			name := g.PkgNames[importPath]
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			pf.imports[name] = importPath
+			g.fileImport[pf.rel] = append(g.fileImport[pf.rel], importPath)
+		}
+		for _, decl := range pf.file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+				g.addRefs(pf, funcID(pf.pkg, fn), fn.Body)
+			}
+		}
+	}
+	for _, funcs := range g.byFile {
+		sort.Slice(funcs, func(i, j int) bool { return funcs[i].Line < funcs[j].Line })
+	}
+	return g, nil
+}
+
+// addFunc 登记一个函数
+func (g *Graph) addFunc(pf *parsedFile, fset *token.FileSet, fn *ast.FuncDecl) {
+	f := &Func{
+		ID:       funcID(pf.pkg, fn),
+		Package:  pf.pkg,
+		File:     pf.rel,
+		Line:     fset.Position(fn.Pos()).Line,
+		EndLine:  fset.Position(fn.End()).Line,
+		Exported: fn.Name.IsExported(),
+	}
+	f.Name = strings.TrimPrefix(f.ID, pf.pkg+".")
+	if recv := recvType(fn); recv != "" {
+		f.Exported = f.Exported && ast.IsExported(recv)
+		if g.methods[pf.pkg] == nil {
+			g.methods[pf.pkg] = map[string][]string{}
+		}
+		g.methods[pf.pkg][fn.Name.Name] = append(g.methods[pf.pkg][fn.Name.Name], f.ID)
+	} else {
+		if g.topLevel[pf.pkg] == nil {
+			g.topLevel[pf.pkg] = map[string]string{}
+		}
+		g.topLevel[pf.pkg][fn.Name.Name] = f.ID
+		f.Test = strings.HasSuffix(pf.rel, "_test.go") && isTestFunc(fn.Name.Name)
+	}
+	g.Funcs[f.ID] = f
+	g.byFile[pf.rel] = append(g.byFile[pf.rel], f)
+}
+
+// addRefs 记录函数体中引用的项目内的函数，包括调用和作为值使用
+func (g *Graph) addRefs(pf *parsedFile, from string, body *ast.BlockStmt) {
+	// 测试包 x_test 中也可以直接引用同目录的 x 中的函数（通过 export_test.go 等），按同包处理
+	samePackages := []string{pf.pkg}
+	if base, ok := strings.CutSuffix(pf.pkg, "_test"); ok {
+		samePackages = append(samePackages, base)
+	}
+	methodPackages := append(append([]string{}, samePackages...), g.fileImport[pf.rel]...)
+
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.SelectorExpr:
+			if ident, ok := n.X.(*ast.Ident); ok {
+				if importPath, ok := pf.imports[ident.Name]; ok {
+					if id, ok := g.topLevel[importPath][n.Sel.Name]; ok {
+						g.addEdge(from, id)
+					}
+					return false
+				}
+			}
+			// x.Method：匹配本包和 import 的包中所有同名方法
+			for _, pkg := range methodPackages {
+				for _, id := range g.methods[pkg][n.Sel.Name] {
+					g.addEdge(from, id)
+				}
+			}
+			// Sel 不是本包的函数，只继续检查 X
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.Ident:
+			g.addLocalRef(samePackages, from, n.Name)
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+}
+
+// addLocalRef 同一个包中的函数引用，局部变量与函数同名时会多出一条边
+func (g *Graph) addLocalRef(packages []string, from, name string) {
+	for _, pkg := range packages {
+		if id, ok := g.topLevel[pkg][name]; ok {
+			g.addEdge(from, id)
+		}
+	}
+}
+
+func (g *Graph) addEdge(from, to string) {
+	if from == to {
+		return
+	}
+	if g.callees[from] == nil {
+		g.callees[from] = map[string]bool{}
+	}
+	g.callees[from][to] = true
+	if g.callers[to] == nil {
+		g.callers[to] = map[string]bool{}
+	}
+	g.callers[to][from] = true
+}
+
+// Callers 返回引用 id 的函数，按 ID 排序
+func (g *Graph) Callers(id string) []string {
+	return sortedKeys(g.callers[id])
+}
+
+// Callees 返回 id 引用的函数，按 ID 排序
+func (g *Graph) Callees(id string) []string {
+	return sortedKeys(g.callees[id])
+}
+
+// FuncsInFile 返回文件中的函数，按行号排序
+func (g *Graph) FuncsInFile(file string) []*Func {
+	return g.byFile[file]
+}
+
+// Resolve 查找名称对应的函数。名称可以是完整的 ID（<import 路径>.<函数>）、
+// <相对目录>.<函数>、<包名>.<函数>，或者只有 <函数>；方法写作 <类型>.<方法>。
+// 没有找到时返回错误，匹配到多个函数时返回全部。
+func (g *Graph) Resolve(name string) ([]*Func, error) {
+	var matches []*Func
+	for _, f := range g.Funcs {
+		pkgName := g.PkgNames[strings.TrimSuffix(f.Package, "_test")]
+		for _, candidate := range []string{f.ID, g.PkgDirs[f.Package] + "." + f.Name, pkgName + "." + f.Name, f.Name} {
+			if candidate == name {
+				matches = append(matches, f)
+				break
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no function named %q in the source directory", name)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+	return matches, nil
+}
+
+// funcID 返回函数的 ID
+func funcID(pkg string, fn *ast.FuncDecl) string {
+	if recv := recvType(fn); recv != "" {
+		return pkg + "." + recv + "." + fn.Name.Name
+	}
+	return pkg + "." + fn.Name.Name
+}
+
+// recvType 返回方法接收者的类型名，去掉指针和类型参数，函数返回空
+func recvType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// isTestFunc 判断是否为 go test 运行的函数
+func isTestFunc(name string) bool {
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+		if rest, ok := strings.CutPrefix(name, prefix); ok {
+			return rest == "" || !('a' <= rest[0] && rest[0] <= 'z')
+		}
+	}
+	return false
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,203 @@
+package impact
+
+import (
+	"sort"
+	"strings"
+
+	"codetest/internal/pkg/unidiff"
+	"codetest/internal/usecase/docsite"
+)
+
+// FuncImpact 受影响的函数
+type FuncImpact struct {
+	ID              string `json:"id"`
+	Package         string `json:"package"`
+	File            string `json:"file"`
+	Line            int    `json:"line"`
+	Depth           int    `json:"depth"`         // 到变化的函数的调用层数，变化的函数为 0
+	Via             string `json:"via,omitempty"` // 引用链上的下一个函数，沿 Via 可以走到变化的函数
+	Exported        bool   `json:"exported,omitempty"`
+	Test            bool   `json:"test,omitempty"`
+	FileDescription string `json:"file_description,omitempty"` // 保存的文件分析结果中的描述，只有变化的函数有
+}
+
+// PackageImpact 受影响的包，外部测试包 x_test 归入 x
+type PackageImpact struct {
+	Package   string `json:"package"`
+	Dir       string `json:"dir"`
+	Changed   bool   `json:"changed"` // 包中有变化的函数
+	Depth     int    `json:"depth"`   // 包中受影响的函数的最小层数
+	Functions int    `json:"functions"`
+	Summary   string `json:"summary,omitempty"` // 保存的包总结
+}
+
+// Edge 受影响的函数之间的引用，Caller 引用了 Callee
+type Edge struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+}
+
+// Report 影响分析的结果，列表都按层数和 ID 排序
+type Report struct {
+	Project   string          `json:"project"`
+	Changed   []FuncImpact    `json:"changed"`
+	Packages  []PackageImpact `json:"packages"`
+	APIs      []FuncImpact    `json:"exported_apis"`
+	Tests     []FuncImpact    `json:"tests"`
+	Functions []FuncImpact    `json:"functions"` // 所有受影响的函数，包括变化的函数
+	Edges     []Edge          `json:"edges"`
+	Narrative string          `json:"narrative,omitempty"` // LLM 对风险的说明
+}
+
+// Analyze 从变化的函数出发沿调用方向上查找所有受影响的函数，maxDepth 大于 0 时最多查找这么多层
+func Analyze(g *Graph, project string, changed []*Func, maxDepth int) *Report {
+	reached := map[string]*FuncImpact{}
+	var queue []string
+	for _, f := range changed {
+		if _, ok := reached[f.ID]; !ok {
+			reached[f.ID] = newFuncImpact(f, 0, "")
+			queue = append(queue, f.ID)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		depth := reached[id].Depth
+		if maxDepth > 0 && depth >= maxDepth {
+			continue
+		}
+		for _, caller := range g.Callers(id) {
+			if _, ok := reached[caller]; !ok {
+				reached[caller] = newFuncImpact(g.Funcs[caller], depth+1, id)
+				queue = append(queue, caller)
+			}
+		}
+	}
+
+	report := &Report{
+		Project:   project,
+		Changed:   []FuncImpact{},
+		Packages:  []PackageImpact{},
+		APIs:      []FuncImpact{},
+		Tests:     []FuncImpact{},
+		Functions: []FuncImpact{},
+		Edges:     []Edge{},
+	}
+	packages := map[string]*PackageImpact{}
+	for _, impact := range reached {
+		report.Functions = append(report.Functions, *impact)
+	}
+	sortFuncs(report.Functions)
+	for _, impact := range report.Functions {
+		if impact.Depth == 0 {
+			report.Changed = append(report.Changed, impact)
+		}
+		if impact.Test {
+			report.Tests = append(report.Tests, impact)
+		} else if impact.Exported && !strings.HasSuffix(impact.File, "_test.go") && g.PkgNames[impact.Package] != "main" {
+			report.APIs = append(report.APIs, impact)
+		}
+
+		pkgPath := strings.TrimSuffix(impact.Package, "_test")
+		pkg, ok := packages[pkgPath]
+		if !ok {
+			pkg = &PackageImpact{Package: pkgPath, Dir: g.PkgDirs[impact.Package], Depth: impact.Depth}
+			packages[pkgPath] = pkg
+		}
+		pkg.Functions++
+		pkg.Changed = pkg.Changed || impact.Depth == 0
+		if impact.Depth < pkg.Depth {
+			pkg.Depth = impact.Depth
+		}
+
+		for _, callee := range g.Callees(impact.ID) {
+			if _, ok := reached[callee]; ok {
+				report.Edges = append(report.Edges, Edge{Caller: impact.ID, Callee: callee})
+			}
+		}
+	}
+	for _, pkg := range packages {
+		report.Packages = append(report.Packages, *pkg)
+	}
+	sort.Slice(report.Packages, func(i, j int) bool {
+		a, b := report.Packages[i], report.Packages[j]
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		return a.Package < b.Package
+	})
+	return report
+}
+
+func newFuncImpact(f *Func, depth int, via string) *FuncImpact {
+	return &FuncImpact{ID: f.ID, Package: f.Package, File: f.File, Line: f.Line, Depth: depth, Via: via, Exported: f.Exported, Test: f.Test}
+}
+
+func sortFuncs(funcs []FuncImpact) {
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].Depth != funcs[j].Depth {
+			return funcs[i].Depth < funcs[j].Depth
+		}
+		return funcs[i].ID < funcs[j].ID
+	})
+}
+
+// ChangedFuncs 返回补丁修改过的函数：新增、修改或删除的行落在函数的范围内。整个删除的函数不在源码中，无法分析。
+func ChangedFuncs(g *Graph, files []unidiff.File) []*Func {
+	var changed []*Func
+	seen := map[string]bool{}
+	for _, file := range files {
+		if file.NewPath == "" {
+			continue
+		}
+		lines := changedLines(file)
+		for _, f := range g.FuncsInFile(file.NewPath) {
+			for _, line := range lines {
+				if line >= f.Line && line <= f.EndLine && !seen[f.ID] {
+					seen[f.ID] = true
+					changed = append(changed, f)
+					break
+				}
+			}
+		}
+	}
+	return changed
+}
+
+// changedLines 返回新文件中变化的行号，删除的行记为删除位置之后的一行
+func changedLines(file unidiff.File) []int {
+	var lines []int
+	for _, hunk := range file.Hunks {
+		next := hunk.NewStart
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case unidiff.LineAdded:
+				lines = append(lines, line.NewLine)
+				next = line.NewLine + 1
+			case unidiff.LineDeleted:
+				lines = append(lines, next)
+			default:
+				next = line.NewLine + 1
+			}
+		}
+	}
+	return lines
+}
+
+// Annotate 用保存的分析结果补充包总结和变化的函数所在文件的描述
+func (r *Report) Annotate(site *docsite.Site) {
+	summaries := map[string]string{}
+	for _, pkg := range site.Packages {
+		summaries[pkg.Dir] = strings.TrimSpace(pkg.Summary)
+	}
+	descriptions := map[string]string{}
+	for _, file := range site.Files {
+		descriptions[file.Path] = file.Description
+	}
+	for i := range r.Packages {
+		r.Packages[i].Summary = summaries[r.Packages[i].Dir]
+	}
+	for i := range r.Changed {
+		r.Changed[i].FileDescription = descriptions[r.Changed[i].File]
+	}
+}
@@ -0,0 +1,215 @@
+package impact
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"codetest/internal/pkg/unidiff"
+	"codetest/internal/usecase/docsite"
+)
+
+// loadTestGraph store 包被 api 包调用，api 包被 main 调用，两个包都有测试，broken 包有语法错误
+func loadTestGraph(t *testing.T) *Graph {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module svc\n\ngo 1.22\n",
+		"store/store.go": `package store
+
+type Store struct{ data map[string]string }
+
+func New() *Store { return &Store{data: map[string]string{}} }
+
+func (s *Store) Get(key string) string {
+	return s.data[key]
+}
+
+func (s *Store) Put(key, value string) { s.data[key] = value }
+`,
+		"store/store_test.go": `package store
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	if New().Get("x") != "" {
+		t.Fail()
+	}
+}
+
+func TestPut(t *testing.T) { New().Put("x", "y") }
+`,
+		"api/api.go": `package api
+
+import "svc/store"
+
+func Handle(s *store.Store) string { return format(s.Get("x")) }
+
+func format(v string) string { return "[" + v + "]" }
+
+func Other() {}
+`,
+		"api/api_test.go": `package api_test
+
+import (
+	"testing"
+
+	"svc/api"
+	st "svc/store"
+)
+
+func TestHandle(t *testing.T) { api.Handle(st.New()) }
+`,
+		"broken/broken.go": "package broken\n\nfunc Broken( {\n",
+		"cmd/main.go": `package main
+
+import "svc/api"
+
+func main() { println(api.Handle(nil)) }
+`,
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(name, ".go") {
+			paths = append(paths, path)
+		}
+	}
+	g, err := LoadGraph(root, paths)
+	if err != nil {
+		t.Fatalf("LoadGraph: %v", err)
+	}
+	return g
+}
+
+func ids(funcs []FuncImpact) []string {
+	var result []string
+	for _, f := range funcs {
+		result = append(result, f.ID)
+	}
+	return result
+}
+
+func TestGraph(t *testing.T) {
+	g := loadTestGraph(t)
+	if got := g.Callers("svc/store.Store.Get"); !slices.Equal(got, []string{"svc/api.Handle", "svc/store.TestGet"}) {
+		t.Errorf("callers of Store.Get = %q", got)
+	}
+	if got := g.Callees("svc/api_test.TestHandle"); !slices.Equal(got, []string{"svc/api.Handle", "svc/store.New"}) {
+		t.Errorf("callees of TestHandle = %q", got)
+	}
+	if got := g.Callers("svc/api.format"); !slices.Equal(got, []string{"svc/api.Handle"}) {
+		t.Errorf("callers of format = %q", got)
+	}
+	if f := g.Funcs["svc/store.TestGet"]; f == nil || !f.Test || f.Exported != true {
+		t.Errorf("TestGet = %+v", f)
+	}
+
+	for _, name := range []string{"svc/store.Store.Get", "store.Store.Get", "Store.Get"} {
+		if funcs, err := g.Resolve(name); err != nil || len(funcs) != 1 || funcs[0].ID != "svc/store.Store.Get" {
+			t.Errorf("Resolve(%q) = %v, %v", name, funcs, err)
+		}
+	}
+	if _, err := g.Resolve("store.Missing"); err == nil {
+		t.Error("Resolve of a missing function must fail")
+	}
+	if funcs := g.FuncsInFile("broken/broken.go"); len(funcs) != 0 {
+		t.Errorf("functions of a file with syntax errors = %v", funcs)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	g := loadTestGraph(t)
+	changed, _ := g.Resolve("Store.Get")
+	report := Analyze(g, "svc", changed, 0)
+
+	want := []string{"svc/store.Store.Get", "svc/api.Handle", "svc/store.TestGet", "svc/api_test.TestHandle", "svc/cmd.main"}
+	if got := ids(report.Functions); !slices.Equal(got, want) {
+		t.Fatalf("functions = %q, want %q", got, want)
+	}
+	if got := ids(report.APIs); !slices.Equal(got, []string{"svc/store.Store.Get", "svc/api.Handle"}) {
+		t.Errorf("exported APIs = %q", got)
+	}
+	if got := ids(report.Tests); !slices.Equal(got, []string{"svc/store.TestGet", "svc/api_test.TestHandle"}) {
+		t.Errorf("tests = %q", got)
+	}
+	var packages []string
+	for _, pkg := range report.Packages {
+		packages = append(packages, pkg.Package)
+	}
+	if !slices.Equal(packages, []string{"svc/store", "svc/api", "svc/cmd"}) || !report.Packages[0].Changed || report.Packages[1].Functions != 2 {
+		t.Errorf("packages = %+v", report.Packages)
+	}
+	if got := report.TestCommands(); !slices.Equal(got, []string{"go test ./api -run '^(TestHandle)$'", "go test ./store -run '^(TestGet)$'"}) {
+		t.Errorf("test commands = %q", got)
+	}
+
+	if got := ids(Analyze(g, "svc", changed, 1).Functions); !slices.Equal(got, want[:3]) {
+		t.Errorf("functions with max depth 1 = %q", got)
+	}
+
+	report.Annotate(&docsite.Site{
+		Packages: []*docsite.Package{{Dir: "store", Summary: "内存存储\n详细说明"}},
+		Files:    []*docsite.File{{Path: "store/store.go", Description: "Store 的实现"}},
+	})
+	text := report.Text()
+	for _, part := range []string{"svc/store  changed, 2 affected\n      内存存储\n", "Store 的实现", "svc/cmd  depth 2, 1 affected"} {
+		if !strings.Contains(text, part) {
+			t.Errorf("text is missing %q:\n%s", part, text)
+		}
+	}
+}
+
+func TestChangedFuncs(t *testing.T) {
+	g := loadTestGraph(t)
+	files, err := unidiff.Parse("--- a/store/store.go\n+++ b/store/store.go\n@@ -8,3 +8,2 @@\n \treturn s.data[key]\n-\t// removed\n }\n" +
+		"--- a/api/api.go\n+++ b/api/api.go\n@@ -8,0 +9 @@\n+func Other() {}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range ChangedFuncs(g, files) {
+		got = append(got, f.ID)
+	}
+	if !slices.Equal(got, []string{"svc/store.Store.Get", "svc/api.Other"}) {
+		t.Errorf("changed functions = %q", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	g := loadTestGraph(t)
+	changed, _ := g.Resolve("api.Handle")
+	report := Analyze(g, "svc", changed, 0)
+
+	dot, err := report.Render(FormatDOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{`"svc/api.Handle" [label="Handle", style=filled, fillcolor="#f4cccc", penwidth=2];`, `"svc/cmd.main" -> "svc/api.Handle";`} {
+		if !strings.Contains(string(dot), part) {
+			t.Errorf("dot is missing %q:\n%s", part, dot)
+		}
+	}
+
+	data, err := report.Render(FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded.Edges) != 2 || decoded.Changed[0].ID != "svc/api.Handle" {
+		t.Errorf("json = %s, %v", data, err)
+	}
+
+	if _, err := report.Render("svg"); err == nil {
+		t.Error("Render accepted an unknown format")
+	}
+}
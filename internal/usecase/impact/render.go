@@ -0,0 +1,161 @@
+package impact
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 影响分析结果的输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatDOT  = "dot"
+)
+
+// Render 按 format 输出影响分析结果
+func (r *Report) Render(format string) ([]byte, error) {
+	switch format {
+	case FormatText:
+		return []byte(r.Text()), nil
+	case FormatJSON:
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode impact report: %v", err)
+		}
+		return append(data, '\n'), nil
+	case FormatDOT:
+		return []byte(r.DOT()), nil
+	}
+	return nil, fmt.Errorf("unknown impact format %q, expected %s, %s or %s", format, FormatText, FormatJSON, FormatDOT)
+}
+
+// Text 输出供人阅读的文本，也作为 LLM 说明风险时的输入
+func (r *Report) Text() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Impact of %d changed functions in %s\n", len(r.Changed), r.Project)
+
+	builder.WriteString("\nChanged functions:\n")
+	for _, f := range r.Changed {
+		fmt.Fprintf(&builder, "  %s  %s:%d\n", f.ID, f.File, f.Line)
+		if f.FileDescription != "" {
+			fmt.Fprintf(&builder, "      %s\n", firstLine(f.FileDescription))
+		}
+	}
+
+	fmt.Fprintf(&builder, "\nAffected packages (%d):\n", len(r.Packages))
+	for _, pkg := range r.Packages {
+		state := fmt.Sprintf("depth %d", pkg.Depth)
+		if pkg.Changed {
+			state = "changed"
+		}
+		fmt.Fprintf(&builder, "  %s  %s, %d affected\n", pkg.Package, state, pkg.Functions)
+		if pkg.Summary != "" {
+			fmt.Fprintf(&builder, "      %s\n", firstLine(pkg.Summary))
+		}
+	}
+
+	fmt.Fprintf(&builder, "\nExported APIs (%d):\n", len(r.APIs))
+	for _, f := range r.APIs {
+		writeFunc(&builder, f)
+	}
+
+	fmt.Fprintf(&builder, "\nTests (%d):\n", len(r.Tests))
+	for _, f := range r.Tests {
+		writeFunc(&builder, f)
+	}
+	if commands := r.TestCommands(); len(commands) > 0 {
+		builder.WriteString("\nRun the affected tests:\n")
+		for _, command := range commands {
+			fmt.Fprintf(&builder, "  %s\n", command)
+		}
+	}
+
+	if r.Narrative != "" {
+		builder.WriteString("\nRisk:\n")
+		builder.WriteString(strings.TrimSpace(r.Narrative) + "\n")
+	}
+	return builder.String()
+}
+
+// writeFunc 输出一个受影响的函数和它的位置，depth 大于 0 时给出引用链上的下一个函数
+func writeFunc(builder *strings.Builder, f FuncImpact) {
+	fmt.Fprintf(builder, "  %s  %s:%d", f.ID, f.File, f.Line)
+	if f.Depth > 0 {
+		fmt.Fprintf(builder, "  depth %d via %s", f.Depth, f.Via)
+	}
+	builder.WriteString("\n")
+}
+
+// TestCommands 返回运行受影响的测试的 go test 命令，每个目录一条，基准测试不包含在内
+func (r *Report) TestCommands() []string {
+	byDir := map[string][]string{}
+	for _, f := range r.Tests {
+		name := f.ID[strings.LastIndex(f.ID, ".")+1:]
+		if strings.HasPrefix(name, "Benchmark") {
+			continue
+		}
+		dir := path.Dir(f.File)
+		byDir[dir] = append(byDir[dir], name)
+	}
+	var commands []string
+	for dir, names := range byDir {
+		sort.Strings(names)
+		target := "./" + dir
+		if dir == "." {
+			target = "."
+		}
+		commands = append(commands, fmt.Sprintf("go test %s -run '^(%s)$'", target, strings.Join(names, "|")))
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+// DOT 输出 Graphviz 的调用图，每个包一个子图：变化的函数为红色，测试为绿色，导出的 API 加粗
+func (r *Report) DOT() string {
+	var builder strings.Builder
+	builder.WriteString("digraph impact {\n")
+	builder.WriteString("  rankdir=LR;\n")
+	builder.WriteString("  node [shape=box, fontsize=10];\n")
+
+	byPackage := map[string][]FuncImpact{}
+	var packages []string
+	for _, f := range r.Functions {
+		if _, ok := byPackage[f.Package]; !ok {
+			packages = append(packages, f.Package)
+		}
+		byPackage[f.Package] = append(byPackage[f.Package], f)
+	}
+	sort.Strings(packages)
+	for i, pkg := range packages {
+		fmt.Fprintf(&builder, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&builder, "    label=%s;\n", strconv.Quote(pkg))
+		for _, f := range byPackage[pkg] {
+			attrs := []string{"label=" + strconv.Quote(strings.TrimPrefix(f.ID, f.Package+"."))}
+			switch {
+			case f.Depth == 0:
+				attrs = append(attrs, `style=filled`, `fillcolor="#f4cccc"`)
+			case f.Test:
+				attrs = append(attrs, `style=filled`, `fillcolor="#d9ead3"`)
+			}
+			if f.Exported && !f.Test {
+				attrs = append(attrs, "penwidth=2")
+			}
+			fmt.Fprintf(&builder, "    %s [%s];\n", strconv.Quote(f.ID), strings.Join(attrs, ", "))
+		}
+		builder.WriteString("  }\n")
+	}
+	for _, edge := range r.Edges {
+		fmt.Fprintf(&builder, "  %s -> %s;\n", strconv.Quote(edge.Caller), strconv.Quote(edge.Callee))
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
@@ -0,0 +1,20 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"codetest/internal/usecase/prompt"
+)
+
+func TestExplainImpact(t *testing.T) {
+	uc := NewAiCode(llmForTest(t, []string{"风险：中。Store.Get 的返回值变化会影响 api.Handle。\n"}), prompt.Default())
+
+	narrative, err := uc.ExplainImpact(context.Background(), "svc", "Impact of 1 changed functions in svc\n")
+	if err != nil {
+		t.Fatalf("ExplainImpact: %v", err)
+	}
+	if narrative != "风险：中。Store.Get 的返回值变化会影响 api.Handle。" {
+		t.Errorf("narrative = %q", narrative)
+	}
+}
@@ -6,20 +6,34 @@ import (
 )
 
 type LLMClient interface {
-	GetResponse(prompt string) (string, error)
+	GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error)
 }
 
-type Logger interface {
-	LogDetail(text string)
+type AICodeUseCase interface {
+	AIAnalysisCode(ctx context.Context, filename, code string) (string, entity.ParsedYAML, error)
+	AIQuestion(ctx context.Context, summaryContent, question, helpInfo string) ([]string, error)
+	PromptVersion(name string) string
+	FileAnalysisPrompt(filename, code string) (string, error)
+	SummarizeProject(ctx context.Context, projectName string, files []entity.FileResult, reuse map[string]entity.PackageSummary) (entity.ProjectSummary, error)
+	ReviewPatch(ctx context.Context, projectName, patch, summary string) (entity.Review, error)
+	ExplainImpact(ctx context.Context, projectName, report string) (string, error)
 }
 
-type AICodeUseCase interface {
-	AIAnalysisCode(filename, code string) (string, entity.ParsedYAML, error)
-	AIQuestion(summaryContent, question, helpInfo string) ([]string, error)
-	UploadCodeInfo(ctx context.Context, data entity.AICodeSnippet) error
+// Sink 分析结果的输出目标，例如本地文件或远程的 workflow server
+type Sink interface {
+	Name() string
+	// SaveFileResult 保存单个文件的分析结果
+	SaveFileResult(ctx context.Context, result entity.FileResult) error
+	// Close 在所有文件处理完成后调用，summary 为本次运行的分层项目总结
+	Close(ctx context.Context, summary entity.ProjectSummary) error
+}
+
+// Flusher 缓冲结果的 Sink 可以实现的接口，运行没有完成就结束时调用 Flush 保存已缓冲的结果
+type Flusher interface {
+	Flush(ctx context.Context) error
 }
 
-type ApiClient interface {
-	Login(ctx context.Context) (string, error)
-	UploadCodeInfo(ctx context.Context, data entity.AICodeSnippet) (string, error)
+// Remover 可以删除单个文件结果的 Sink 实现，用于 analyze --since、--diff 中删除或重命名的文件
+type Remover interface {
+	RemoveFileResult(ctx context.Context, path string) error
 }
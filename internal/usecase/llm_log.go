@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"codetest/internal/entity"
+)
+
+// LoggingClient 转发调用到真实的 LLM 客户端，在日志中记录调用的阶段、文件、耗时和 token 数，
+// 完整的 prompt 和 response 由 TranscriptRecorder 记录
+type LoggingClient struct {
+	inner LLMClient
+}
+
+// NewLoggingClient 创建新的 LoggingClient
+func NewLoggingClient(inner LLMClient) *LoggingClient {
+	return &LoggingClient{inner: inner}
+}
+
+// GetResponse 调用真实客户端并记录调用
+func (c *LoggingClient) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
+	start := time.Now()
+	response, err := c.inner.GetResponse(ctx, prompt)
+	attrs := []any{
+		slog.String("stage", StageFromContext(ctx)),
+		slog.String("file", FileFromContext(ctx)),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("model", response.Model),
+		slog.Int("prompt_tokens", response.PromptTokens),
+		slog.Int("completion_tokens", response.CompletionTokens),
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "LLM call failed", append(attrs, slog.Any("error", err))...)
+	} else {
+		slog.DebugContext(ctx, "LLM call", attrs...)
+	}
+	return response, err
+}
+
+// Embed 实现 Embedder，调用真实客户端并记录调用
+func (c *LoggingClient) Embed(ctx context.Context, texts []string) (entity.EmbeddingResponse, error) {
+	start := time.Now()
+	response, err := Embed(ctx, c.inner, texts)
+	attrs := []any{
+		slog.String("stage", StageFromContext(ctx)),
+		slog.Int("texts", len(texts)),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("model", response.Model),
+		slog.Int("prompt_tokens", response.PromptTokens),
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "LLM embedding call failed", append(attrs, slog.Any("error", err))...)
+	} else {
+		slog.DebugContext(ctx, "LLM embedding call", attrs...)
+	}
+	return response, err
+}
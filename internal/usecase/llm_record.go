@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"codetest/internal/entity"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LLMRecord 一次 LLM 调用的 prompt 和 response
+type LLMRecord struct {
+	PromptHash       string `yaml:"prompt_hash"`
+	Prompt           string `yaml:"prompt"`
+	Response         string `yaml:"response"`
+	Model            string `yaml:"model,omitempty"`
+	PromptTokens     int    `yaml:"prompt_tokens,omitempty"`
+	CompletionTokens int    `yaml:"completion_tokens,omitempty"`
+	Error            string `yaml:"error,omitempty"` // 调用失败时的错误，回放时原样返回
+
+	Embeddings [][]float32 `yaml:"embeddings,omitempty"` // 向量请求的结果，Prompt 为 EmbeddingPrompt
+}
+
+// PromptHash 计算 prompt 的哈希，用于回放时匹配记录
+func PromptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordingClient 转发调用到真实的 LLM 客户端，并记录每一次的 prompt 和 response
+type RecordingClient struct {
+	inner   LLMClient
+	mutex   sync.Mutex
+	records []LLMRecord
+}
+
+// NewRecordingClient 创建新的 RecordingClient
+func NewRecordingClient(inner LLMClient) *RecordingClient {
+	return &RecordingClient{inner: inner}
+}
+
+// GetResponse 调用真实客户端并记录结果
+func (c *RecordingClient) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
+	response, err := c.inner.GetResponse(ctx, prompt)
+	if err != nil {
+		return response, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.records = append(c.records, LLMRecord{
+		PromptHash:       PromptHash(prompt),
+		Prompt:           prompt,
+		Response:         response.Content,
+		Model:            response.Model,
+		PromptTokens:     response.PromptTokens,
+		CompletionTokens: response.CompletionTokens,
+	})
+	return response, nil
+}
+
+// Records 返回已记录的调用
+func (c *RecordingClient) Records() []LLMRecord {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]LLMRecord(nil), c.records...)
+}
+
+// Save 把记录写入 golden 文件
+func (c *RecordingClient) Save(file string) error {
+	data, err := yaml.Marshal(c.Records())
+	if err != nil {
+		return fmt.Errorf("failed to encode LLM records: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create golden directory: %v", err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write golden file: %v", err)
+	}
+	return nil
+}
+
+// ReplayClient 根据 prompt 回放 golden 文件或 transcript 中记录的 response，不访问网络。
+// 同一个 prompt 有多条记录时按记录顺序依次返回，用完后重复最后一条。
+type ReplayClient struct {
+	mutex   sync.Mutex
+	records map[string][]LLMRecord
+}
+
+// NewReplayClient 从 golden 文件创建 ReplayClient
+func NewReplayClient(file string) (*ReplayClient, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read golden file: %v", err)
+	}
+	var records []LLMRecord
+	if err := yaml.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode golden file: %v", err)
+	}
+	return NewReplayClientFromRecords(records), nil
+}
+
+// NewReplayClientFromRecords 从内存中的记录创建 ReplayClient
+func NewReplayClientFromRecords(records []LLMRecord) *ReplayClient {
+	client := &ReplayClient{records: map[string][]LLMRecord{}}
+	for _, record := range records {
+		hash := record.PromptHash
+		if hash == "" {
+			hash = PromptHash(record.Prompt)
+		}
+		client.records[hash] = append(client.records[hash], record)
+	}
+	return client
+}
+
+// GetResponse 返回 prompt 对应的记录，没有记录时返回错误
+func (c *ReplayClient) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
+	record, err := c.next(prompt)
+	if err != nil {
+		return entity.LLMResponse{}, err
+	}
+	return entity.LLMResponse{
+		Content:          record.Response,
+		Model:            record.Model,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+	}, nil
+}
+
+// Embed 实现 Embedder，返回 EmbeddingPrompt(texts) 对应的记录
+func (c *ReplayClient) Embed(ctx context.Context, texts []string) (entity.EmbeddingResponse, error) {
+	record, err := c.next(EmbeddingPrompt(texts))
+	if err != nil {
+		return entity.EmbeddingResponse{}, err
+	}
+	return entity.EmbeddingResponse{Vectors: record.Embeddings, Model: record.Model, PromptTokens: record.PromptTokens}, nil
+}
+
+// next 取出 prompt 的下一条记录，记录的调用失败时返回同样的错误
+func (c *ReplayClient) next(prompt string) (LLMRecord, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	hash := PromptHash(prompt)
+	records := c.records[hash]
+	if len(records) == 0 {
+		return LLMRecord{}, fmt.Errorf("no recorded response for prompt %s, re-record the golden file or transcript", hash[:12])
+	}
+	if len(records) > 1 {
+		c.records[hash] = records[1:]
+	}
+	record := records[0]
+	if record.Error != "" {
+		return LLMRecord{}, errors.New(record.Error)
+	}
+	return record, nil
+}
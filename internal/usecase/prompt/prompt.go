@@ -0,0 +1,213 @@
+// Package prompt 管理 LLM 提示词模板。
+// 模板使用 text/template 编写并内嵌在程序中，可以通过外部目录覆盖，
+// 每个模板都带有版本号，随分析结果一起保存。
+package prompt
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"codetest/internal/entity"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 模板名称
+const (
+	FileAnalysis          = "file_analysis"
+	QuestionRelFiles      = "question_rel_files"
+	QuestionRelFilesParse = "question_rel_files_parse"
+	FinalAnswer           = "final_answer"
+	YAMLRepair            = "yaml_repair"
+	PackageSummary        = "package_summary"
+	ProjectOverview       = "project_overview"
+	ProjectDescription    = "project_description"
+	CodeReview            = "code_review"
+	ImpactRisk            = "impact_risk"
+)
+
+// Names 所有模板的名称
+var Names = []string{
+	FileAnalysis, QuestionRelFiles, QuestionRelFilesParse, FinalAnswer, YAMLRepair,
+	PackageSummary, ProjectOverview, ProjectDescription, CodeReview,
+	ImpactRisk,
+}
+
+// 支持的输出语言
+const (
+	LanguageZh = "zh"
+	LanguageEn = "en"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+var versionRegex = regexp.MustCompile(`^\{\{-?\s*/\*\s*version:\s*(\S+)\s*\*/\s*-?\}\}`)
+
+// GlossaryTerm 术语表中的一项
+type GlossaryTerm struct {
+	Term    string
+	Meaning string
+}
+
+// Data 渲染模板时可以使用的数据，Glossary 由 Set 自动填充
+type Data struct {
+	Filename    string
+	Code        string
+	Question    string
+	Summary     string
+	Step1Answer string
+	HelpInfo    string
+	Files       []*entity.Step1FileInfo
+	YAML        string
+	Diff        string
+	Error       string
+	Project     string
+	Package     string
+	MaxLength   int
+	Glossary    []GlossaryTerm
+}
+
+// Set 一组同一语言的提示词模板
+type Set struct {
+	language  string
+	glossary  []GlossaryTerm
+	templates map[string]*template.Template
+	versions  map[string]string
+}
+
+// NewSet 加载指定语言的模板，dir 不为空时优先使用 dir/<language>/<name>.tmpl 或 dir/<name>.tmpl
+func NewSet(dir, language string, glossary []GlossaryTerm) (*Set, error) {
+	if language == "" {
+		language = LanguageZh
+	}
+	if language != LanguageZh && language != LanguageEn {
+		return nil, fmt.Errorf("unsupported output language %q, expected zh or en", language)
+	}
+
+	set := &Set{
+		language:  language,
+		glossary:  glossary,
+		templates: map[string]*template.Template{},
+		versions:  map[string]string{},
+	}
+	for _, name := range Names {
+		text, custom, err := readTemplate(dir, language, name)
+		if err != nil {
+			return nil, err
+		}
+		tpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %v", name, err)
+		}
+		set.templates[name] = tpl
+		set.versions[name] = templateVersion(name, language, text, custom)
+	}
+	return set, nil
+}
+
+// Default 返回内嵌的中文模板
+func Default() *Set {
+	set, err := NewSet("", LanguageZh, nil)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// Render 渲染指定模板
+func (s *Set) Render(name string, data Data) (string, error) {
+	tpl, ok := s.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt template %s", name)
+	}
+	data.Glossary = s.glossary
+
+	var builder strings.Builder
+	if err := tpl.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %s: %v", name, err)
+	}
+	return builder.String(), nil
+}
+
+// Version 返回模板的版本号，形如 file_analysis.zh@v2，自定义模板没有声明版本时使用内容哈希
+func (s *Set) Version(name string) string {
+	return s.versions[name]
+}
+
+// Language 返回模板的输出语言
+func (s *Set) Language() string {
+	return s.language
+}
+
+// readTemplate 读取模板内容，custom 表示来自外部目录
+func readTemplate(dir, language, name string) (string, bool, error) {
+	file := name + ".tmpl"
+	if dir != "" {
+		for _, candidate := range []string{filepath.Join(dir, language, file), filepath.Join(dir, file)} {
+			data, err := os.ReadFile(candidate)
+			if err == nil {
+				return string(data), true, nil
+			}
+			if !os.IsNotExist(err) {
+				return "", false, fmt.Errorf("failed to read prompt template %s: %v", candidate, err)
+			}
+		}
+	}
+
+	data, err := embedded.ReadFile(path.Join("templates", language, file))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read embedded prompt template %s: %v", name, err)
+	}
+	return string(data), false, nil
+}
+
+// templateVersion 解析模板头部的版本声明
+func templateVersion(name, language, text string, custom bool) string {
+	version := ""
+	if match := versionRegex.FindStringSubmatch(text); match != nil {
+		version = match[1]
+	}
+	if custom {
+		sum := sha256.Sum256([]byte(text))
+		hash := hex.EncodeToString(sum[:])[:8]
+		if version == "" {
+			version = "custom-" + hash
+		} else {
+			version += "+custom-" + hash
+		}
+	}
+	return fmt.Sprintf("%s.%s@%s", name, language, version)
+}
+
+// LoadGlossary 从 YAML 文件加载术语表，文件内容为 术语: 解释 的映射
+func LoadGlossary(file string) ([]GlossaryTerm, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read glossary file: %v", err)
+	}
+
+	var terms map[string]string
+	if err := yaml.Unmarshal(data, &terms); err != nil {
+		return nil, fmt.Errorf("failed to decode glossary file: %v", err)
+	}
+
+	glossary := make([]GlossaryTerm, 0, len(terms))
+	for term, meaning := range terms {
+		glossary = append(glossary, GlossaryTerm{Term: term, Meaning: meaning})
+	}
+	sort.Slice(glossary, func(i, j int) bool { return glossary[i].Term < glossary[j].Term })
+	return glossary, nil
+}
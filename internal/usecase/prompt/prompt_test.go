@@ -0,0 +1,64 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedTemplatesRender(t *testing.T) {
+	for _, language := range []string{LanguageZh, LanguageEn} {
+		set, err := NewSet("", language, []GlossaryTerm{{Term: "artifact", Meaning: "制品"}})
+		if err != nil {
+			t.Fatalf("NewSet(%s): %v", language, err)
+		}
+		for _, name := range Names {
+			text, err := set.Render(name, Data{Filename: "main.go", Code: "package main", Question: "q", YAML: "a: [", Error: "bad"})
+			if err != nil {
+				t.Fatalf("Render(%s/%s): %v", language, name, err)
+			}
+			if strings.Contains(text, "version:") {
+				t.Errorf("%s/%s: version header leaked into prompt", language, name)
+			}
+			if name != YAMLRepair && !strings.Contains(text, "artifact") {
+				t.Errorf("%s/%s: glossary missing from prompt", language, name)
+			}
+			if !strings.HasPrefix(set.Version(name), name+"."+language+"@v") {
+				t.Errorf("%s/%s: unexpected version %q", language, name, set.Version(name))
+			}
+		}
+	}
+}
+
+func TestOverrideTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, LanguageEn), 0755); err != nil {
+		t.Fatal(err)
+	}
+	custom := "{{/* version: team-3 */}}Describe {{.Filename}}"
+	if err := os.WriteFile(filepath.Join(dir, LanguageEn, FileAnalysis+".tmpl"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := NewSet(dir, LanguageEn, nil)
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	text, err := set.Render(FileAnalysis, Data{Filename: "a.go"})
+	if err != nil || text != "Describe a.go" {
+		t.Fatalf("Render = %q, %v", text, err)
+	}
+	if version := set.Version(FileAnalysis); !strings.HasPrefix(version, "file_analysis.en@team-3+custom-") {
+		t.Errorf("Version = %q", version)
+	}
+	if version := set.Version(FinalAnswer); version != "final_answer.en@v1" {
+		t.Errorf("embedded template version = %q", version)
+	}
+}
+
+func TestUnsupportedLanguage(t *testing.T) {
+	if _, err := NewSet("", "fr", nil); err == nil {
+		t.Fatal("expected an error for an unsupported language")
+	}
+}
@@ -3,55 +3,433 @@ package repo
 
 import (
 	"codetest/internal/entity"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 输出目录的布局
+const (
+	resultsDir    = "files"      // 分析结果按源码目录结构保存在这个子目录中
+	indexFileName = "index.json" // 源码路径到分析结果和元数据的索引
+
+	// indexFlushEvery 索引在内存中积累这么多条修改后写回一次，其余在 Flush 或 Close 时写回
+	indexFlushEvery = 50
 )
 
 // CodeSummary 结构体用于封装文件操作
 type CodeSummary struct {
-	OutputDir string
+	OutputDir      string
+	SourceDir      string   // 被分析的源码根目录，分析结果按相对它的路径保存
+	SummaryFormats []string // Close 时生成的文件总结格式，为空时只生成 Markdown
+
+	mutex sync.Mutex
+	index *Index // 延迟加载
+	dirty int    // 还没有写回索引文件的修改数
+}
+
+// Index 输出目录中的索引文件
+type Index struct {
+	SourceDir string                `json:"source_dir"`
+	Files     map[string]IndexEntry `json:"files"` // key 为相对源码根目录的路径（使用 /）
 }
 
-// NewCodeSummaryRepo 返回一个新的 CodeSummary 实例
-func NewCodeSummaryRepo(outputDir string) *CodeSummary {
+// IndexEntry 一个源码文件的分析结果及元数据
+type IndexEntry struct {
+	Source         string    `json:"source"` // 相对源码根目录的路径
+	Result         string    `json:"result"` // 相对输出目录的分析结果路径
+	Model          string    `json:"model"`
+	PromptVersion  string    `json:"prompt_version"`
+	OutputLanguage string    `json:"output_language"`
+	Hash           string    `json:"hash"` // 源码内容的 sha256
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// NewCodeSummaryRepo 返回一个新的 CodeSummary 实例，sourceDir 为被分析的源码根目录
+func NewCodeSummaryRepo(outputDir, sourceDir string) *CodeSummary {
 	return &CodeSummary{
 		OutputDir: outputDir,
+		SourceDir: sourceDir,
+	}
+}
+
+// RelPath 返回 path 相对源码根目录的路径（使用 /），path 不在源码根目录下时返回错误
+func (r *CodeSummary) RelPath(path string) (string, error) {
+	return relPath(r.SourceDir, path)
+}
+
+// ResultPath 返回文件分析结果的保存路径：<OutputDir>/files/<相对源码根目录的路径>.yaml
+func (r *CodeSummary) ResultPath(path string) (string, error) {
+	rel, err := r.RelPath(path)
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(r.OutputDir, resultsDir, filepath.FromSlash(rel)+".yaml"), nil
 }
 
-// SaveAIResult 保存 AI 分析结果到文件
-func (r *CodeSummary) SaveAIResult(projectName, path, rawAiResponse string) error {
-	resultPath := filepath.Join(r.OutputDir, strings.ReplaceAll(path, "/", "|")+".yaml")
-	fmt.Println("resultPath = ", resultPath)
-	if err := os.WriteFile(resultPath, []byte(rawAiResponse), 0644); err != nil {
+// SaveAIResult 保存 AI 分析结果到文件，结果的元数据以注释的形式写在文件头部
+func (r *CodeSummary) SaveAIResult(projectName, path, rawAiResponse string, meta entity.ResultMeta) error {
+	resultPath, err := r.ResultPath(path)
+	if err != nil {
+		return err
+	}
+	slog.Debug("Saving analysis result", "file", path, "result", resultPath)
+
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("# model: %s\n", meta.Model))
+	content.WriteString(fmt.Sprintf("# prompt_version: %s\n", meta.PromptVersion))
+	content.WriteString(fmt.Sprintf("# output_language: %s\n", meta.OutputLanguage))
+	content.WriteString(rawAiResponse)
+	if err := writeFileAtomic(resultPath, []byte(content.String())); err != nil {
 		return fmt.Errorf("error writing result file: %v", err)
 	}
 	return nil
 }
 
-// UpdateSummaryFile 更新总结文件
-func (r *CodeSummary) UpdateSummaryFile(projectName, path string, yamlResult *entity.ParsedYAML) error {
-	var strBuilder strings.Builder
+// LoadAIResult 读取 SaveAIResult 保存的分析结果，返回去掉元数据注释后的 YAML。
+// 新布局中没有结果时读取旧版本以 | 代替 / 的扁平文件名。
+func (r *CodeSummary) LoadAIResult(path string) (string, entity.ResultMeta, error) {
+	resultPath, err := r.ResultPath(path)
+	if err != nil {
+		return "", entity.ResultMeta{}, err
+	}
+	data, err := os.ReadFile(resultPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// 旧文件名在 Windows 上不合法，读取失败时仍然返回不存在
+		if legacy, legacyErr := os.ReadFile(filepath.Join(r.OutputDir, strings.ReplaceAll(path, "/", "|")+".yaml")); legacyErr == nil {
+			data, err = legacy, nil
+		}
+	}
+	if err != nil {
+		return "", entity.ResultMeta{}, err
+	}
+	content, meta := parseResult(data)
+	return content, meta, nil
+}
+
+// parseResult 把保存的分析结果拆分为 YAML 内容和头部注释中的元数据
+func parseResult(data []byte) (string, entity.ResultMeta) {
+	var meta entity.ResultMeta
+	content := string(data)
+	for strings.HasPrefix(content, "# ") {
+		line, rest, _ := strings.Cut(content, "\n")
+		key, value, _ := strings.Cut(strings.TrimPrefix(line, "# "), ":")
+		switch key {
+		case "model":
+			meta.Model = strings.TrimSpace(value)
+		case "prompt_version":
+			meta.PromptVersion = strings.TrimSpace(value)
+		case "output_language":
+			meta.OutputLanguage = strings.TrimSpace(value)
+		default:
+			return content, meta
+		}
+		content = rest
+	}
+	return content, meta
+}
+
+// CachedResult 返回 path 之前保存的分析结果，源码内容、模型、提示词版本或输出语言有变化时返回 false
+func (r *CodeSummary) CachedResult(path, code string, meta entity.ResultMeta) (entity.FileResult, bool) {
+	rel, err := r.RelPath(path)
+	if err != nil {
+		return entity.FileResult{}, false
+	}
+	r.mutex.Lock()
+	index, err := r.loadIndex()
+	var entry IndexEntry
+	if err == nil {
+		entry = index.Files[rel]
+	}
+	r.mutex.Unlock()
+	if err != nil || entry.Hash != entity.ContentHash(code) || entry.Model != meta.Model ||
+		entry.PromptVersion != meta.PromptVersion || entry.OutputLanguage != meta.OutputLanguage {
+		return entity.FileResult{}, false
+	}
+
+	raw, _, err := r.LoadAIResult(path)
+	if err != nil {
+		return entity.FileResult{}, false
+	}
+	result := entity.FileResult{Path: path, Code: code, Raw: raw, Meta: meta}
+	if err := yaml.Unmarshal([]byte(raw), &result.Parsed); err != nil {
+		return entity.FileResult{}, false
+	}
+	return result, true
+}
+
+// LoadIndex 读取输出目录中的索引，索引不存在时返回空索引
+func (r *CodeSummary) LoadIndex() (Index, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	index, err := r.loadIndex()
+	if err != nil {
+		return Index{}, err
+	}
+	return *index, nil
+}
+
+func (r *CodeSummary) loadIndex() (*Index, error) {
+	if r.index != nil {
+		return r.index, nil
+	}
+	index := &Index{Files: map[string]IndexEntry{}}
+	data, err := os.ReadFile(filepath.Join(r.OutputDir, indexFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read result index: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, index); err != nil {
+			return nil, fmt.Errorf("failed to decode result index: %v", err)
+		}
+		if index.Files == nil {
+			index.Files = map[string]IndexEntry{}
+		}
+	}
+	r.index = index
+	return index, nil
+}
+
+// updateIndex 记录一个文件的分析结果
+func (r *CodeSummary) updateIndex(result entity.FileResult) error {
+	rel, err := r.RelPath(result.Path)
+	if err != nil {
+		return err
+	}
+	return r.putIndexEntry(IndexEntry{
+		Source:         rel,
+		Result:         resultsDir + "/" + rel + ".yaml",
+		Model:          result.Meta.Model,
+		PromptVersion:  result.Meta.PromptVersion,
+		OutputLanguage: result.Meta.OutputLanguage,
+		Hash:           entity.ContentHash(result.Code),
+		UpdatedAt:      time.Now().UTC(),
+	})
+}
+
+// putIndexEntry 在内存中写入一条索引记录，每 indexFlushEvery 条修改写回一次索引文件
+func (r *CodeSummary) putIndexEntry(entry IndexEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	index, err := r.loadIndex()
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(r.SourceDir); err == nil {
+		index.SourceDir = abs
+	}
+	index.Files[entry.Source] = entry
+	return r.markIndexDirty()
+}
+
+// removeIndexEntry 在内存中删除 rel 的索引记录，每 indexFlushEvery 条修改写回一次索引文件
+func (r *CodeSummary) removeIndexEntry(rel string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	index, err := r.loadIndex()
+	if err != nil {
+		return err
+	}
+	if _, ok := index.Files[rel]; !ok {
+		return nil
+	}
+	delete(index.Files, rel)
+	return r.markIndexDirty()
+}
+
+// markIndexDirty 记录一条未写回的修改，积累到 indexFlushEvery 条时写回，调用方需要持有 mutex
+func (r *CodeSummary) markIndexDirty() error {
+	r.dirty++
+	if r.dirty < indexFlushEvery {
+		return nil
+	}
+	return r.writeIndex()
+}
+
+// writeIndex 有未写回的修改时写回索引文件，调用方需要持有 mutex
+func (r *CodeSummary) writeIndex() error {
+	if r.dirty == 0 || r.index == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result index: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(r.OutputDir, indexFileName), data); err != nil {
+		return fmt.Errorf("failed to write result index: %v", err)
+	}
+	r.dirty = 0
+	return nil
+}
+
+// Flush 实现 usecase.Flusher，把内存中修改过的索引写回索引文件
+func (r *CodeSummary) Flush(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writeIndex()
+}
 
-	strBuilder.WriteString(fmt.Sprintf("文件名: %s\n", path))
-	strBuilder.WriteString(fmt.Sprintf("功能: %s\n", yamlResult.FileDescription))
-	strBuilder.WriteString(fmt.Sprintf("包名: %s\n", yamlResult.FileInfo.PackageName))
-	strBuilder.WriteString("依赖导入项目: ")
-	strBuilder.WriteString(strings.Join(yamlResult.FileInfo.Imports, ","))
-	strBuilder.WriteString("\n---\n")
+// Name 实现 usecase.Sink
+func (r *CodeSummary) Name() string {
+	return "local"
+}
+
+// SaveFileResult 把分析结果写入输出目录并更新内存中的索引，索引在 Flush 或 Close 时写回，summary.md 在 Close 时重新生成
+func (r *CodeSummary) SaveFileResult(ctx context.Context, result entity.FileResult) error {
+	if err := r.SaveAIResult(result.ProjectName, result.Path, result.Raw, result.Meta); err != nil {
+		return err
+	}
+	return r.updateIndex(result)
+}
 
-	// 追加写入总结文件
-	summaryFilePath := filepath.Join(r.OutputDir, "summary.md")
-	file, err := os.OpenFile(summaryFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// RemoveFileResult 实现 usecase.Remover，删除文件的分析结果和索引记录
+func (r *CodeSummary) RemoveFileResult(ctx context.Context, path string) error {
+	rel, err := r.RelPath(path)
+	if err != nil {
+		return err
+	}
+	resultPath, err := r.ResultPath(path)
 	if err != nil {
-		return fmt.Errorf("failed to open summary file: %v", err)
+		return err
+	}
+	if err := os.Remove(resultPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove result of %s: %v", rel, err)
+	}
+	return r.removeIndexEntry(rel)
+}
+
+// Close 写回索引，用保存的分析结果重新生成文件总结（summary.md 等），并把分层总结写入 summaries 目录：
+// overview.md 为项目概览，description.txt 为简洁的项目描述，packages 下每个包一个文档，summary.json 保存完整的结构化总结
+func (r *CodeSummary) Close(ctx context.Context, summary entity.ProjectSummary) error {
+	if err := r.Flush(ctx); err != nil {
+		return err
+	}
+	if err := r.RebuildSummary(summary); err != nil {
+		return err
 	}
-	defer file.Close()
 
-	if _, err := file.WriteString(strBuilder.String()); err != nil {
-		return fmt.Errorf("failed to write to summary file: %v", err)
+	dir := filepath.Join(r.OutputDir, "summaries")
+	packagesDir := filepath.Join(dir, "packages")
+	if err := os.MkdirAll(packagesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create summaries directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode project summary: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "summary.json"), data); err != nil {
+		return fmt.Errorf("failed to write project summary: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "overview.md"), []byte(summary.Overview+"\n")); err != nil {
+		return fmt.Errorf("failed to write project overview: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "description.txt"), []byte(summary.Description+"\n")); err != nil {
+		return fmt.Errorf("failed to write project description: %v", err)
+	}
+	for _, pkg := range summary.Packages {
+		var content strings.Builder
+		content.WriteString(fmt.Sprintf("# %s\n\n", pkg.Path))
+		content.WriteString(pkg.Summary)
+		content.WriteString("\n\n## 文件\n\n")
+		for _, file := range pkg.Files {
+			content.WriteString(fmt.Sprintf("- %s\n", file))
+		}
+		name := strings.ReplaceAll(filepath.ToSlash(pkg.Path), "/", "_") + ".md"
+		if pkg.Path == "." {
+			name = "_root.md"
+		}
+		if err := writeFileAtomic(filepath.Join(packagesDir, name), []byte(content.String())); err != nil {
+			return fmt.Errorf("failed to write package summary %s: %v", pkg.Path, err)
+		}
 	}
 	return nil
 }
+
+// LoadProjectSummary 读取 Close 保存的结构化项目总结
+func (r *CodeSummary) LoadProjectSummary() (entity.ProjectSummary, error) {
+	var summary entity.ProjectSummary
+	data, err := os.ReadFile(filepath.Join(r.OutputDir, "summaries", "summary.json"))
+	if err != nil {
+		return summary, err
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return summary, fmt.Errorf("failed to decode project summary: %v", err)
+	}
+	return summary, nil
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名，避免中断时留下写了一半的文件，目录不存在时自动创建
+func writeFileAtomic(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// relPath 返回 path 相对 root 的路径（使用 /），root 为空时使用当前目录，path 不在 root 下时返回错误
+func relPath(root, path string) (string, error) {
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not a file inside the source directory %s", path, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// relDir 返回目录 dir 相对 root 的路径，root 本身为 "."
+func relDir(root, dir string) (string, bool) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	if absDir == absRoot {
+		return ".", true
+	}
+	rel, err := relPath(root, dir)
+	return rel, err == nil
+}
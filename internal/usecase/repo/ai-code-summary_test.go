@@ -0,0 +1,164 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+func TestSaveFileResultMirrorsSourceTree(t *testing.T) {
+	source := t.TempDir()
+	output := filepath.Join(t.TempDir(), "nested", "result") // 输出目录不存在时自动创建
+	r := NewCodeSummaryRepo(output, source)
+
+	path := filepath.Join(source, "internal", "store", "store.go")
+	result := entity.FileResult{
+		ProjectName: "demo",
+		Path:        path,
+		Code:        "package store\n",
+		Raw:         "file_description: 存储\n",
+		Meta:        entity.ResultMeta{Model: "gpt-4o-mini", PromptVersion: "v2", OutputLanguage: "zh"},
+	}
+	if err := r.SaveFileResult(context.Background(), result); err != nil {
+		t.Fatal(err)
+	}
+
+	resultPath := filepath.Join(output, "files", "internal", "store", "store.go.yaml")
+	if got, err := r.ResultPath(path); err != nil || got != resultPath {
+		t.Fatalf("ResultPath = %q, %v", got, err)
+	}
+	raw, meta, err := r.LoadAIResult(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw != result.Raw || meta != result.Meta {
+		t.Errorf("LoadAIResult = %q, %+v", raw, meta)
+	}
+
+	// 索引在 Flush 时才写回，新实例从磁盘读取索引
+	if _, err := os.Stat(filepath.Join(output, "index.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("index written before Flush: %v", err)
+	}
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	index, err := NewCodeSummaryRepo(output, source).LoadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := index.Files["internal/store/store.go"]
+	if !ok {
+		t.Fatalf("index = %+v", index)
+	}
+	if entry.Result != "files/internal/store/store.go.yaml" || entry.Model != "gpt-4o-mini" ||
+		entry.PromptVersion != "v2" || entry.Hash != entity.ContentHash(result.Code) || entry.UpdatedAt.IsZero() {
+		t.Errorf("index entry = %+v", entry)
+	}
+
+	// 没有留下临时文件
+	entries, err := os.ReadDir(filepath.Dir(resultPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("result directory has %d entries, want 1", len(entries))
+	}
+
+	if err := r.RemoveFileResult(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(resultPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("result file still exists: %v", err)
+	}
+	if index, _ := NewCodeSummaryRepo(output, source).LoadIndex(); len(index.Files) != 0 {
+		t.Errorf("index after remove = %+v", index)
+	}
+}
+
+func TestIndexIsWrittenEveryFewFiles(t *testing.T) {
+	source := t.TempDir()
+	output := t.TempDir()
+	r := NewCodeSummaryRepo(output, source)
+	for i := 0; i < indexFlushEvery+1; i++ {
+		path := filepath.Join(source, fmt.Sprintf("f%d.go", i))
+		if err := r.SaveFileResult(context.Background(), entity.FileResult{Path: path, Raw: "file_description: x\n"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 第 indexFlushEvery 个文件时写回一次，之后的修改留在内存中
+	if index, err := NewCodeSummaryRepo(output, source).LoadIndex(); err != nil || len(index.Files) != indexFlushEvery {
+		t.Fatalf("index on disk has %d files, %v; want %d", len(index.Files), err, indexFlushEvery)
+	}
+	if err := r.Close(context.Background(), entity.ProjectSummary{}); err != nil {
+		t.Fatal(err)
+	}
+	if index, _ := NewCodeSummaryRepo(output, source).LoadIndex(); len(index.Files) != indexFlushEvery+1 {
+		t.Errorf("index after Close has %d files", len(index.Files))
+	}
+}
+
+func TestResultPathRejectsPathsOutsideSource(t *testing.T) {
+	source := t.TempDir()
+	r := NewCodeSummaryRepo(t.TempDir(), source)
+	for _, path := range []string{filepath.Join(source, "..", "other.go"), source, filepath.Join(t.TempDir(), "x.go")} {
+		if _, err := r.ResultPath(path); err == nil || !strings.Contains(err.Error(), "not a file inside") {
+			t.Errorf("ResultPath(%q) error = %v", path, err)
+		}
+	}
+}
+
+func TestLoadAIResultReadsLegacyLayout(t *testing.T) {
+	output := t.TempDir()
+	r := NewCodeSummaryRepo(output, ".")
+	legacy := filepath.Join(output, "cmd|root.go.yaml")
+	if err := os.WriteFile(legacy, []byte("# prompt_version: v1\nfile_description: x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	raw, meta, err := r.LoadAIResult("cmd/root.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw != "file_description: x\n" || meta.PromptVersion != "v1" {
+		t.Errorf("LoadAIResult = %q, %+v", raw, meta)
+	}
+}
+
+func TestCachedResult(t *testing.T) {
+	source := t.TempDir()
+	path := filepath.Join(source, "auth", "service.go")
+	meta := entity.ResultMeta{Model: "gpt-4o-mini", PromptVersion: "v2", OutputLanguage: "zh"}
+	saved := NewCodeSummaryRepo(t.TempDir(), source)
+	if err := saved.SaveFileResult(context.Background(), entity.FileResult{Path: path, Code: "package auth", Raw: storeAnalysis, Meta: meta}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saved.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewCodeSummaryRepo(saved.OutputDir, source)
+	result, ok := cache.CachedResult(path, "package auth", meta)
+	if !ok || result.Raw != storeAnalysis || result.Parsed.FileDescription != "用户登录" || result.Meta != meta {
+		t.Fatalf("CachedResult = %+v, %t", result, ok)
+	}
+
+	changed := meta
+	changed.PromptVersion = "v3"
+	for name, miss := range map[string]func() bool{
+		"code":    func() bool { _, ok := cache.CachedResult(path, "package auth // changed", meta); return ok },
+		"prompt":  func() bool { _, ok := cache.CachedResult(path, "package auth", changed); return ok },
+		"missing": func() bool { _, ok := cache.CachedResult(filepath.Join(source, "a.go"), "package a", meta); return ok },
+	} {
+		if miss() {
+			t.Errorf("a changed %s must not hit the cache", name)
+		}
+	}
+}
@@ -0,0 +1,728 @@
+package repo
+
+import (
+	"codetest/internal/entity"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	_ "modernc.org/sqlite"
+)
+
+// DefaultDBFileName SQLite 数据库在输出目录中的默认文件名
+const DefaultDBFileName = "analysis.db"
+
+// schema 数据库结构。路径都是相对源码根目录的路径（使用 /），包的路径为 path.Dir(文件路径)。
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	project     TEXT NOT NULL,
+	model       TEXT NOT NULL DEFAULT '',
+	started_at  TEXT NOT NULL,
+	finished_at TEXT,
+	cost        REAL NOT NULL DEFAULT 0,
+	currency    TEXT NOT NULL DEFAULT '',
+	commit_sha  TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS files (
+	path            TEXT PRIMARY KEY,
+	package         TEXT NOT NULL,
+	raw             TEXT NOT NULL,
+	description     TEXT NOT NULL DEFAULT '',
+	package_name    TEXT NOT NULL DEFAULT '',
+	imports         TEXT NOT NULL DEFAULT '[]',
+	content_hash    TEXT NOT NULL DEFAULT '',
+	model           TEXT NOT NULL DEFAULT '',
+	prompt_version  TEXT NOT NULL DEFAULT '',
+	output_language TEXT NOT NULL DEFAULT '',
+	updated_at      TEXT NOT NULL,
+	run_id          INTEGER REFERENCES runs(id)
+);
+CREATE TABLE IF NOT EXISTS symbols (
+	file_path   TEXT NOT NULL REFERENCES files(path) ON DELETE CASCADE,
+	kind        TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	signature   TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS symbols_file ON symbols(file_path);
+CREATE INDEX IF NOT EXISTS symbols_name ON symbols(name);
+CREATE TABLE IF NOT EXISTS summaries (
+	kind  TEXT NOT NULL,
+	path  TEXT NOT NULL DEFAULT '',
+	text  TEXT NOT NULL,
+	files TEXT NOT NULL DEFAULT '[]',
+	PRIMARY KEY (kind, path)
+);
+CREATE TABLE IF NOT EXISTS embeddings (
+	file_path TEXT NOT NULL REFERENCES files(path) ON DELETE CASCADE,
+	model     TEXT NOT NULL,
+	vector    BLOB NOT NULL,
+	PRIMARY KEY (file_path, model)
+);
+CREATE TABLE IF NOT EXISTS usage (
+	run_id            INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	scope             TEXT NOT NULL,
+	key               TEXT NOT NULL,
+	calls             INTEGER NOT NULL,
+	prompt_tokens     INTEGER NOT NULL,
+	completion_tokens INTEGER NOT NULL,
+	cost              REAL NOT NULL
+);
+`
+
+// addedColumns schema 建立之后加入的列，打开旧的数据库时补上
+var addedColumns = []struct{ table, name, definition string }{
+	{"runs", "commit_sha", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// summaries 表中的总结种类
+const (
+	summaryKindOverview    = "overview"
+	summaryKindDescription = "description"
+	summaryKindPackage     = "package"
+)
+
+// SQLiteStore 把分析结果保存在嵌入式 SQLite 数据库中：文件、符号、分层总结、向量、运行记录和 token 用量。
+// 实现 usecase.Sink，也可以作为 docs 的结果来源。
+type SQLiteStore struct {
+	db        *sql.DB
+	SourceDir string // 被分析的源码根目录，文件按相对它的路径保存
+	runID     int64  // 当前运行，BeginRun 之前为 0
+}
+
+// SearchHit 搜索结果
+type SearchHit struct {
+	Kind      string  `json:"kind"` // file 或符号种类
+	Path      string  `json:"path"` // 相对源码根目录的文件路径
+	Name      string  `json:"name"`
+	Signature string  `json:"signature,omitempty"`
+	Text      string  `json:"text"`
+	Score     float64 `json:"score,omitempty"` // SemanticSearch 的余弦相似度
+}
+
+// OpenSQLiteStore 打开（不存在时创建）dbPath 处的数据库
+func OpenSQLiteStore(dbPath, sourceDir string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %v", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(dbPath)+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %v", dbPath, err)
+	}
+	// SQLite 同一时间只允许一个写入者
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create database schema in %s: %v", dbPath, err)
+	}
+	if err := addMissingColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to upgrade database schema in %s: %v", dbPath, err)
+	}
+	return &SQLiteStore{db: db, SourceDir: sourceDir}, nil
+}
+
+// addMissingColumns 给旧的数据库加上 addedColumns 中缺少的列
+func addMissingColumns(db *sql.DB) error {
+	for _, column := range addedColumns {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, column.table, column.name).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.table, column.name, column.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseDB 关闭数据库。Close 是 usecase.Sink 在运行结束时的回调，不会关闭数据库
+func (s *SQLiteStore) CloseDB() error {
+	return s.db.Close()
+}
+
+// Name 实现 usecase.Sink
+func (s *SQLiteStore) Name() string {
+	return "sqlite"
+}
+
+// BeginRun 记录一次新的运行，之后保存的文件都关联到这次运行，commit 为分析的提交 SHA，不在 git 仓库中时为空
+func (s *SQLiteStore) BeginRun(ctx context.Context, project, model, commit string) error {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO runs (project, model, started_at, commit_sha) VALUES (?, ?, ?, ?)`,
+		project, model, formatTime(time.Now()), commit)
+	if err != nil {
+		return fmt.Errorf("failed to record run: %v", err)
+	}
+	if s.runID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	return s.setMeta(ctx, "project", project)
+}
+
+// FinishRun 保存本次运行的 token 用量，按阶段、模型和文件分别记录
+func (s *SQLiteStore) FinishRun(ctx context.Context, report entity.UsageReport) error {
+	if s.runID == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE runs SET finished_at = ?, cost = ?, currency = ? WHERE id = ?`,
+		formatTime(time.Now()), report.Total.Cost, report.Currency, s.runID); err != nil {
+		return fmt.Errorf("failed to finish run: %v", err)
+	}
+	scopes := map[string]map[string]entity.TokenUsage{
+		"total": {"": report.Total},
+		"stage": report.ByStage,
+		"model": report.ByModel,
+		"file":  report.ByFile,
+	}
+	for scope, usage := range scopes {
+		for key, u := range usage {
+			if key != "" && scope == "file" {
+				if rel, err := relPath(s.SourceDir, key); err == nil {
+					key = rel
+				}
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO usage (run_id, scope, key, calls, prompt_tokens, completion_tokens, cost)
+				VALUES (?, ?, ?, ?, ?, ?, ?)`, s.runID, scope, key, u.Calls, u.PromptTokens, u.CompletionTokens, u.Cost); err != nil {
+				return fmt.Errorf("failed to record usage: %v", err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveFileResult 实现 usecase.Sink，保存文件的分析结果和其中声明的符号
+func (s *SQLiteStore) SaveFileResult(ctx context.Context, result entity.FileResult) error {
+	rel, err := relPath(s.SourceDir, result.Path)
+	if err != nil {
+		return err
+	}
+	return s.putFile(ctx, rel, result.Raw, entity.ContentHash(result.Code), result.Meta, time.Now())
+}
+
+// RemoveFileResult 实现 usecase.Remover，删除文件记录，符号和向量随之删除
+func (s *SQLiteStore) RemoveFileResult(ctx context.Context, path string) error {
+	rel, err := relPath(s.SourceDir, path)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM files WHERE path = ?`, rel); err != nil {
+		return fmt.Errorf("failed to remove %s: %v", rel, err)
+	}
+	return nil
+}
+
+// putFile 写入文件记录，替换文件原有的符号
+func (s *SQLiteStore) putFile(ctx context.Context, rel, raw, hash string, meta entity.ResultMeta, updatedAt time.Time) error {
+	var analysis entity.FileAnalysis
+	// 分析结果不是合法的 YAML 时仍然保存原文，只是没有符号
+	_ = yaml.Unmarshal([]byte(raw), &analysis)
+	imports, err := json.Marshal(nonNil(analysis.FileInfo.Imports))
+	if err != nil {
+		return err
+	}
+	var runID any
+	if s.runID != 0 {
+		runID = s.runID
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	description := strings.TrimSpace(analysis.FileDescription)
+	// 描述变化后原来的向量不再对应文件内容
+	if _, err := tx.ExecContext(ctx, `DELETE FROM embeddings WHERE file_path IN (SELECT path FROM files WHERE path = ? AND description != ?)`,
+		rel, description); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO files (path, package, raw, description, package_name, imports, content_hash,
+			model, prompt_version, output_language, updated_at, run_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET package = excluded.package, raw = excluded.raw, description = excluded.description,
+			package_name = excluded.package_name, imports = excluded.imports, content_hash = excluded.content_hash,
+			model = excluded.model, prompt_version = excluded.prompt_version, output_language = excluded.output_language,
+			updated_at = excluded.updated_at, run_id = excluded.run_id`,
+		rel, path.Dir(rel), raw, description, analysis.FileInfo.PackageName, string(imports), hash,
+		meta.Model, meta.PromptVersion, meta.OutputLanguage, formatTime(updatedAt), runID); err != nil {
+		return fmt.Errorf("failed to save %s: %v", rel, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM symbols WHERE file_path = ?`, rel); err != nil {
+		return err
+	}
+	for _, sym := range analysisSymbols(analysis) {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO symbols (file_path, kind, name, signature, description) VALUES (?, ?, ?, ?, ?)`,
+			rel, sym.Kind, sym.Name, sym.Signature, sym.Text); err != nil {
+			return fmt.Errorf("failed to save symbols of %s: %v", rel, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// analysisSymbols 从分析结果中取出声明的符号，Text 为符号的说明
+func analysisSymbols(analysis entity.FileAnalysis) []SearchHit {
+	var symbols []SearchHit
+	for _, st := range analysis.Structs {
+		symbols = append(symbols, SearchHit{Kind: "struct", Name: st.Name, Signature: strings.Join(st.Fields, "; ")})
+		for _, m := range st.Methods {
+			symbols = append(symbols, SearchHit{Kind: "method", Name: st.Name + "." + m.Name, Signature: methodSignature(m), Text: m.Description})
+		}
+	}
+	for _, it := range analysis.Interfaces {
+		var methods []string
+		for _, m := range it.Methods {
+			methods = append(methods, methodSignature(m))
+		}
+		symbols = append(symbols, SearchHit{Kind: "interface", Name: it.Name, Signature: strings.Join(methods, "; ")})
+	}
+	for _, m := range analysis.Methods {
+		symbols = append(symbols, SearchHit{Kind: "func", Name: m.Name, Signature: methodSignature(m), Text: m.Description})
+	}
+	for _, c := range analysis.Constants {
+		symbols = append(symbols, SearchHit{Kind: "const", Name: c.Name, Signature: c.Value, Text: c.Description})
+	}
+	for _, api := range analysis.APIEndpoints {
+		symbols = append(symbols, SearchHit{Kind: "api", Name: api.Name, Signature: api.RequestMethod})
+	}
+	return symbols
+}
+
+func methodSignature(m entity.Method) string {
+	return fmt.Sprintf("%s(%s) (%s)", m.Name, strings.Join(m.Params, ", "), strings.Join(m.ReturnValues, ", "))
+}
+
+// Close 实现 usecase.Sink，保存本次运行的分层总结
+func (s *SQLiteStore) Close(ctx context.Context, summary entity.ProjectSummary) error {
+	return s.SaveProjectSummary(ctx, summary)
+}
+
+// SaveProjectSummary 用 summary 替换保存的分层总结，包的路径会转换为相对源码根目录的路径
+func (s *SQLiteStore) SaveProjectSummary(ctx context.Context, summary entity.ProjectSummary) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM summaries`); err != nil {
+		return err
+	}
+	put := func(kind, dir, text string, files []string) error {
+		data, err := json.Marshal(nonNil(files))
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO summaries (kind, path, text, files) VALUES (?, ?, ?, ?)`, kind, dir, text, string(data))
+		return err
+	}
+	if err := put(summaryKindOverview, "", summary.Overview, nil); err != nil {
+		return fmt.Errorf("failed to save project overview: %v", err)
+	}
+	if err := put(summaryKindDescription, "", summary.Description, nil); err != nil {
+		return fmt.Errorf("failed to save project description: %v", err)
+	}
+	for _, pkg := range summary.Packages {
+		dir, ok := relDir(s.SourceDir, pkg.Path)
+		if !ok {
+			continue
+		}
+		var files []string
+		for _, file := range pkg.Files {
+			if rel, err := relPath(s.SourceDir, file); err == nil {
+				files = append(files, rel)
+			}
+		}
+		if err := put(summaryKindPackage, dir, pkg.Summary, files); err != nil {
+			return fmt.Errorf("failed to save package summary %s: %v", dir, err)
+		}
+	}
+	if summary.Name != "" {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO meta (key, value) VALUES ('project', ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value`, summary.Name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadProjectSummary 读取保存的分层总结，包和文件的路径与分析时遍历到的路径一致（SourceDir 加相对路径）。
+// 没有分层总结时返回 os.ErrNotExist。
+func (s *SQLiteStore) LoadProjectSummary() (entity.ProjectSummary, error) {
+	var summary entity.ProjectSummary
+	summary.Name, _ = s.meta(context.Background(), "project")
+	rows, err := s.db.Query(`SELECT kind, path, text, files FROM summaries ORDER BY kind, path`)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		var kind, dir, text, filesJSON string
+		if err := rows.Scan(&kind, &dir, &text, &filesJSON); err != nil {
+			return summary, err
+		}
+		found = true
+		switch kind {
+		case summaryKindOverview:
+			summary.Overview = text
+		case summaryKindDescription:
+			summary.Description = text
+		case summaryKindPackage:
+			var files []string
+			if err := json.Unmarshal([]byte(filesJSON), &files); err != nil {
+				return summary, fmt.Errorf("failed to decode files of package %s: %v", dir, err)
+			}
+			for i, file := range files {
+				files[i] = s.sourcePath(file)
+			}
+			summary.Packages = append(summary.Packages, entity.PackageSummary{Path: s.sourcePath(dir), Files: files, Summary: text})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+	if !found {
+		return summary, fmt.Errorf("no project summary in database: %w", os.ErrNotExist)
+	}
+	return summary, nil
+}
+
+// LoadAIResult 读取文件的分析结果，没有时返回 os.ErrNotExist
+func (s *SQLiteStore) LoadAIResult(filePath string) (string, entity.ResultMeta, error) {
+	rel, err := relPath(s.SourceDir, filePath)
+	if err != nil {
+		return "", entity.ResultMeta{}, err
+	}
+	var raw string
+	var meta entity.ResultMeta
+	err = s.db.QueryRow(`SELECT raw, model, prompt_version, output_language FROM files WHERE path = ?`, rel).
+		Scan(&raw, &meta.Model, &meta.PromptVersion, &meta.OutputLanguage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", meta, fmt.Errorf("no analysis of %s in database: %w", rel, os.ErrNotExist)
+	}
+	return raw, meta, err
+}
+
+// SummaryReport 按包分组返回所有文件的总结，包和文件都按路径排序
+func (s *SQLiteStore) SummaryReport(ctx context.Context) (entity.FileSummaryReport, error) {
+	var report entity.FileSummaryReport
+	report.Project, _ = s.meta(ctx, "project")
+	rows, err := s.db.QueryContext(ctx, `SELECT f.path, f.package, f.description, f.package_name, f.imports, COALESCE(p.text, '')
+		FROM files f LEFT JOIN summaries p ON p.kind = ? AND p.path = f.package
+		ORDER BY f.package, f.path`, summaryKindPackage)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var file entity.FileSummary
+		var dir, imports, pkgSummary string
+		if err := rows.Scan(&file.Path, &dir, &file.Description, &file.PackageName, &imports, &pkgSummary); err != nil {
+			return report, err
+		}
+		if err := json.Unmarshal([]byte(imports), &file.Imports); err != nil {
+			return report, fmt.Errorf("failed to decode imports of %s: %v", file.Path, err)
+		}
+		if n := len(report.Packages); n == 0 || report.Packages[n-1].Path != dir {
+			report.Packages = append(report.Packages, entity.PackageFiles{Path: dir, Summary: pkgSummary})
+		}
+		last := &report.Packages[len(report.Packages)-1]
+		last.Files = append(last.Files, file)
+	}
+	return report, rows.Err()
+}
+
+// Search 在文件路径、文件描述、符号名称和签名中搜索 query（不区分大小写），文件排在符号之前
+func (s *SQLiteStore) Search(ctx context.Context, query string, limit int) ([]SearchHit, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT 'file', path, path, '', description, 0 FROM files
+			WHERE path LIKE ?1 ESCAPE '\' OR description LIKE ?1 ESCAPE '\'
+		UNION ALL
+		SELECT kind, file_path, name, signature, description, 1 FROM symbols
+			WHERE name LIKE ?1 ESCAPE '\' OR signature LIKE ?1 ESCAPE '\' OR description LIKE ?1 ESCAPE '\'
+		ORDER BY 6, 2, 3
+		LIMIT ?2`, pattern, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %v", err)
+	}
+	defer rows.Close()
+
+	var hits []SearchHit
+	for rows.Next() {
+		var hit SearchHit
+		var order int
+		if err := rows.Scan(&hit.Kind, &hit.Path, &hit.Name, &hit.Signature, &hit.Text, &order); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// SaveEmbedding 保存文件内容的向量，同一文件同一模型只保留最新的向量
+func (s *SQLiteStore) SaveEmbedding(ctx context.Context, filePath, model string, vector []float32) error {
+	rel, err := relPath(s.SourceDir, filePath)
+	if err != nil {
+		return err
+	}
+	blob := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(v))
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO embeddings (file_path, model, vector) VALUES (?, ?, ?)
+		ON CONFLICT (file_path, model) DO UPDATE SET vector = excluded.vector`, rel, model, blob)
+	return err
+}
+
+// Embeddings 返回模型 model 的所有向量，key 为相对源码根目录的文件路径
+func (s *SQLiteStore) Embeddings(ctx context.Context, model string) (map[string][]float32, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT file_path, vector FROM embeddings WHERE model = ?`, model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	embeddings := map[string][]float32{}
+	for rows.Next() {
+		var rel string
+		var blob []byte
+		if err := rows.Scan(&rel, &blob); err != nil {
+			return nil, err
+		}
+		vector := make([]float32, len(blob)/4)
+		for i := range vector {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+		}
+		embeddings[rel] = vector
+	}
+	return embeddings, rows.Err()
+}
+
+// FilesWithoutEmbedding 返回有描述但还没有模型 model 的向量的文件，按路径排序
+func (s *SQLiteStore) FilesWithoutEmbedding(ctx context.Context, model string) ([]entity.FileSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT path, description FROM files
+		WHERE description != '' AND path NOT IN (SELECT file_path FROM embeddings WHERE model = ?)
+		ORDER BY path`, model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []entity.FileSummary
+	for rows.Next() {
+		var file entity.FileSummary
+		if err := rows.Scan(&file.Path, &file.Description); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// SemanticSearch 按与 query 向量的余弦相似度返回最接近的 limit 个文件，query 必须由同一模型 model 计算
+func (s *SQLiteStore) SemanticSearch(ctx context.Context, model string, query []float32, limit int) ([]SearchHit, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	embeddings, err := s.Embeddings(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no %s embeddings in the database, run db embed first", model)
+	}
+
+	var hits []SearchHit
+	for rel, vector := range embeddings {
+		hits = append(hits, SearchHit{Kind: "file", Path: rel, Name: rel, Score: cosine(query, vector)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Path < hits[j].Path
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		if err := s.db.QueryRowContext(ctx, `SELECT description FROM files WHERE path = ?`, hits[i].Path).Scan(&hits[i].Text); err != nil {
+			return nil, err
+		}
+	}
+	return hits, nil
+}
+
+// cosine 返回两个向量的余弦相似度，长度不同或有零向量时为 0
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// Import 把输出目录中按 YAML 布局保存的分析结果和分层总结导入数据库，返回导入的文件数
+func (s *SQLiteStore) Import(ctx context.Context, local *CodeSummary) (int, error) {
+	index, err := local.LoadIndex()
+	if err != nil {
+		return 0, err
+	}
+	sources := make([]string, 0, len(index.Files))
+	for rel := range index.Files {
+		sources = append(sources, rel)
+	}
+	sort.Strings(sources)
+
+	count := 0
+	for _, rel := range sources {
+		entry := index.Files[rel]
+		data, err := os.ReadFile(filepath.Join(local.OutputDir, filepath.FromSlash(entry.Result)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return count, fmt.Errorf("failed to read result of %s: %v", rel, err)
+		}
+		raw, meta := parseResult(data)
+		if meta.Model == "" {
+			meta.Model = entry.Model
+		}
+		if err := s.putFile(ctx, rel, raw, entry.Hash, meta, entry.UpdatedAt); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	summary, err := local.LoadProjectSummary()
+	if errors.Is(err, os.ErrNotExist) {
+		return count, nil
+	} else if err != nil {
+		return count, err
+	}
+	return count, s.SaveProjectSummary(ctx, summary)
+}
+
+// Export 把数据库中的分析结果和分层总结按 YAML 布局写入输出目录，返回导出的文件数
+func (s *SQLiteStore) Export(ctx context.Context, local *CodeSummary) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT path, raw, content_hash, model, prompt_version, output_language, updated_at
+		FROM files ORDER BY path`)
+	if err != nil {
+		return 0, err
+	}
+	type fileRow struct {
+		rel, raw, hash, updatedAt string
+		meta                      entity.ResultMeta
+	}
+	var files []fileRow
+	for rows.Next() {
+		var f fileRow
+		if err := rows.Scan(&f.rel, &f.raw, &f.hash, &f.meta.Model, &f.meta.PromptVersion, &f.meta.OutputLanguage, &f.updatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		files = append(files, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, f := range files {
+		sourcePath := filepath.Join(local.SourceDir, filepath.FromSlash(f.rel))
+		if err := local.SaveAIResult("", sourcePath, f.raw, f.meta); err != nil {
+			return 0, err
+		}
+		updatedAt, _ := time.Parse(time.RFC3339Nano, f.updatedAt)
+		if err := local.putIndexEntry(IndexEntry{
+			Source:         f.rel,
+			Result:         resultsDir + "/" + f.rel + ".yaml",
+			Model:          f.meta.Model,
+			PromptVersion:  f.meta.PromptVersion,
+			OutputLanguage: f.meta.OutputLanguage,
+			Hash:           f.hash,
+			UpdatedAt:      updatedAt,
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := local.Flush(ctx); err != nil {
+		return 0, err
+	}
+
+	summary, err := s.LoadProjectSummary()
+	if errors.Is(err, os.ErrNotExist) {
+		return len(files), local.RebuildSummary(summary)
+	} else if err != nil {
+		return len(files), err
+	}
+	return len(files), local.Close(ctx, summary)
+}
+
+// sourcePath 把相对路径转换为与分析时遍历到的路径一致的形式
+func (s *SQLiteStore) sourcePath(rel string) string {
+	return filepath.Join(s.SourceDir, filepath.FromSlash(rel))
+}
+
+func (s *SQLiteStore) meta(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	return value, err
+}
+
+func (s *SQLiteStore) setMeta(ctx context.Context, key, value string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO meta (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
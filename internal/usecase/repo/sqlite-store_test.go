@@ -0,0 +1,250 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+const storeAnalysis = `file_description: 用户登录
+file_info:
+  package_name: auth
+  imports: [net/http]
+structs:
+  - name: Service
+    fields: [db *sql.DB]
+    methods:
+      - name: Login
+        params: [name string]
+        return_values: [error]
+        description: 校验密码并登录
+constants:
+  - name: MaxRetry
+    value: "3"
+    description: 最大重试次数
+`
+
+func openTestStore(t *testing.T, source string) *SQLiteStore {
+	t.Helper()
+	store, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "db", DefaultDBFileName), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.CloseDB() })
+	return store
+}
+
+func TestSQLiteStoreSink(t *testing.T) {
+	ctx := context.Background()
+	source := t.TempDir()
+	store := openTestStore(t, source)
+	if err := store.BeginRun(ctx, "demo", "gpt-4o-mini", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	authFile := filepath.Join(source, "auth", "service.go")
+	for _, result := range []entity.FileResult{
+		{Path: authFile, Code: "package auth", Raw: storeAnalysis, Meta: entity.ResultMeta{Model: "gpt-4o-mini", PromptVersion: "v2"}},
+		{Path: filepath.Join(source, "main.go"), Code: "package main", Raw: "file_description: 入口\n"},
+	} {
+		if err := store.SaveFileResult(ctx, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 再次保存时替换原有的符号
+	if err := store.SaveFileResult(ctx, entity.FileResult{Path: authFile, Code: "package auth", Raw: storeAnalysis}); err != nil {
+		t.Fatal(err)
+	}
+	summary := entity.ProjectSummary{
+		Name:     "demo",
+		Overview: "演示项目",
+		Packages: []entity.PackageSummary{{Path: filepath.Join(source, "auth"), Files: []string{authFile}, Summary: "认证"}},
+	}
+	if err := store.Close(ctx, summary); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FinishRun(ctx, entity.UsageReport{
+		Total:  entity.TokenUsage{Calls: 2, PromptTokens: 100, Cost: 0.01},
+		ByFile: map[string]entity.TokenUsage{authFile: {Calls: 1}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err := store.Search(ctx, "登录", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].Kind != "file" || hits[0].Path != "auth/service.go" || hits[1].Name != "Service.Login" {
+		t.Errorf("hits = %+v", hits)
+	}
+	if hits, _ := store.Search(ctx, "100%", 10); len(hits) != 0 {
+		t.Errorf("wildcards in the query must be escaped, got %+v", hits)
+	}
+	if hits, _ := store.Search(ctx, "login", 10); len(hits) != 1 || hits[0].Kind != "method" {
+		t.Errorf("search is case insensitive, got %+v", hits)
+	}
+
+	raw, meta, err := store.LoadAIResult(authFile)
+	if err != nil || raw != storeAnalysis || meta.Model != "" {
+		t.Fatalf("LoadAIResult = %q, %+v, %v", raw, meta, err)
+	}
+	if _, _, err := store.LoadAIResult(filepath.Join(source, "missing.go")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file error = %v", err)
+	}
+
+	loaded, err := store.LoadProjectSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Name != "demo" || loaded.Overview != "演示项目" || len(loaded.Packages) != 1 ||
+		loaded.Packages[0].Path != filepath.Join(source, "auth") || loaded.Packages[0].Files[0] != authFile {
+		t.Errorf("LoadProjectSummary = %+v", loaded)
+	}
+
+	report, err := store.SummaryReport(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Packages) != 2 || report.Packages[0].Path != "." || report.Packages[1].Summary != "认证" ||
+		report.Packages[1].Files[0].Imports[0] != "net/http" {
+		t.Errorf("SummaryReport = %+v", report)
+	}
+
+	var usageRows int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM usage WHERE key IN ('', 'auth/service.go')`).Scan(&usageRows); err != nil || usageRows != 2 {
+		t.Errorf("usage rows = %d, %v", usageRows, err)
+	}
+	var commit string
+	if err := store.db.QueryRow(`SELECT commit_sha FROM runs`).Scan(&commit); err != nil || commit != "abc123" {
+		t.Errorf("commit = %q, %v", commit, err)
+	}
+
+	// 删除的文件不再出现在总结中
+	if err := store.RemoveFileResult(ctx, authFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.LoadAIResult(authFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("removed file error = %v", err)
+	}
+}
+
+func TestSQLiteStoreUpgradesSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultDBFileName)
+	old, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`CREATE TABLE runs (id INTEGER PRIMARY KEY AUTOINCREMENT, project TEXT NOT NULL, model TEXT NOT NULL DEFAULT '',
+		started_at TEXT NOT NULL, finished_at TEXT, cost REAL NOT NULL DEFAULT 0, currency TEXT NOT NULL DEFAULT '')`); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	store, err := OpenSQLiteStore(path, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.CloseDB()
+	if err := store.BeginRun(context.Background(), "demo", "gpt-4o-mini", "abc123"); err != nil {
+		t.Errorf("BeginRun on an upgraded database: %v", err)
+	}
+}
+
+func TestSQLiteStoreEmbeddings(t *testing.T) {
+	ctx := context.Background()
+	source := t.TempDir()
+	store := openTestStore(t, source)
+	file := filepath.Join(source, "a.go")
+	if err := store.SaveFileResult(ctx, entity.FileResult{Path: file, Raw: "file_description: a\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveEmbedding(ctx, file, "text-embedding-3-small", []float32{0.5, -1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	embeddings, err := store.Embeddings(ctx, "text-embedding-3-small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := embeddings["a.go"]; len(v) != 3 || v[0] != 0.5 || v[1] != -1 || v[2] != 2 {
+		t.Errorf("embeddings = %v", embeddings)
+	}
+
+	other := filepath.Join(source, "b.go")
+	if err := store.SaveFileResult(ctx, entity.FileResult{Path: other, Raw: "file_description: b\n"}); err != nil {
+		t.Fatal(err)
+	}
+	missing, err := store.FilesWithoutEmbedding(ctx, "text-embedding-3-small")
+	if err != nil || len(missing) != 1 || missing[0].Path != "b.go" || missing[0].Description != "b" {
+		t.Fatalf("files without embedding = %+v, %v", missing, err)
+	}
+	if err := store.SaveEmbedding(ctx, other, "text-embedding-3-small", []float32{1, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	hits, err := store.SemanticSearch(ctx, "text-embedding-3-small", []float32{2, 0, 0.1}, 1)
+	if err != nil || len(hits) != 1 || hits[0].Path != "b.go" || hits[0].Text != "b" || hits[0].Score < 0.99 {
+		t.Errorf("semantic search = %+v, %v", hits, err)
+	}
+
+	// 保存相同的描述时保留向量，描述变化后删除
+	if err := store.SaveFileResult(ctx, entity.FileResult{Path: other, Raw: "file_description: b\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveFileResult(ctx, entity.FileResult{Path: file, Raw: "file_description: a2\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if embeddings, err = store.Embeddings(ctx, "text-embedding-3-small"); err != nil || len(embeddings) != 1 || embeddings["b.go"] == nil {
+		t.Errorf("embeddings after re-analysis = %v, %v", embeddings, err)
+	}
+}
+
+func TestSQLiteStoreImportExport(t *testing.T) {
+	ctx := context.Background()
+	source := t.TempDir()
+	authFile := filepath.Join(source, "auth", "service.go")
+	if err := os.MkdirAll(filepath.Dir(authFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(authFile, []byte("package auth"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	local := NewCodeSummaryRepo(t.TempDir(), source)
+	if err := local.SaveFileResult(ctx, entity.FileResult{Path: authFile, Code: "package auth", Raw: storeAnalysis,
+		Meta: entity.ResultMeta{Model: "gpt-4o-mini", PromptVersion: "v2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Close(ctx, entity.ProjectSummary{Name: "demo", Overview: "演示项目",
+		Packages: []entity.PackageSummary{{Path: filepath.Join(source, "auth"), Summary: "认证"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	store := openTestStore(t, source)
+	if count, err := store.Import(ctx, local); err != nil || count != 1 {
+		t.Fatalf("Import = %d, %v", count, err)
+	}
+	if hits, err := store.Search(ctx, "MaxRetry", 10); err != nil || len(hits) != 1 {
+		t.Errorf("hits after import = %+v, %v", hits, err)
+	}
+
+	exported := NewCodeSummaryRepo(t.TempDir(), source)
+	if count, err := store.Export(ctx, exported); err != nil || count != 1 {
+		t.Fatalf("Export = %d, %v", count, err)
+	}
+	raw, meta, err := exported.LoadAIResult(authFile)
+	if err != nil || raw != storeAnalysis || meta.PromptVersion != "v2" || meta.Model != "gpt-4o-mini" {
+		t.Errorf("exported result = %q, %+v, %v", raw, meta, err)
+	}
+	index, err := exported.LoadIndex()
+	if err != nil || index.Files["auth/service.go"].Hash != entity.ContentHash("package auth") {
+		t.Errorf("exported index = %+v, %v", index, err)
+	}
+	summary, err := exported.LoadProjectSummary()
+	if err != nil || summary.Overview != "演示项目" || summary.Packages[0].Summary != "认证" {
+		t.Errorf("exported summary = %+v, %v", summary, err)
+	}
+}
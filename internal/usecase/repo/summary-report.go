@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"codetest/internal/entity"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 文件总结的格式
+const (
+	SummaryFormatMarkdown = "markdown"
+	SummaryFormatJSON     = "json"
+	SummaryFormatYAML     = "yaml"
+)
+
+// summaryFileNames 每种格式的文件总结在输出目录中的文件名
+var summaryFileNames = map[string]string{
+	SummaryFormatMarkdown: "summary.md",
+	SummaryFormatJSON:     "summary.json",
+	SummaryFormatYAML:     "summary.yaml",
+}
+
+// ValidateSummaryFormats 检查文件总结格式是否都受支持
+func ValidateSummaryFormats(formats []string) error {
+	for _, format := range formats {
+		if _, ok := summaryFileNames[format]; !ok {
+			return fmt.Errorf("unknown summary format %q, supported formats: %s, %s, %s",
+				format, SummaryFormatMarkdown, SummaryFormatJSON, SummaryFormatYAML)
+		}
+	}
+	return nil
+}
+
+// RebuildSummary 用索引中记录的所有分析结果重新生成文件总结，按 SummaryFormats 写入输出目录。
+// 多次运行得到相同的输出：包和文件都按路径排序，不包含时间等每次变化的内容。
+func (r *CodeSummary) RebuildSummary(summary entity.ProjectSummary) error {
+	report, err := r.BuildSummaryReport(summary)
+	if err != nil {
+		return err
+	}
+	formats := r.SummaryFormats
+	if len(formats) == 0 {
+		formats = []string{SummaryFormatMarkdown}
+	}
+	if err := ValidateSummaryFormats(formats); err != nil {
+		return err
+	}
+
+	for _, format := range formats {
+		var data []byte
+		switch format {
+		case SummaryFormatMarkdown:
+			data = []byte(MarkdownSummary(report))
+		case SummaryFormatJSON:
+			if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+				return fmt.Errorf("failed to encode summary: %v", err)
+			}
+		case SummaryFormatYAML:
+			if data, err = yaml.Marshal(report); err != nil {
+				return fmt.Errorf("failed to encode summary: %v", err)
+			}
+		}
+		if err := writeFileAtomic(filepath.Join(r.OutputDir, summaryFileNames[format]), data); err != nil {
+			return fmt.Errorf("failed to write summary: %v", err)
+		}
+	}
+	return nil
+}
+
+// BuildSummaryReport 读取索引中记录的分析结果，按包分组生成文件总结。
+// 源码已经删除的文件会被跳过，summary 中的包总结会合并到对应的包。
+func (r *CodeSummary) BuildSummaryReport(summary entity.ProjectSummary) (entity.FileSummaryReport, error) {
+	report := entity.FileSummaryReport{Project: summary.Name}
+	index, err := r.LoadIndex()
+	if err != nil {
+		return report, err
+	}
+	sourceDir := r.SourceDir
+	if sourceDir == "" {
+		sourceDir = index.SourceDir
+	}
+
+	packageSummaries := map[string]string{}
+	for _, pkg := range summary.Packages {
+		if dir, ok := relDir(r.SourceDir, pkg.Path); ok {
+			packageSummaries[dir] = pkg.Summary
+		}
+	}
+
+	byPackage := map[string]*entity.PackageFiles{}
+	for rel, entry := range index.Files {
+		if sourceDir != "" {
+			if _, err := os.Stat(filepath.Join(sourceDir, filepath.FromSlash(rel))); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+		}
+		data, err := os.ReadFile(filepath.Join(r.OutputDir, filepath.FromSlash(entry.Result)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return report, fmt.Errorf("failed to read result of %s: %v", rel, err)
+		}
+		content, _ := parseResult(data)
+		var parsed entity.ParsedYAML
+		if err := yaml.Unmarshal([]byte(content), &parsed); err != nil {
+			return report, fmt.Errorf("failed to parse result of %s: %v", rel, err)
+		}
+
+		dir := path.Dir(rel)
+		pkg, ok := byPackage[dir]
+		if !ok {
+			pkg = &entity.PackageFiles{Path: dir, Summary: packageSummaries[dir]}
+			byPackage[dir] = pkg
+		}
+		pkg.Files = append(pkg.Files, entity.FileSummary{
+			Path:        rel,
+			Description: strings.TrimSpace(parsed.FileDescription),
+			PackageName: parsed.FileInfo.PackageName,
+			Imports:     parsed.FileInfo.Imports,
+		})
+	}
+
+	for _, pkg := range byPackage {
+		sort.Slice(pkg.Files, func(i, j int) bool { return pkg.Files[i].Path < pkg.Files[j].Path })
+		report.Packages = append(report.Packages, *pkg)
+	}
+	sort.Slice(report.Packages, func(i, j int) bool { return report.Packages[i].Path < report.Packages[j].Path })
+	return report, nil
+}
+
+// MarkdownSummary 把文件总结渲染为 Markdown，每个文件的条目与 entity.SummaryEntry 的格式一致
+func MarkdownSummary(report entity.FileSummaryReport) string {
+	var b strings.Builder
+	if report.Project != "" {
+		b.WriteString(fmt.Sprintf("# %s\n\n", report.Project))
+	}
+	for _, pkg := range report.Packages {
+		b.WriteString(fmt.Sprintf("## %s\n\n", pkg.Path))
+		if pkg.Summary != "" {
+			b.WriteString(strings.TrimSpace(pkg.Summary))
+			b.WriteString("\n\n")
+		}
+		for _, file := range pkg.Files {
+			b.WriteString(entity.SummaryEntry(file.Path, &entity.ParsedYAML{
+				FileDescription: file.Description,
+				FileInfo:        entity.FileInfo{PackageName: file.PackageName, Imports: file.Imports},
+			}))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+func TestRebuildSummaryIsStable(t *testing.T) {
+	source := t.TempDir()
+	output := t.TempDir()
+	r := NewCodeSummaryRepo(output, source)
+	r.SummaryFormats = []string{SummaryFormatMarkdown, SummaryFormatJSON, SummaryFormatYAML}
+
+	// 按与路径顺序不同的顺序保存
+	for _, name := range []string{"store/b.go", "main.go", "store/a.go", "gone.go"} {
+		path := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		raw := "file_description: " + name + " 的功能\nfile_info:\n  package_name: x\n  imports: [fmt]\n"
+		if err := r.SaveFileResult(context.Background(), entity.FileResult{Path: path, Raw: raw}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 源码已经删除的文件不出现在总结中
+	if err := os.Remove(filepath.Join(source, "gone.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := entity.ProjectSummary{
+		Name:     "demo",
+		Packages: []entity.PackageSummary{{Path: filepath.Join(source, "store"), Summary: "存储相关"}},
+	}
+	if err := r.Close(context.Background(), summary); err != nil {
+		t.Fatal(err)
+	}
+	first := readSummaryFiles(t, output)
+	if err := r.Close(context.Background(), summary); err != nil {
+		t.Fatal(err)
+	}
+	if second := readSummaryFiles(t, output); second != first {
+		t.Errorf("summary changed between runs:\n%s\n---\n%s", first, second)
+	}
+
+	md, err := os.ReadFile(filepath.Join(output, "summary.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(md), "gone.go") {
+		t.Errorf("summary contains deleted file:\n%s", md)
+	}
+	order := []string{"# demo", "## .", "文件名: main.go", "## store", "存储相关", "文件名: store/a.go", "文件名: store/b.go"}
+	last := -1
+	for _, want := range order {
+		i := strings.Index(string(md), want)
+		if i <= last {
+			t.Fatalf("%q missing or out of order in:\n%s", want, md)
+		}
+		last = i
+	}
+
+	var report entity.FileSummaryReport
+	data, err := os.ReadFile(filepath.Join(output, "summary.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Packages) != 2 || len(report.Packages[1].Files) != 2 || report.Packages[1].Files[0].Imports[0] != "fmt" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func readSummaryFiles(t *testing.T, dir string) string {
+	t.Helper()
+	var all strings.Builder
+	for _, name := range []string{"summary.md", "summary.json", "summary.yaml"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		all.Write(data)
+	}
+	return all.String()
+}
+
+func TestValidateSummaryFormats(t *testing.T) {
+	if err := ValidateSummaryFormats([]string{"markdown", "yaml"}); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateSummaryFormats([]string{"html"}); err == nil {
+		t.Error("expected error for html")
+	}
+}
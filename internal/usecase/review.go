@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"codetest/internal/entity"
+	"codetest/internal/usecase/prompt"
+)
+
+// reviewShape 评审结果的 YAML 结构
+var reviewShape = shapeOf(reflect.TypeOf(entity.Review{}))
+
+// ReviewPatch 让 LLM 评审补丁，patch 为带行号的补丁，summary 为相关文件的分析总结。
+// 不认识的类别归为 other，不认识的严重程度按 medium 处理。
+func (uc *aiCodeUseCase) ReviewPatch(ctx context.Context, projectName, patch, summary string) (entity.Review, error) {
+	response, err := askLLM(ctx, uc.client, uc.prompts, prompt.CodeReview, prompt.Data{Project: projectName, Diff: patch, Summary: summary})
+	if err != nil {
+		return entity.Review{}, err
+	}
+
+	var review entity.Review
+	if _, err := unmarshalLLMYAML(ctx, uc.client, uc.prompts, response, reviewShape, &review); err != nil {
+		return review, fmt.Errorf("failed to parse review: %w", err)
+	}
+	for i := range review.Findings {
+		finding := &review.Findings[i]
+		finding.Category = strings.ToLower(strings.TrimSpace(finding.Category))
+		switch finding.Category {
+		case entity.ReviewBug, entity.ReviewAPIBreak, entity.ReviewMissingTest:
+		default:
+			finding.Category = entity.ReviewOther
+		}
+		finding.Severity = strings.ToLower(strings.TrimSpace(finding.Severity))
+		switch finding.Severity {
+		case entity.SeverityHigh, entity.SeverityMedium, entity.SeverityLow:
+		default:
+			finding.Severity = entity.SeverityMedium
+		}
+	}
+	return review, nil
+}
@@ -0,0 +1,188 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"codetest/internal/entity"
+)
+
+// 评审结果的输出格式
+const (
+	FormatMarkdown = "markdown"
+	FormatSARIF    = "sarif"
+)
+
+// categoryTitles 每类问题的标题，也是 SARIF 规则的描述
+var categoryTitles = map[string]string{
+	entity.ReviewBug:         "Possible bug",
+	entity.ReviewAPIBreak:    "Breaking API change",
+	entity.ReviewMissingTest: "Missing test",
+	entity.ReviewOther:       "Other issue",
+}
+
+// categories 规则的顺序
+var categories = []string{entity.ReviewBug, entity.ReviewAPIBreak, entity.ReviewMissingTest, entity.ReviewOther}
+
+// Render 按 format 输出评审结果
+func Render(review entity.Review, format, project string) ([]byte, error) {
+	switch format {
+	case FormatMarkdown:
+		return []byte(Markdown(review, project)), nil
+	case FormatSARIF:
+		return SARIF(review)
+	}
+	return nil, fmt.Errorf("unknown review format %q, expected %s or %s", format, FormatMarkdown, FormatSARIF)
+}
+
+// Markdown 输出适合贴到 PR 评论中的 Markdown，问题按类别分组
+func Markdown(review entity.Review, project string) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# Review of %s\n\n", project)
+	if summary := strings.TrimSpace(review.Summary); summary != "" {
+		builder.WriteString(summary + "\n\n")
+	}
+	if len(review.Findings) == 0 {
+		builder.WriteString("No findings.\n")
+		return builder.String()
+	}
+
+	for _, category := range categories {
+		var findings []entity.ReviewFinding
+		for _, finding := range review.Findings {
+			if finding.Category == category {
+				findings = append(findings, finding)
+			}
+		}
+		if len(findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(&builder, "## %s (%d)\n\n", categoryTitles[category], len(findings))
+		for _, finding := range findings {
+			fmt.Fprintf(&builder, "- **[%s]** `%s` %s\n", finding.Severity, anchor(finding), strings.TrimSpace(finding.Title))
+			if detail := strings.TrimSpace(finding.Detail); detail != "" {
+				fmt.Fprintf(&builder, "  %s\n", indent(detail))
+			}
+			if suggestion := strings.TrimSpace(finding.Suggestion); suggestion != "" {
+				fmt.Fprintf(&builder, "  Suggestion: %s\n", indent(suggestion))
+			}
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// anchor 返回 file:line，行号未知时只有文件
+func anchor(finding entity.ReviewFinding) string {
+	if finding.Line > 0 {
+		return fmt.Sprintf("%s:%d", finding.File, finding.Line)
+	}
+	return finding.File
+}
+
+// indent 让多行文本留在同一个列表项中
+func indent(text string) string {
+	return strings.ReplaceAll(text, "\n", "\n  ")
+}
+
+// sarifLevels 严重程度对应的 SARIF level
+var sarifLevels = map[string]string{
+	entity.SeverityHigh:   "error",
+	entity.SeverityMedium: "warning",
+	entity.SeverityLow:    "note",
+}
+
+// SARIF 2.1.0 中用到的部分结构
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// SARIF 输出 SARIF 2.1.0 格式，可以上传到支持代码扫描结果的平台或在编辑器中查看。
+// 路径相对 %SRCROOT%，即 review 的源码目录。
+func SARIF(review entity.Review) ([]byte, error) {
+	run := sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: "code-analyzer"}}, Results: []sarifResult{}}
+	for _, category := range categories {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: category, ShortDescription: sarifMessage{Text: categoryTitles[category]}})
+	}
+	for _, finding := range review.Findings {
+		message := strings.TrimSpace(finding.Title)
+		for _, extra := range []string{finding.Detail, finding.Suggestion} {
+			if extra = strings.TrimSpace(extra); extra != "" {
+				message += "\n\n" + extra
+			}
+		}
+		location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: finding.File, URIBaseID: "%SRCROOT%"},
+		}}
+		if finding.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: finding.Line}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    finding.Category,
+			Level:     sarifLevels[finding.Severity],
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SARIF: %v", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,177 @@
+// Package review 为 review 命令准备发给 LLM 的补丁和上下文，并把评审结果输出为 Markdown 或 SARIF
+package review
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"codetest/internal/entity"
+	"codetest/internal/pkg/unidiff"
+	"codetest/internal/usecase/docsite"
+)
+
+// FormatPatch 把补丁转换为发给 LLM 的文本，每行前标注新文件的行号，便于 LLM 给出准确的 file:line
+func FormatPatch(files []unidiff.File) string {
+	var builder strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&builder, "=== %s (%s", file.Path(), file.Status())
+		if file.Status() == unidiff.StatusRenamed {
+			fmt.Fprintf(&builder, " from %s", file.OldPath)
+		}
+		builder.WriteString(")\n")
+		if file.Binary {
+			builder.WriteString("(binary file)\n")
+		}
+		for _, hunk := range file.Hunks {
+			fmt.Fprintf(&builder, "@@ %s\n", hunk.Section)
+			for _, line := range hunk.Lines {
+				number := ""
+				if line.NewLine > 0 {
+					number = fmt.Sprint(line.NewLine)
+				}
+				fmt.Fprintf(&builder, "%6s %c %s\n", number, line.Kind, line.Text)
+			}
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// Context 发给 LLM 的相关文件：补丁修改的文件以及引用了它们所在包的文件。
+// 调用方按 import 关系近似，import 了包但没有调用被修改的函数的文件也算在内
+type Context struct {
+	Changed []*docsite.File
+	Callers []*docsite.File
+}
+
+// BuildContext 从文档站点数据中找出补丁修改的文件和它们的调用方，调用方最多 maxCallers 个，按路径排序。
+// 调用方是其他包中 import 了被修改文件所在包的文件，maxCallers 为 0 时不限制。
+func BuildContext(site *docsite.Site, files []unidiff.File, maxCallers int) Context {
+	byPath := map[string]*docsite.File{}
+	for _, file := range site.Files {
+		byPath[file.Path] = file
+	}
+
+	var ctx Context
+	changed := map[string]bool{}
+	importPaths := map[string]bool{}
+	for _, diffFile := range files {
+		file, ok := byPath[diffFile.Path()]
+		if !ok || changed[file.Path] {
+			continue
+		}
+		changed[file.Path] = true
+		ctx.Changed = append(ctx.Changed, file)
+		if file.Package.ImportPath != "" {
+			importPaths[file.Package.ImportPath] = true
+		}
+	}
+
+	for _, file := range site.Files {
+		if changed[file.Path] || importPaths[file.Package.ImportPath] {
+			continue
+		}
+		for _, importPath := range file.Imports {
+			if importPaths[importPath] {
+				ctx.Callers = append(ctx.Callers, file)
+				break
+			}
+		}
+		if maxCallers > 0 && len(ctx.Callers) == maxCallers {
+			break
+		}
+	}
+	return ctx
+}
+
+// Paths 返回上下文中所有文件的路径
+func (c Context) Paths() []string {
+	var paths []string
+	for _, file := range append(append([]*docsite.File{}, c.Changed...), c.Callers...) {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+// String 返回发给 LLM 的上下文：修改的文件给出完整的分析结果，调用方只给出描述
+func (c Context) String() string {
+	var builder strings.Builder
+	builder.WriteString("#### 修改的文件\n")
+	if len(c.Changed) == 0 {
+		builder.WriteString("没有保存的分析结果\n")
+	}
+	for _, file := range c.Changed {
+		fmt.Fprintf(&builder, "##### %s", file.Path)
+		if file.Package.ImportPath != "" {
+			fmt.Fprintf(&builder, " (包 %s)", file.Package.ImportPath)
+		}
+		builder.WriteString("\n")
+		if file.Analysis == "" {
+			builder.WriteString("没有保存的分析结果\n")
+		} else {
+			builder.WriteString(strings.TrimSpace(file.Analysis) + "\n")
+		}
+		builder.WriteString("\n")
+	}
+
+	if len(c.Callers) > 0 {
+		builder.WriteString("#### 调用方\n")
+		for _, file := range c.Callers {
+			description := file.Description
+			if description == "" {
+				description = "没有保存的分析结果"
+			}
+			fmt.Fprintf(&builder, "- %s: %s\n", file.Path, description)
+		}
+	}
+	return builder.String()
+}
+
+// severityOrder 输出时按严重程度排序
+var severityOrder = map[string]int{entity.SeverityHigh: 0, entity.SeverityMedium: 1, entity.SeverityLow: 2}
+
+// Anchor 检查问题的位置：路径统一为相对源码目录的形式，不在补丁或上下文中的文件被视为 LLM 编造的位置并丢弃，
+// 行号只保留落在补丁修改的文件的变化范围内的，其余清零。返回丢弃的问题，剩下的问题按严重程度、文件和行号排序。
+func Anchor(review *entity.Review, files []unidiff.File, known []string) []entity.ReviewFinding {
+	diffFiles := map[string]unidiff.File{}
+	for _, file := range files {
+		diffFiles[file.Path()] = file
+	}
+	knownFiles := map[string]bool{}
+	for _, file := range known {
+		knownFiles[file] = true
+	}
+
+	var kept, dropped []entity.ReviewFinding
+	for _, finding := range review.Findings {
+		finding.File = path.Clean(strings.TrimPrefix(strings.TrimSpace(finding.File), "./"))
+		if finding.Line < 0 {
+			finding.Line = 0
+		}
+		diffFile, ok := diffFiles[finding.File]
+		if !ok {
+			if !knownFiles[finding.File] {
+				dropped = append(dropped, finding)
+				continue
+			}
+			finding.Line = 0
+		} else if !diffFile.InHunk(finding.Line) {
+			finding.Line = 0
+		}
+		kept = append(kept, finding)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		a, b := kept[i], kept[j]
+		if severityOrder[a.Severity] != severityOrder[b.Severity] {
+			return severityOrder[a.Severity] < severityOrder[b.Severity]
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	review.Findings = kept
+	return dropped
+}
@@ -0,0 +1,116 @@
+package review
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+	"codetest/internal/pkg/unidiff"
+	"codetest/internal/usecase/docsite"
+)
+
+// testSite store 包被 api 包引用，cli 包没有引用它
+func testSite() *docsite.Site {
+	store := &docsite.Package{Dir: "store", ImportPath: "svc/store"}
+	api := &docsite.Package{Dir: "api", ImportPath: "svc/api"}
+	cli := &docsite.Package{Dir: "cli", ImportPath: "svc/cli"}
+	return &docsite.Site{Files: []*docsite.File{
+		{Path: "api/handler.go", Package: api, Description: "HTTP 接口", Imports: []string{"net/http", "svc/store"}},
+		{Path: "cli/main.go", Package: cli, Imports: []string{"fmt"}},
+		{Path: "store/store.go", Package: store, Analysis: "file_description: 内存存储\n"},
+		{Path: "store/util.go", Package: store, Imports: []string{"svc/store"}},
+	}}
+}
+
+func testPatch(t *testing.T) []unidiff.File {
+	t.Helper()
+	files, err := unidiff.Parse("--- a/store/store.go\n+++ b/store/store.go\n@@ -1,2 +1,3 @@\n package store\n+var count int\n func Put() {}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestBuildContext(t *testing.T) {
+	ctx := BuildContext(testSite(), testPatch(t), 0)
+	if got := ctx.Paths(); !slices.Equal(got, []string{"store/store.go", "api/handler.go"}) {
+		t.Fatalf("paths = %q", got)
+	}
+	text := ctx.String()
+	if !strings.Contains(text, "file_description: 内存存储") || !strings.Contains(text, "- api/handler.go: HTTP 接口") {
+		t.Errorf("context = %s", text)
+	}
+
+	if patch := FormatPatch(testPatch(t)); !strings.Contains(patch, "     2 + var count int\n") || !strings.Contains(patch, "=== store/store.go (modified)") {
+		t.Errorf("patch = %s", patch)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	review := entity.Review{Findings: []entity.ReviewFinding{
+		{Category: entity.ReviewMissingTest, Severity: entity.SeverityLow, File: "./store/store.go", Line: 2},
+		{Category: entity.ReviewAPIBreak, Severity: entity.SeverityHigh, File: "api/handler.go", Line: 30},
+		{Category: entity.ReviewBug, Severity: entity.SeverityHigh, File: "made/up.go", Line: 1},
+		{Category: entity.ReviewOther, Severity: entity.SeverityLow, File: "store/store.go", Line: 400},
+	}}
+	dropped := Anchor(&review, testPatch(t), []string{"store/store.go", "api/handler.go"})
+	if len(dropped) != 1 || dropped[0].File != "made/up.go" {
+		t.Errorf("dropped = %+v", dropped)
+	}
+	if len(review.Findings) != 3 {
+		t.Fatalf("findings = %+v", review.Findings)
+	}
+	// 调用方的行号不在补丁中，LLM 无从得知，去掉
+	if got := review.Findings[0]; got.File != "api/handler.go" || got.Line != 0 {
+		t.Errorf("first finding = %+v", got)
+	}
+	// 行号不在补丁的变化范围内，只保留文件
+	if got := review.Findings[1]; got.File != "store/store.go" || got.Line != 0 {
+		t.Errorf("second finding = %+v", got)
+	}
+	if got := review.Findings[2]; got.File != "store/store.go" || got.Line != 2 {
+		t.Errorf("third finding = %+v", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	review := entity.Review{Summary: "增加计数", Findings: []entity.ReviewFinding{
+		{Category: entity.ReviewBug, Severity: entity.SeverityHigh, File: "store/store.go", Line: 2, Title: "没有加锁", Suggestion: "使用 mu"},
+		{Category: entity.ReviewMissingTest, Severity: entity.SeverityLow, File: "store/store.go", Title: "没有测试"},
+	}}
+
+	markdown, err := Render(review, FormatMarkdown, "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## Possible bug (1)", "- **[high]** `store/store.go:2` 没有加锁", "  Suggestion: 使用 mu", "- **[low]** `store/store.go` 没有测试"} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("markdown is missing %q:\n%s", want, markdown)
+		}
+	}
+
+	data, err := Render(review, FormatSARIF, "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("invalid SARIF: %v", err)
+	}
+	results := log.Runs[0].Results
+	if log.Version != "2.1.0" || len(results) != 2 || len(log.Runs[0].Tool.Driver.Rules) != len(categories) {
+		t.Fatalf("sarif = %s", data)
+	}
+	if results[0].Level != "error" || results[0].Locations[0].PhysicalLocation.Region.StartLine != 2 || results[0].Message.Text != "没有加锁\n\n使用 mu" {
+		t.Errorf("first result = %+v", results[0])
+	}
+	if results[1].Level != "note" || results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("second result = %+v", results[1])
+	}
+
+	if _, err := Render(review, "html", "svc"); err == nil {
+		t.Error("Render accepted an unknown format")
+	}
+}
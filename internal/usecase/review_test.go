@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"codetest/internal/entity"
+	"codetest/internal/usecase/prompt"
+)
+
+func TestReviewPatch(t *testing.T) {
+	uc := NewAiCode(llmForTest(t, []string{"```yaml\n" + `summary: 给 Store 增加了计数
+findings:
+  - category: Bug
+    severity: high
+    file: store/store.go
+    line: 6
+    title: count 没有在锁内更新
+    detail: 并发调用 Put 时计数会出错
+  - category: style
+    severity: critical
+    file: store/store.go
+    line: 0
+    title: 字段没有对齐
+` + "```"}), prompt.Default())
+
+	review, err := uc.ReviewPatch(context.Background(), "svc", "=== store/store.go (modified)\n", "")
+	if err != nil {
+		t.Fatalf("ReviewPatch: %v", err)
+	}
+	if review.Summary != "给 Store 增加了计数" || len(review.Findings) != 2 {
+		t.Fatalf("review = %+v", review)
+	}
+	if got := review.Findings[0]; got.Category != entity.ReviewBug || got.Severity != entity.SeverityHigh || got.Line != 6 {
+		t.Errorf("first finding = %+v", got)
+	}
+	if got := review.Findings[1]; got.Category != entity.ReviewOther || got.Severity != entity.SeverityMedium {
+		t.Errorf("unknown category and severity were not normalized: %+v", got)
+	}
+}
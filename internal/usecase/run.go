@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"codetest/internal/entity"
+)
+
+// FailuresFileName 分析失败的文件保存在输出目录下的这个文件中
+const FailuresFileName = "failures.json"
+
+// 文件的处理结果
+const (
+	FileSucceeded = "succeeded" // 调用 LLM 分析成功
+	FileCached    = "cached"    // 内容和提示词都没有变化，使用之前保存的结果
+	FileSkipped   = "skipped"   // 超出预算或 --retry-failed 时没有重新分析
+	FileFailed    = "failed"
+)
+
+// FileStatuses 汇总表中各处理结果的顺序
+var FileStatuses = []string{FileSucceeded, FileCached, FileSkipped, FileFailed}
+
+// FileError 单个文件的处理错误
+type FileError struct {
+	Path string
+	Err  error
+}
+
+// Error 实现 error
+func (e *FileError) Error() string {
+	if e.Err == nil {
+		return e.Path + ": failed"
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap 返回原始错误，errors.Is 可以判断失败原因，例如 entity.ErrUnauthorized
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// FileErrors 一次运行中所有失败文件的错误
+type FileErrors struct {
+	Errors    []*FileError
+	Files     int // 处理的文件数
+	Succeeded int // 成功或使用缓存的文件数
+}
+
+// Error 实现 error，只包含第一个错误，完整的列表在 failures.json 中
+func (e *FileErrors) Error() string {
+	return fmt.Sprintf("%d of %d files failed, first: %v", len(e.Errors), e.Files, e.Errors[0])
+}
+
+// Unwrap 返回每个文件的错误
+func (e *FileErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// AllFailed 判断是否每个文件都分析失败。跳过的文件（超出预算、--retry-failed 或 --since 时没有保存的结果）
+// 没有尝试分析，不算失败，有跳过的文件时只是部分失败
+func (e *FileErrors) AllFailed() bool {
+	return len(e.Errors) == e.Files
+}
+
+// FailThreshold --fail-on-error 的阈值：失败的文件达到 Count 个或处理的文件的 Percent% 时运行失败，
+// 都为 0 时单个文件失败不会导致运行失败
+type FailThreshold struct {
+	Count   int
+	Percent float64
+}
+
+// ParseFailThreshold 解析文件数（例如 10）或百分比（例如 5%）
+func ParseFailThreshold(value string) (FailThreshold, error) {
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || p < 0 || p > 100 {
+			return FailThreshold{}, fmt.Errorf("invalid failure threshold %q, want a number of files or a percentage between 0%% and 100%%", value)
+		}
+		return FailThreshold{Percent: p}, nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return FailThreshold{}, fmt.Errorf("invalid failure threshold %q, want a number of files or a percentage between 0%% and 100%%", value)
+	}
+	return FailThreshold{Count: count}, nil
+}
+
+// Exceeded 判断 files 个文件中失败 failed 个是否达到阈值
+func (t FailThreshold) Exceeded(failed, files int) bool {
+	if failed == 0 {
+		return false
+	}
+	if t.Count > 0 && failed >= t.Count {
+		return true
+	}
+	return t.Percent > 0 && files > 0 && float64(failed)*100 >= t.Percent*float64(files)
+}
+
+// RunSummary 统计一次 analyze 中每个文件的处理结果
+type RunSummary struct {
+	mutex  sync.Mutex
+	counts map[string]int
+	errs   []*FileError
+}
+
+// NewRunSummary 创建新的 RunSummary
+func NewRunSummary() *RunSummary {
+	return &RunSummary{counts: map[string]int{}}
+}
+
+// Add 记录一个文件的处理结果，status 为 FileFailed 时 err 为失败原因
+func (s *RunSummary) Add(path, status string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.counts[status]++
+	if status == FileFailed {
+		s.errs = append(s.errs, &FileError{Path: path, Err: err})
+	}
+}
+
+// Count 返回处理结果为 status 的文件数
+func (s *RunSummary) Count(status string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.counts[status]
+}
+
+// Total 返回已处理的文件数
+func (s *RunSummary) Total() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	total := 0
+	for _, count := range s.counts {
+		total += count
+	}
+	return total
+}
+
+// Failures 返回分析失败的文件
+func (s *RunSummary) Failures() []entity.FileFailure {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var failures []entity.FileFailure
+	for _, e := range s.errs {
+		failure := entity.FileFailure{Path: e.Path}
+		if e.Err != nil {
+			failure.Error = e.Err.Error()
+		}
+		failures = append(failures, failure)
+	}
+	return failures
+}
+
+// Err 返回收集到的文件错误，没有失败的文件时返回 nil
+func (s *RunSummary) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.errs) == 0 {
+		return nil
+	}
+	files := 0
+	for _, count := range s.counts {
+		files += count
+	}
+	return &FileErrors{
+		Errors:    append([]*FileError(nil), s.errs...),
+		Files:     files,
+		Succeeded: s.counts[FileSucceeded] + s.counts[FileCached],
+	}
+}
+
+// WriteFailures 把分析失败的文件写入 dir/failures.json，没有失败时写入空列表，
+// 这样 --retry-failed 不会重试上一次运行已经成功的文件
+func (s *RunSummary) WriteFailures(dir, runID string) (string, error) {
+	report := entity.FailureReport{RunID: runID, CreatedAt: time.Now().UTC(), Failures: s.Failures()}
+	if report.Failures == nil {
+		report.Failures = []entity.FileFailure{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode failures: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %v", err)
+	}
+	path := filepath.Join(dir, FailuresFileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write failures: %v", err)
+	}
+	return path, nil
+}
+
+// LoadFailures 读取 WriteFailures 写入的文件
+func LoadFailures(path string) (entity.FailureReport, error) {
+	var report entity.FailureReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("failed to read failures: %v", err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("failed to decode failures %s: %v", path, err)
+	}
+	return report, nil
+}
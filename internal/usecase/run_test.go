@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+func TestRunSummary(t *testing.T) {
+	summary := NewRunSummary()
+	summary.Add("a.go", FileSucceeded, nil)
+	summary.Add("b.go", FileCached, nil)
+	summary.Add("c.go", FileSkipped, ErrBudgetExceeded)
+	summary.Add("d.go", FileFailed, errors.New("timeout"))
+	if summary.Total() != 4 || summary.Count(FileFailed) != 1 || summary.Count(FileSkipped) != 1 {
+		t.Errorf("counts = %+v", summary.counts)
+	}
+
+	dir := t.TempDir()
+	path, err := summary.WriteFailures(dir, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, FailuresFileName) {
+		t.Errorf("path = %s", path)
+	}
+	report, err := LoadFailures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.RunID != "run-1" || len(report.Failures) != 1 || report.Failures[0].Path != "d.go" || report.Failures[0].Error != "timeout" {
+		t.Errorf("report = %+v", report)
+	}
+
+	// 没有失败时写入空列表，覆盖上一次的失败
+	if _, err := NewRunSummary().WriteFailures(dir, "run-2"); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := LoadFailures(path); err != nil || report.RunID != "run-2" || report.Failures == nil || len(report.Failures) != 0 {
+		t.Errorf("empty report = %+v, %v", report, err)
+	}
+}
+
+func TestRunSummaryErr(t *testing.T) {
+	summary := NewRunSummary()
+	summary.Add("a.go", FileCached, nil)
+	if summary.Err() != nil {
+		t.Fatal("no failures must return nil")
+	}
+	summary.Add("b.go", FileFailed, fmt.Errorf("AI analysis failed: %w", entity.ErrUnauthorized))
+	summary.Add("c.go", FileFailed, errors.New("timeout"))
+
+	var fileErrs *FileErrors
+	err := summary.Err()
+	if !errors.As(err, &fileErrs) || len(fileErrs.Errors) != 2 || fileErrs.Files != 3 || fileErrs.AllFailed() {
+		t.Fatalf("err = %#v", err)
+	}
+	if !errors.Is(err, entity.ErrUnauthorized) {
+		t.Error("file errors must unwrap to the cause")
+	}
+	if !strings.HasPrefix(err.Error(), "2 of 3 files failed, first: b.go: ") {
+		t.Errorf("message = %q", err.Error())
+	}
+
+	failed := NewRunSummary()
+	failed.Add("a.go", FileFailed, nil)
+	if !errors.As(failed.Err(), &fileErrs) || !fileErrs.AllFailed() {
+		t.Errorf("err = %v, want all failed", failed.Err())
+	}
+
+	// 跳过的文件没有尝试分析，一个文件失败、其余跳过时不是全部失败
+	failed.Add("b.go", FileSkipped, nil)
+	failed.Add("c.go", FileSkipped, nil)
+	if !errors.As(failed.Err(), &fileErrs) || fileErrs.AllFailed() {
+		t.Errorf("err = %v, want a partial failure", failed.Err())
+	}
+}
+
+func TestFailThreshold(t *testing.T) {
+	tests := []struct {
+		value         string
+		failed, files int
+		want          bool
+	}{
+		{"1", 1, 100, true},
+		{"1", 0, 100, false},
+		{"0", 50, 100, false},
+		{"10", 9, 100, false},
+		{"5%", 4, 100, false},
+		{"5%", 5, 100, true},
+		{"0%", 5, 100, false},
+	}
+	for _, tt := range tests {
+		threshold, err := ParseFailThreshold(tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := threshold.Exceeded(tt.failed, tt.files); got != tt.want {
+			t.Errorf("%s: Exceeded(%d, %d) = %v", tt.value, tt.failed, tt.files, got)
+		}
+	}
+	for _, value := range []string{"-1", "many", "120%"} {
+		if _, err := ParseFailThreshold(value); err == nil {
+			t.Errorf("%q must be invalid", value)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"codetest/internal/entity"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// multiSink 把结果依次交给多个 Sink，某个 Sink 失败不影响其他 Sink
+type multiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink 创建组合多个输出目标的 Sink
+func NewMultiSink(sinks ...Sink) Sink {
+	return &multiSink{sinks: sinks}
+}
+
+// Name 返回所有 Sink 的名称
+func (m *multiSink) Name() string {
+	name := ""
+	for i, sink := range m.sinks {
+		if i > 0 {
+			name += ","
+		}
+		name += sink.Name()
+	}
+	return name
+}
+
+// SaveFileResult 保存到所有 Sink，返回合并后的错误
+func (m *multiSink) SaveFileResult(ctx context.Context, result entity.FileResult) error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.SaveFileResult(ctx, result); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 关闭所有 Sink，返回合并后的错误
+func (m *multiSink) Close(ctx context.Context, summary entity.ProjectSummary) error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.Close(ctx, summary); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush 调用实现了 Flusher 的 Sink，返回合并后的错误
+func (m *multiSink) Flush(ctx context.Context) error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if flusher, ok := sink.(Flusher); ok {
+			if err := flusher.Flush(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RemoveFileResult 从实现了 Remover 的 Sink 中删除文件结果，返回合并后的错误
+func (m *multiSink) RemoveFileResult(ctx context.Context, path string) error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if remover, ok := sink.(Remover); ok {
+			if err := remover.RemoveFileResult(ctx, path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+// memorySink 记录收到的结果，err 不为空时返回错误
+type memorySink struct {
+	name    string
+	err     error
+	paths   []string
+	summary entity.ProjectSummary
+}
+
+func (s *memorySink) Name() string { return s.name }
+
+func (s *memorySink) SaveFileResult(ctx context.Context, result entity.FileResult) error {
+	s.paths = append(s.paths, result.Path)
+	return s.err
+}
+
+func (s *memorySink) Close(ctx context.Context, summary entity.ProjectSummary) error {
+	s.summary = summary
+	return s.err
+}
+
+func TestMultiSinkContinuesAfterError(t *testing.T) {
+	failing := &memorySink{name: "remote", err: errors.New("unavailable")}
+	local := &memorySink{name: "local"}
+	sink := NewMultiSink(failing, local)
+
+	err := sink.SaveFileResult(context.Background(), entity.FileResult{Path: "a.go"})
+	if err == nil || !errors.Is(err, failing.err) {
+		t.Fatalf("err = %v, want the remote error", err)
+	}
+	if len(local.paths) != 1 || local.paths[0] != "a.go" {
+		t.Errorf("local sink paths = %v, want [a.go]", local.paths)
+	}
+
+	if err := sink.Close(context.Background(), entity.ProjectSummary{Description: "summary"}); !errors.Is(err, failing.err) {
+		t.Errorf("close err = %v, want the remote error", err)
+	}
+	if local.summary.Description != "summary" {
+		t.Errorf("local summary = %q", local.summary)
+	}
+	if sink.Name() != "remote,local" {
+		t.Errorf("name = %q", sink.Name())
+	}
+}
+
+// flushingSink 记录 Flush 的调用次数
+type flushingSink struct {
+	memorySink
+	flushed int
+}
+
+func (s *flushingSink) Flush(ctx context.Context) error {
+	s.flushed++
+	return s.err
+}
+
+func TestMultiSinkFlush(t *testing.T) {
+	buffered := &flushingSink{memorySink: memorySink{name: "remote"}}
+	sink := NewMultiSink(&memorySink{name: "local"}, buffered)
+	flusher, ok := sink.(Flusher)
+	if !ok {
+		t.Fatal("multi sink must implement Flusher")
+	}
+	if err := flusher.Flush(context.Background()); err != nil || buffered.flushed != 1 {
+		t.Errorf("flush = %v, flushed %d times", err, buffered.flushed)
+	}
+}
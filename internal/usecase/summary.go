@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"codetest/internal/entity"
+	"codetest/internal/usecase/prompt"
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MaxProjectDescLength Project.Desc 的最大长度（字符）
+const MaxProjectDescLength = 4096
+
+// projectDescTarget 要求 LLM 生成的项目描述长度，低于上限以留出余量
+const projectDescTarget = 1500
+
+// maxSummaryRounds 输入过长时分块汇总的最大轮数
+const maxSummaryRounds = 3
+
+// summaryInputLimit 单次汇总调用的输入上限（字符），超出时分块汇总
+var summaryInputLimit = 24000
+
+// EstimateSummaryTokens 粗略估算 SummarizeProject 的 token 数：每个文件的总结条目约 150 token，
+// 每个包的总结约 300 token，项目概览约 1500 token
+func EstimateSummaryTokens(files, packages int) (promptTokens, completionTokens int) {
+	if files == 0 {
+		return 0, 0
+	}
+	const entryTokens, packageTokens, overviewTokens, templateTokens = 150, 300, 1500, 250
+	promptTokens = files*entryTokens + packages*templateTokens + // 包总结
+		packages*packageTokens + templateTokens + // 项目概览
+		overviewTokens + templateTokens // 项目描述
+	completionTokens = packages*packageTokens + overviewTokens + projectDescTarget/2
+	return promptTokens, completionTokens
+}
+
+// SummarizeProject 把文件总结逐层汇总：文件 -> 包（目录）-> 项目概览 -> 简洁的项目描述。
+// reuse 按包路径给出之前保存的包总结，文件列表没有变化的包直接使用，不再调用 LLM，可以为 nil
+func (uc *aiCodeUseCase) SummarizeProject(ctx context.Context, projectName string, files []entity.FileResult,
+	reuse map[string]entity.PackageSummary) (entity.ProjectSummary, error) {
+	summary := entity.ProjectSummary{Name: projectName}
+	if len(files) == 0 {
+		return summary, fmt.Errorf("no file results to summarize")
+	}
+
+	// 按目录分组，目录和文件都按路径排序，保证提示词稳定
+	byPackage := map[string][]entity.FileResult{}
+	for _, file := range files {
+		dir := filepath.Dir(file.Path)
+		byPackage[dir] = append(byPackage[dir], file)
+	}
+	packages := make([]string, 0, len(byPackage))
+	for dir := range byPackage {
+		packages = append(packages, dir)
+	}
+	sort.Strings(packages)
+
+	var packageEntries []string
+	for _, dir := range packages {
+		pkgFiles := byPackage[dir]
+		sort.Slice(pkgFiles, func(i, j int) bool { return pkgFiles[i].Path < pkgFiles[j].Path })
+
+		pkg := entity.PackageSummary{Path: dir}
+		var entries []string
+		for i := range pkgFiles {
+			pkg.Files = append(pkg.Files, pkgFiles[i].Path)
+			entries = append(entries, entity.SummaryEntry(pkgFiles[i].Path, &pkgFiles[i].Parsed))
+		}
+		text := ""
+		if previous, ok := reuse[dir]; ok && previous.Summary != "" && slices.Equal(previous.Files, pkg.Files) {
+			text = previous.Summary
+		} else {
+			var err error
+			if text, err = uc.condense(ctx, prompt.PackageSummary, prompt.Data{Project: projectName, Package: dir}, entries); err != nil {
+				return summary, fmt.Errorf("failed to summarize package %s: %w", dir, err)
+			}
+		}
+		pkg.Summary = text
+		summary.Packages = append(summary.Packages, pkg)
+		packageEntries = append(packageEntries, fmt.Sprintf("## %s\n%s\n", dir, text))
+	}
+
+	overview, err := uc.condense(ctx, prompt.ProjectOverview, prompt.Data{Project: projectName}, packageEntries)
+	if err != nil {
+		return summary, fmt.Errorf("failed to write project overview: %w", err)
+	}
+	summary.Overview = overview
+
+	description, err := askLLM(ctx, uc.client, uc.prompts, prompt.ProjectDescription, prompt.Data{
+		Project:   projectName,
+		Summary:   overview,
+		MaxLength: projectDescTarget,
+	})
+	if err != nil {
+		return summary, fmt.Errorf("failed to write project description: %w", err)
+	}
+	summary.Description = truncateRunes(strings.TrimSpace(description), MaxProjectDescLength)
+	return summary, nil
+}
+
+// condense 用模板 name 汇总 entries。输入超过 summaryInputLimit 时先分块汇总，
+// 再把各块的结果作为新的输入继续汇总，最多 maxSummaryRounds 轮。
+func (uc *aiCodeUseCase) condense(ctx context.Context, name string, data prompt.Data, entries []string) (string, error) {
+	for round := 0; ; round++ {
+		chunks := chunkEntries(entries, summaryInputLimit)
+		if len(chunks) == 1 || round == maxSummaryRounds {
+			data.Summary = truncateRunes(strings.Join(entries, "\n"), summaryInputLimit)
+			return askLLM(ctx, uc.client, uc.prompts, name, data)
+		}
+
+		next := make([]string, 0, len(chunks))
+		for _, chunk := range chunks {
+			data.Summary = strings.Join(chunk, "\n")
+			text, err := askLLM(ctx, uc.client, uc.prompts, name, data)
+			if err != nil {
+				return "", err
+			}
+			next = append(next, text)
+		}
+		entries = next
+	}
+}
+
+// chunkEntries 把 entries 按顺序分成总长度不超过 limit 的块，单个过长的条目会被截断
+func chunkEntries(entries []string, limit int) [][]string {
+	var chunks [][]string
+	var current []string
+	size := 0
+	for _, entry := range entries {
+		entry = truncateRunes(entry, limit)
+		n := len([]rune(entry)) + 1
+		if len(current) > 0 && size+n > limit {
+			chunks = append(chunks, current)
+			current, size = nil, 0
+		}
+		current = append(current, entry)
+		size += n
+	}
+	if len(current) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// truncateRunes 把文本截断到最多 max 个字符
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
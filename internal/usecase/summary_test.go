@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+	"codetest/internal/usecase/prompt"
+)
+
+// summaryFiles 两个包中三个文件的分析结果
+func summaryFiles() []entity.FileResult {
+	file := func(path, pkg, desc string, imports ...string) entity.FileResult {
+		var parsed entity.ParsedYAML
+		parsed.FileDescription = desc
+		parsed.FileInfo.PackageName = pkg
+		parsed.FileInfo.Imports = imports
+		return entity.FileResult{Path: path, Parsed: parsed}
+	}
+	return []entity.FileResult{
+		file("svc/store/store.go", "store", "线程安全的内存存储", "errors", "sync"),
+		file("svc/main.go", "main", "启动 HTTP 服务", "net/http", "svc/store"),
+		file("svc/store/handler.go", "store", "通过 HTTP 暴露 Store", "fmt", "net/http"),
+	}
+}
+
+func TestSummarizeProject(t *testing.T) {
+	longDescription := strings.Repeat("描述", MaxProjectDescLength)
+	uc := NewAiCode(llmForTest(t, []string{
+		"启动 HTTP 服务的入口包。",
+		"内存存储以及它的 HTTP 接口。",
+		"## 项目简介\n内存 KV 服务\n## 入口\nsvc/main.go",
+		longDescription,
+	}), prompt.Default())
+
+	summary, err := uc.SummarizeProject(context.Background(), "svc", summaryFiles(), nil)
+	if err != nil {
+		t.Fatalf("SummarizeProject: %v", err)
+	}
+	if len(summary.Packages) != 2 || summary.Packages[0].Path != "svc" || summary.Packages[1].Path != "svc/store" {
+		t.Fatalf("packages = %+v", summary.Packages)
+	}
+	if got := strings.Join(summary.Packages[1].Files, ","); got != "svc/store/handler.go,svc/store/store.go" {
+		t.Errorf("svc/store files = %s", got)
+	}
+	if summary.Packages[1].Summary != "内存存储以及它的 HTTP 接口。" {
+		t.Errorf("svc/store summary = %q", summary.Packages[1].Summary)
+	}
+	if !strings.Contains(summary.Overview, "## 入口") {
+		t.Errorf("overview = %q", summary.Overview)
+	}
+	if n := len([]rune(summary.Description)); n != MaxProjectDescLength {
+		t.Errorf("description has %d characters, want it truncated to %d", n, MaxProjectDescLength)
+	}
+}
+
+func TestSummarizeProjectReusesUnchangedPackages(t *testing.T) {
+	client := &stubLLMClient{responses: []string{"新的 svc 包", "概览", "描述"}}
+	uc := NewAiCode(client, prompt.Default())
+	reuse := map[string]entity.PackageSummary{
+		// 文件列表没有变化，直接使用
+		"svc/store": {Path: "svc/store", Files: []string{"svc/store/handler.go", "svc/store/store.go"}, Summary: "保存的 svc/store 包"},
+		// 文件列表变化了，重新总结
+		"svc": {Path: "svc", Files: []string{"svc/main.go", "svc/old.go"}, Summary: "保存的 svc 包"},
+	}
+	summary, err := uc.SummarizeProject(context.Background(), "svc", summaryFiles(), reuse)
+	if err != nil {
+		t.Fatalf("SummarizeProject: %v", err)
+	}
+	if len(client.prompts) != 3 {
+		t.Fatalf("got %d LLM calls, want 3 (svc, overview, description)", len(client.prompts))
+	}
+	if summary.Packages[0].Summary != "新的 svc 包" || summary.Packages[1].Summary != "保存的 svc/store 包" {
+		t.Errorf("packages = %+v", summary.Packages)
+	}
+	if overview := client.prompts[1]; !strings.Contains(overview, "保存的 svc/store 包") || !strings.Contains(overview, "新的 svc 包") {
+		t.Errorf("overview prompt does not contain both package summaries:\n%s", overview)
+	}
+}
+
+func TestSummarizeProjectChunksLongInput(t *testing.T) {
+	defer func(limit int) { summaryInputLimit = limit }(summaryInputLimit)
+	summaryInputLimit = 150 // 每个文件条目约 80 字符，一块只能放下一个条目
+
+	client := &stubLLMClient{responses: []string{
+		"svc 包",
+		"store.go 部分", "handler.go 部分", "svc/store 包",
+		"概览", "描述",
+	}}
+	uc := NewAiCode(client, prompt.Default())
+	summary, err := uc.SummarizeProject(context.Background(), "svc", summaryFiles(), nil)
+	if err != nil {
+		t.Fatalf("SummarizeProject: %v", err)
+	}
+	if len(client.prompts) != 6 {
+		t.Fatalf("got %d LLM calls, want 6", len(client.prompts))
+	}
+	if summary.Packages[1].Summary != "svc/store 包" || summary.Description != "描述" {
+		t.Errorf("summary = %+v", summary)
+	}
+	// 第二轮的输入是第一轮各块的总结
+	if final := client.prompts[3]; !strings.Contains(final, "store.go 部分") || !strings.Contains(final, "handler.go 部分") {
+		t.Errorf("second round prompt does not contain the chunk summaries:\n%s", final)
+	}
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Handler 通过 HTTP 暴露 Store
+type Handler struct {
+	store *Store
+}
+
+// NewHandler 创建新的 Handler
+func NewHandler(store *Store) *Handler {
+	return &Handler{store: store}
+}
+
+// ServeHTTP 处理 GET /items?key=xxx 请求
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	value, err := h.store.Get(r.URL.Query().Get("key"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, value)
+}
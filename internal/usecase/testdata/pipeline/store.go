@@ -0,0 +1,41 @@
+package store
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("not found")
+
+// MaxItems 存储的最大记录数
+const MaxItems = 1024
+
+// Store 线程安全的内存存储
+type Store struct {
+	mutex sync.Mutex
+	items map[string]string
+}
+
+// NewStore 创建新的 Store
+func NewStore() *Store {
+	return &Store{items: map[string]string{}}
+}
+
+// Get 读取记录
+func (s *Store) Get(key string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.items[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// Put 写入记录
+func (s *Store) Put(key, value string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.items[key] = value
+}
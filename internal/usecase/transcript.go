@@ -0,0 +1,224 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"codetest/internal/entity"
+)
+
+// TranscriptEntry transcript 中的一次 LLM 调用，每行一条 JSON
+type TranscriptEntry struct {
+	Time             time.Time `json:"time"`
+	RunID            string    `json:"run_id,omitempty"`
+	Stage            string    `json:"stage,omitempty"`
+	File             string    `json:"file,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	PromptHash       string    `json:"prompt_hash"`
+	Prompt           string    `json:"prompt"`
+	Response         string    `json:"response"`
+	Error            string    `json:"error,omitempty"`
+	LatencyMS        int64     `json:"latency_ms"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+
+	Embeddings [][]float32 `json:"embeddings,omitempty"` // 向量请求的结果，Prompt 为 EmbeddingPrompt
+}
+
+// transcriptHeaderType TranscriptHeader 的 type
+const transcriptHeaderType = "header"
+
+// TranscriptHeader 每次运行第一次调用 LLM 时写在调用记录之前，保存客户端配置的模型。
+// 回复中的模型名称可能是带日期的快照名称，与配置的别名不同，回放时使用这里的模型
+type TranscriptHeader struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	RunID string    `json:"run_id,omitempty"`
+	Model string    `json:"model"`
+}
+
+// Transcript 从文件读取的 transcript，同一个文件中可能追加了多次运行
+type Transcript struct {
+	Headers []TranscriptHeader
+	Entries []TranscriptEntry
+}
+
+// Model 返回第一次运行配置的模型，没有头记录（旧版本写入）时返回空字符串
+func (t *Transcript) Model() string {
+	if len(t.Headers) == 0 {
+		return ""
+	}
+	return t.Headers[0].Model
+}
+
+// Record 转换为回放使用的记录
+func (e TranscriptEntry) Record() LLMRecord {
+	return LLMRecord{
+		PromptHash:       e.PromptHash,
+		Prompt:           e.Prompt,
+		Response:         e.Response,
+		Model:            e.Model,
+		PromptTokens:     e.PromptTokens,
+		CompletionTokens: e.CompletionTokens,
+		Error:            e.Error,
+		Embeddings:       e.Embeddings,
+	}
+}
+
+// TranscriptRecorder 转发调用到真实的 LLM 客户端，并把每次调用按 JSON Lines 写入 transcript
+type TranscriptRecorder struct {
+	inner LLMClient
+	runID string
+	model string
+
+	mutex         sync.Mutex
+	w             io.Writer
+	headerWritten bool
+}
+
+// NewTranscriptRecorder 创建新的 TranscriptRecorder，runID 写入每条记录，用于关联本次运行的日志；
+// model 为 inner 配置的模型，第一次调用时写入 TranscriptHeader
+func NewTranscriptRecorder(inner LLMClient, w io.Writer, runID, model string) *TranscriptRecorder {
+	return &TranscriptRecorder{inner: inner, w: w, runID: runID, model: model}
+}
+
+// GetResponse 调用真实客户端并记录调用，包括失败的调用；写入 transcript 失败不影响调用结果
+func (r *TranscriptRecorder) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
+	start := time.Now()
+	response, err := r.inner.GetResponse(ctx, prompt)
+	entry := TranscriptEntry{
+		Time:             start,
+		RunID:            r.runID,
+		Stage:            StageFromContext(ctx),
+		File:             FileFromContext(ctx),
+		Model:            response.Model,
+		PromptHash:       PromptHash(prompt),
+		Prompt:           prompt,
+		Response:         response.Content,
+		LatencyMS:        time.Since(start).Milliseconds(),
+		PromptTokens:     response.PromptTokens,
+		CompletionTokens: response.CompletionTokens,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if werr := r.write(entry); werr != nil {
+		slog.WarnContext(ctx, "Failed to write LLM transcript", "error", werr)
+	}
+	return response, err
+}
+
+// Embed 实现 Embedder，调用真实客户端并记录调用，prompt 记为 EmbeddingPrompt(texts)
+func (r *TranscriptRecorder) Embed(ctx context.Context, texts []string) (entity.EmbeddingResponse, error) {
+	start := time.Now()
+	response, err := Embed(ctx, r.inner, texts)
+	prompt := EmbeddingPrompt(texts)
+	entry := TranscriptEntry{
+		Time:         start,
+		RunID:        r.runID,
+		Stage:        StageFromContext(ctx),
+		Model:        response.Model,
+		PromptHash:   PromptHash(prompt),
+		Prompt:       prompt,
+		LatencyMS:    time.Since(start).Milliseconds(),
+		PromptTokens: response.PromptTokens,
+		Embeddings:   response.Vectors,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if werr := r.write(entry); werr != nil {
+		slog.WarnContext(ctx, "Failed to write LLM transcript", "error", werr)
+	}
+	return response, err
+}
+
+// write 写入一条调用记录，第一次写入前先写入 TranscriptHeader
+func (r *TranscriptRecorder) write(entry TranscriptEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.headerWritten {
+		header, err := json.Marshal(TranscriptHeader{Type: transcriptHeaderType, Time: entry.Time, RunID: r.runID, Model: r.model})
+		if err != nil {
+			return err
+		}
+		data = append(append(header, '\n'), data...)
+	}
+	if _, err = r.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	r.headerWritten = true
+	return nil
+}
+
+// LoadTranscript 读取 JSON Lines 格式的 transcript，忽略空行
+func LoadTranscript(path string) (*Transcript, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %v", err)
+	}
+	defer file.Close()
+
+	transcript := &Transcript{}
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		text, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read transcript: %v", err)
+		}
+		if strings.TrimSpace(text) != "" {
+			if err := transcript.add([]byte(text)); err != nil {
+				return nil, fmt.Errorf("invalid transcript entry at %s:%d: %v", path, line, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return transcript, nil
+		}
+	}
+}
+
+// add 解析一行记录，按 type 区分头记录和调用记录
+func (t *Transcript) add(line []byte) error {
+	var kind struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &kind); err != nil {
+		return err
+	}
+	if kind.Type == transcriptHeaderType {
+		var header TranscriptHeader
+		if err := json.Unmarshal(line, &header); err != nil {
+			return err
+		}
+		t.Headers = append(t.Headers, header)
+		return nil
+	}
+	var entry TranscriptEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return err
+	}
+	t.Entries = append(t.Entries, entry)
+	return nil
+}
+
+// NewTranscriptReplayClient 用 transcript 中的调用创建 ReplayClient，失败的调用回放时返回同样的错误
+func NewTranscriptReplayClient(entries []TranscriptEntry) *ReplayClient {
+	records := make([]LLMRecord, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, entry.Record())
+	}
+	return NewReplayClientFromRecords(records)
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTranscriptRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := NewTranscriptRecorder(&stubLLMClient{responses: []string{"first", "second"}}, file, "run-1", "gpt-4o-mini-alias")
+
+	ctx := WithFile(WithStage(context.Background(), "file"), "a.go")
+	for _, prompt := range []string{"analyze a.go", "analyze a.go", "summarize"} {
+		recorder.GetResponse(ctx, prompt)
+	}
+	file.Close()
+
+	transcript, err := LoadTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 头记录保存配置的模型，调用记录保存回复中的模型
+	if len(transcript.Headers) != 1 || transcript.Model() != "gpt-4o-mini-alias" || transcript.Headers[0].RunID != "run-1" {
+		t.Errorf("headers = %+v", transcript.Headers)
+	}
+	entries := transcript.Entries
+	if len(entries) != 3 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	first := entries[0]
+	if first.RunID != "run-1" || first.Stage != "file" || first.File != "a.go" || first.Model != "gpt-4o-mini" ||
+		first.Prompt != "analyze a.go" || first.Response != "first" || first.PromptHash != PromptHash("analyze a.go") {
+		t.Errorf("first entry = %+v", first)
+	}
+	if entries[2].Error != "no more stub responses" {
+		t.Errorf("failed calls are recorded, got %+v", entries[2])
+	}
+
+	// 回放时同一个 prompt 按记录顺序返回，失败的调用返回同样的错误
+	replay := NewTranscriptReplayClient(entries)
+	for _, want := range []string{"first", "second"} {
+		if response, err := replay.GetResponse(ctx, "analyze a.go"); err != nil || response.Content != want {
+			t.Errorf("replay = %q, %v, want %q", response.Content, err, want)
+		}
+	}
+	if _, err := replay.GetResponse(ctx, "summarize"); err == nil || err.Error() != "no more stub responses" {
+		t.Errorf("replayed error = %v", err)
+	}
+	if _, err := replay.GetResponse(ctx, "unknown"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("unknown prompt error = %v", err)
+	}
+}
+
+func TestLoadTranscriptInvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	if err := os.WriteFile(path, []byte("{\"prompt\":\"a\"}\n\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTranscript(path); err == nil || !strings.Contains(err.Error(), ":3:") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"codetest/internal/entity"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrBudgetExceeded 继续调用会超出预算
+var ErrBudgetExceeded = errors.New("LLM budget exceeded")
+
+type contextKey int
+
+const (
+	stageKey contextKey = iota
+	fileKey
+)
+
+// WithStage 在 context 中标记当前调用所属的阶段
+func WithStage(ctx context.Context, stage string) context.Context {
+	return context.WithValue(ctx, stageKey, stage)
+}
+
+// WithFile 在 context 中标记当前调用所属的文件
+func WithFile(ctx context.Context, file string) context.Context {
+	return context.WithValue(ctx, fileKey, file)
+}
+
+// StageFromContext 返回 context 中的阶段
+func StageFromContext(ctx context.Context) string {
+	stage, _ := ctx.Value(stageKey).(string)
+	return stage
+}
+
+// FileFromContext 返回 context 中的文件
+func FileFromContext(ctx context.Context) string {
+	file, _ := ctx.Value(fileKey).(string)
+	return file
+}
+
+// ModelPrice 模型每百万 token 的价格
+type ModelPrice struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// PriceTable 模型名称到价格的映射，模型名称按最长前缀匹配
+type PriceTable map[string]ModelPrice
+
+// DefaultPrices 默认价格表（美元 / 百万 token）
+func DefaultPrices() PriceTable {
+	return PriceTable{
+		"gpt-4o-mini":   {Prompt: 0.15, Completion: 0.60},
+		"gpt-4o":        {Prompt: 2.50, Completion: 10.00},
+		"gpt-4.1-mini":  {Prompt: 0.40, Completion: 1.60},
+		"gpt-4.1":       {Prompt: 2.00, Completion: 8.00},
+		"gpt-3.5-turbo": {Prompt: 0.50, Completion: 1.50},
+		"qwen-plus":     {Prompt: 0.11, Completion: 0.28},
+
+		"text-embedding-3-small": {Prompt: 0.02},
+	}
+}
+
+// LoadPriceTable 读取 YAML 价格表并覆盖默认价格，file 为空时返回默认价格表
+func LoadPriceTable(file string) (PriceTable, error) {
+	prices := DefaultPrices()
+	if file == "" {
+		return prices, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price table: %v", err)
+	}
+	var custom PriceTable
+	if err := yaml.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to decode price table: %v", err)
+	}
+	for model, price := range custom {
+		prices[model] = price
+	}
+	return prices, nil
+}
+
+// Lookup 查找模型价格
+func (p PriceTable) Lookup(model string) (ModelPrice, bool) {
+	best := ""
+	for name := range p {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return p[best], true
+}
+
+// Cost 计算一次调用的费用
+func (p PriceTable) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, _ := p.Lookup(model)
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6
+}
+
+// EstimateTokens 粗略估算文本的 token 数：ASCII 约 4 个字符一个 token，其他字符（如中文）约一个字一个 token
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateCompletionTokens 粗略估算分析一个文件时回复的 token 数：约为代码的三分之一，至少 200
+func EstimateCompletionTokens(code string) int {
+	tokens := EstimateTokens(code) / 3
+	if tokens < 200 {
+		tokens = 200
+	}
+	return tokens
+}
+
+// UsageMeter 统计 LLM 调用的 token 用量和费用，并在超出预算前拒绝调用
+type UsageMeter struct {
+	RunID  string // 写入报告，与本次运行的日志关联
+	Commit string // 写入报告，分析的提交 SHA
+
+	inner        LLMClient
+	prices       PriceTable
+	budget       float64
+	defaultModel string
+
+	mutex  sync.Mutex
+	report entity.UsageReport
+}
+
+// NewUsageMeter 创建新的 UsageMeter，budget 为 0 表示不限制；
+// defaultModel 用于在调用前估算费用以及回复中没有模型名称时计价
+func NewUsageMeter(inner LLMClient, prices PriceTable, budget float64, defaultModel string) *UsageMeter {
+	return &UsageMeter{
+		inner:        inner,
+		prices:       prices,
+		budget:       budget,
+		defaultModel: defaultModel,
+		report: entity.UsageReport{
+			StartedAt: time.Now(),
+			Currency:  "USD",
+			Budget:    budget,
+			ByStage:   map[string]entity.TokenUsage{},
+			ByModel:   map[string]entity.TokenUsage{},
+			ByFile:    map[string]entity.TokenUsage{},
+		},
+	}
+}
+
+// GetResponse 检查预算后调用 LLM，并按阶段、文件、模型记录用量
+func (m *UsageMeter) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
+	if err := m.checkBudget(EstimateTokens(prompt), true); err != nil {
+		return entity.LLMResponse{}, err
+	}
+
+	response, err := m.inner.GetResponse(ctx, prompt)
+	if err != nil {
+		return response, err
+	}
+
+	promptTokens, completionTokens := response.PromptTokens, response.CompletionTokens
+	if promptTokens == 0 && completionTokens == 0 {
+		promptTokens, completionTokens = EstimateTokens(prompt), EstimateTokens(response.Content)
+	}
+	m.add(ctx, response.Model, promptTokens, completionTokens)
+	return response, nil
+}
+
+// Embed 实现 Embedder，检查预算后计算向量并记录用量，向量请求只有 prompt token
+func (m *UsageMeter) Embed(ctx context.Context, texts []string) (entity.EmbeddingResponse, error) {
+	promptTokens := 0
+	for _, text := range texts {
+		promptTokens += EstimateTokens(text)
+	}
+	if err := m.checkBudget(promptTokens, false); err != nil {
+		return entity.EmbeddingResponse{}, err
+	}
+
+	response, err := Embed(ctx, m.inner, texts)
+	if err != nil {
+		return response, err
+	}
+	if response.PromptTokens > 0 {
+		promptTokens = response.PromptTokens
+	}
+	m.add(ctx, response.Model, promptTokens, 0)
+	return response, nil
+}
+
+// add 按阶段、文件、模型记录一次调用的用量，model 为空时使用 defaultModel 计价
+func (m *UsageMeter) add(ctx context.Context, model string, promptTokens, completionTokens int) {
+	if model == "" {
+		model = m.defaultModel
+	}
+	cost := m.prices.Cost(model, promptTokens, completionTokens)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.report.Total.Add(promptTokens, completionTokens, cost)
+	addUsage(m.report.ByModel, model, promptTokens, completionTokens, cost)
+	addUsage(m.report.ByStage, orUnknown(StageFromContext(ctx)), promptTokens, completionTokens, cost)
+	if file := FileFromContext(ctx); file != "" {
+		addUsage(m.report.ByFile, file, promptTokens, completionTokens, cost)
+	}
+}
+
+// checkBudget 按 defaultModel 估算本次调用的费用，超出预算时返回 ErrBudgetExceeded。
+// withCompletion 为 true 时回复的 token 数按已完成调用的平均值估算，还没有调用时按 prompt 的一半估算。
+func (m *UsageMeter) checkBudget(promptTokens int, withCompletion bool) error {
+	if m.budget <= 0 {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.report.BudgetExceeded {
+		return ErrBudgetExceeded
+	}
+
+	completionTokens := 0
+	if withCompletion {
+		completionTokens = promptTokens / 2
+		if m.report.Total.Calls > 0 {
+			completionTokens = m.report.Total.CompletionTokens / m.report.Total.Calls
+		}
+	}
+	estimate := m.prices.Cost(m.defaultModel, promptTokens, completionTokens)
+	if m.report.Total.Cost+estimate > m.budget {
+		m.report.BudgetExceeded = true
+		return fmt.Errorf("%w: spent %.4f, next call needs about %.4f, budget %.4f", ErrBudgetExceeded, m.report.Total.Cost, estimate, m.budget)
+	}
+	return nil
+}
+
+// Total 返回目前的总用量
+func (m *UsageMeter) Total() entity.TokenUsage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.report.Total
+}
+
+// Report 返回当前的用量报告
+func (m *UsageMeter) Report() entity.UsageReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	report := m.report
+	report.RunID = m.RunID
+	report.Commit = m.Commit
+	report.FinishedAt = time.Now()
+	report.ByStage = copyUsage(m.report.ByStage)
+	report.ByModel = copyUsage(m.report.ByModel)
+	report.ByFile = copyUsage(m.report.ByFile)
+	return report
+}
+
+// WriteReport 把用量报告写入 dir/run_report.json
+func (m *UsageMeter) WriteReport(dir string) (string, error) {
+	data, err := json.MarshalIndent(m.Report(), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode run report: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %v", err)
+	}
+	path := filepath.Join(dir, "run_report.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write run report: %v", err)
+	}
+	return path, nil
+}
+
+func addUsage(usages map[string]entity.TokenUsage, key string, promptTokens, completionTokens int, cost float64) {
+	usage := usages[key]
+	usage.Add(promptTokens, completionTokens, cost)
+	usages[key] = usage
+}
+
+func copyUsage(usages map[string]entity.TokenUsage) map[string]entity.TokenUsage {
+	out := make(map[string]entity.TokenUsage, len(usages))
+	for key, usage := range usages {
+		out[key] = usage
+	}
+	return out
+}
+
+func orUnknown(stage string) string {
+	if stage == "" {
+		return "unknown"
+	}
+	return stage
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+)
+
+// fixedUsageClient 每次返回固定 token 用量的 LLM 客户端
+type fixedUsageClient struct {
+	calls int
+}
+
+func (c *fixedUsageClient) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
+	c.calls++
+	return entity.LLMResponse{Content: "ok", Model: "gpt-4o-mini-2024-07-18", PromptTokens: 1000, CompletionTokens: 500}, nil
+}
+
+func TestUsageMeterAggregates(t *testing.T) {
+	meter := NewUsageMeter(&fixedUsageClient{}, DefaultPrices(), 0, "gpt-4o-mini")
+	ctx := WithFile(context.Background(), "a.go")
+	for _, stage := range []string{"file_analysis", "file_analysis", "yaml_repair"} {
+		if _, err := meter.GetResponse(WithStage(ctx, stage), "prompt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := meter.GetResponse(WithStage(context.Background(), "final_answer"), "prompt"); err != nil {
+		t.Fatal(err)
+	}
+
+	report := meter.Report()
+	if report.Total.Calls != 4 || report.Total.PromptTokens != 4000 || report.Total.CompletionTokens != 2000 {
+		t.Errorf("total = %+v", report.Total)
+	}
+	wantCost := 4 * (1000*0.15 + 500*0.60) / 1e6
+	if math.Abs(report.Total.Cost-wantCost) > 1e-12 {
+		t.Errorf("cost = %v, want %v", report.Total.Cost, wantCost)
+	}
+	if report.ByStage["file_analysis"].Calls != 2 || report.ByStage["yaml_repair"].Calls != 1 {
+		t.Errorf("by stage = %+v", report.ByStage)
+	}
+	if report.ByFile["a.go"].Calls != 3 || len(report.ByFile) != 1 {
+		t.Errorf("by file = %+v", report.ByFile)
+	}
+	if report.ByModel["gpt-4o-mini-2024-07-18"].Calls != 4 {
+		t.Errorf("by model = %+v", report.ByModel)
+	}
+}
+
+func TestUsageMeterStopsBeforeBudget(t *testing.T) {
+	inner := &fixedUsageClient{}
+	// 每次调用约 0.00045 美元，预算只够两次
+	meter := NewUsageMeter(inner, DefaultPrices(), 0.001, "gpt-4o-mini")
+
+	var err error
+	for i := 0; i < 5 && err == nil; i++ {
+		_, err = meter.GetResponse(context.Background(), "prompt")
+	}
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("inner client called %d times, want 2", inner.calls)
+	}
+	report := meter.Report()
+	if !report.BudgetExceeded || report.Total.Cost > 0.001 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens("abcdefgh"); got != 2 {
+		t.Errorf("EstimateTokens(ascii) = %d, want 2", got)
+	}
+	if got := EstimateTokens("代码分析"); got != 4 {
+		t.Errorf("EstimateTokens(cjk) = %d, want 4", got)
+	}
+}
+
+func TestEstimateCompletionTokens(t *testing.T) {
+	if got := EstimateCompletionTokens("package a"); got != 200 {
+		t.Errorf("EstimateCompletionTokens(small) = %d, want 200", got)
+	}
+	if got := EstimateCompletionTokens(strings.Repeat("abcd", 3000)); got != 1000 {
+		t.Errorf("EstimateCompletionTokens(large) = %d, want 1000", got)
+	}
+}
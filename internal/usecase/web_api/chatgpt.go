@@ -1,16 +1,25 @@
 package web_api
 
 import (
+	"codetest/internal/entity"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/sashabaranov/go-openai"
+	"net/http"
 	"os"
 )
 
+// DefaultChatGPTModel ChatGPTClient 默认使用的模型
+const DefaultChatGPTModel = openai.GPT4oMini
+
+// EmbeddingModel Embed 使用的向量模型
+const EmbeddingModel = string(openai.SmallEmbedding3)
+
 // ChatGPTClient 结构体封装 ChatGPT 客户端
 type ChatGPTClient struct {
-	client  *openai.Client
-	logFile *os.File
+	client *openai.Client
+	model  string
 }
 
 // NewChatGPTClient 创建新的 ChatGPTClient
@@ -21,31 +30,22 @@ func NewChatGPTClient(apiKey string) *ChatGPTClient {
 	cfg := openai.DefaultConfig(apiKey)
 	cfg.BaseURL = "https://api.chatanywhere.tech/v1"
 
-	logFile, err := os.OpenFile("log.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Println("Failed to open log file:", err)
-		return nil
-	}
-
 	return &ChatGPTClient{
-		client:  openai.NewClientWithConfig(cfg),
-		logFile: logFile,
+		client: openai.NewClientWithConfig(cfg),
+		model:  DefaultChatGPTModel,
 	}
 }
 
-// LogDetail 记录详细日志
-func (c *ChatGPTClient) LogDetail(text string) {
-	if _, err := c.logFile.WriteString(text + "\n"); err != nil {
-		fmt.Printf("Failed to write log: %v\n", err)
-	}
+// Model 返回请求使用的模型名称
+func (c *ChatGPTClient) Model() string {
+	return c.model
 }
 
-// GetResponse 调用 ChatGPT API 并返回回复
-func (c *ChatGPTClient) GetResponse(prompt string) (string, error) {
-	ctx := context.Background()
+// GetResponse 调用 ChatGPT API 并返回回复及 token 用量
+func (c *ChatGPTClient) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
 	req := openai.ChatCompletionRequest{
 		Temperature: 0,
-		Model:       openai.GPT4oMini,
+		Model:       c.model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleUser,
@@ -56,8 +56,54 @@ func (c *ChatGPTClient) GetResponse(prompt string) (string, error) {
 
 	resp, err := c.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("ChatGPT request failed: %v", err)
+		var apiErr *openai.APIError
+		if errors.As(err, &apiErr) && isAuthStatus(apiErr.HTTPStatusCode) {
+			return entity.LLMResponse{}, fmt.Errorf("ChatGPT request failed: %w: %v", entity.ErrUnauthorized, err)
+		}
+		return entity.LLMResponse{}, fmt.Errorf("ChatGPT request failed: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		return entity.LLMResponse{}, fmt.Errorf("ChatGPT returned no choices")
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = c.model
+	}
+	return entity.LLMResponse{
+		Content:          resp.Choices[0].Message.Content,
+		Model:            model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}, nil
+}
+
+// Embed 用 EmbeddingModel 计算每段文本的向量，返回的向量与 texts 一一对应，实现 usecase.Embedder
+func (c *ChatGPTClient) Embed(ctx context.Context, texts []string) (entity.EmbeddingResponse, error) {
+	resp, err := c.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{Input: texts, Model: openai.SmallEmbedding3})
+	if err != nil {
+		var apiErr *openai.APIError
+		if errors.As(err, &apiErr) && isAuthStatus(apiErr.HTTPStatusCode) {
+			return entity.EmbeddingResponse{}, fmt.Errorf("embedding request failed: %w: %v", entity.ErrUnauthorized, err)
+		}
+		return entity.EmbeddingResponse{}, fmt.Errorf("embedding request failed: %v", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return entity.EmbeddingResponse{}, fmt.Errorf("embedding response has an unexpected index %d", data.Index)
+		}
+		vectors[data.Index] = data.Embedding
 	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return entity.EmbeddingResponse{}, fmt.Errorf("embedding response is missing text %d", i)
+		}
+	}
+	return entity.EmbeddingResponse{Vectors: vectors, Model: EmbeddingModel, PromptTokens: resp.Usage.PromptTokens}, nil
+}
 
-	return resp.Choices[0].Message.Content, nil
+// isAuthStatus 判断 HTTP 状态码是否表示 token 无效或没有权限
+func isAuthStatus(code int) bool {
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
 }
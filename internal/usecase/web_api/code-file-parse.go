@@ -6,6 +6,7 @@ import (
 	"go/parser"
 	"go/token"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -17,6 +18,8 @@ type StructInfo struct {
 
 // ParseResult 解析结果
 type ParseResult struct {
+	PackageName  string
+	Imports      []string
 	Structs      map[string]*StructInfo
 	Interfaces   map[string][]string
 	Constants    []string
@@ -107,12 +110,19 @@ func (p *Parser) ParseByFile(filePath string) (*ParseResult, error) {
 		return nil, err
 	}
 	result := ParseResult{
+		PackageName:  f.Name.Name,
+		Imports:      []string{},
 		Structs:      make(map[string]*StructInfo),
 		Interfaces:   make(map[string][]string),
 		Constants:    []string{},
 		ExportedFunc: []string{},
 		ExportedVar:  []string{},
 	}
+	for _, spec := range f.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+			result.Imports = append(result.Imports, importPath)
+		}
+	}
 	// 遍历 AST 树
 	ast.Inspect(f, func(n ast.Node) bool {
 		switch t := n.(type) {
@@ -180,7 +190,7 @@ func parseFunc(t *ast.FuncDecl, structs map[string]*StructInfo, exportedFuncs *[
 
 		if t.Recv != nil {
 			// 解析方法的接收者
-			receiverType := exprToString(t.Recv.List[0].Type)
+			receiverType := strings.TrimPrefix(exprToString(t.Recv.List[0].Type), "*")
 			if structInfo, ok := structs[receiverType]; ok {
 				structInfo.Methods = append(structInfo.Methods, fmt.Sprintf("%s(%s) (%s)", t.Name.Name, params, results))
 			}
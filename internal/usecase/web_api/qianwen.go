@@ -2,6 +2,8 @@ package web_api
 
 import (
 	"bytes"
+	"codetest/internal/entity"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -23,8 +25,7 @@ type RequestBody struct {
 
 // QwenClient 封装 Qwen 客户端
 type QwenClient struct {
-	client  *http.Client
-	logFile *os.File
+	client *http.Client
 }
 
 // NewQwenClient 创建新的 QwenClient
@@ -33,27 +34,13 @@ func NewQwenClient(apiKey string) *QwenClient {
 		apiKey = os.Getenv("DASHSCOPE_API_KEY")
 	}
 
-	logFile, err := os.OpenFile("log.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Println("Failed to open log file:", err)
-		return nil
-	}
-
 	return &QwenClient{
-		client:  &http.Client{},
-		logFile: logFile,
+		client: &http.Client{},
 	}
 }
 
-// LogDetail 记录详细日志
-func (c *QwenClient) LogDetail(text string) {
-	if _, err := c.logFile.WriteString(text + "\n"); err != nil {
-		fmt.Printf("Failed to write log: %v\n", err)
-	}
-}
-
-// GetResponse 调用 Qwen API 并返回回复
-func (c *QwenClient) GetResponse(prompt string) (string, error) {
+// GetResponse 调用 Qwen API 并返回回复及 token 用量
+func (c *QwenClient) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
 	// 构建请求体
 	requestBody := RequestBody{
 		Model: "qwen-plus",
@@ -65,12 +52,12 @@ func (c *QwenClient) GetResponse(prompt string) (string, error) {
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request body: %v", err)
+		return entity.LLMResponse{}, fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://dashscope.aliyuncs.com/compatible-mode/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://dashscope.aliyuncs.com/compatible-mode/v1/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return entity.LLMResponse{}, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+os.Getenv("DASHSCOPE_API_KEY"))
@@ -78,30 +65,43 @@ func (c *QwenClient) GetResponse(prompt string) (string, error) {
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return entity.LLMResponse{}, fmt.Errorf("failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
 
 	bodyText, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
+		return entity.LLMResponse{}, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if isAuthStatus(resp.StatusCode) {
+		return entity.LLMResponse{}, fmt.Errorf("Qwen request failed: %w: %s", entity.ErrUnauthorized, bodyText)
 	}
 
 	var responseBody struct {
+		Model   string `json:"model"`
 		Choices []struct {
 			Message struct {
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
 	}
 
 	if err := json.Unmarshal(bodyText, &responseBody); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response body: %v", err)
+		return entity.LLMResponse{}, fmt.Errorf("failed to unmarshal response body: %v", err)
 	}
 
 	if len(responseBody.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+		return entity.LLMResponse{}, fmt.Errorf("no choices in response")
 	}
 
-	return responseBody.Choices[0].Message.Content, nil
+	return entity.LLMResponse{
+		Content:          responseBody.Choices[0].Message.Content,
+		Model:            responseBody.Model,
+		PromptTokens:     responseBody.Usage.PromptTokens,
+		CompletionTokens: responseBody.Usage.CompletionTokens,
+	}, nil
 }
@@ -3,11 +3,23 @@ package workflow_server
 import (
 	"codetest/internal/entity"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-resty/resty/v2"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+// tokenRefreshSkew token 过期前多久主动重新登录
+const tokenRefreshSkew = 30 * time.Second
+
+// ErrProjectNotFound 没有找到项目
+var ErrProjectNotFound = errors.New("project not found")
+
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -15,9 +27,11 @@ type LoginRequest struct {
 
 type LoginResponse struct {
 	Data struct {
-		Token    string `json:"token"`
-		UserID   int    `json:"user_id"`
-		Username string `json:"username"`
+		Token     string    `json:"token"`
+		UserID    int       `json:"user_id"`
+		Username  string    `json:"username"`
+		ExpiresIn int       `json:"expires_in"` // token 有效期（秒），可选
+		ExpiresAt time.Time `json:"expires_at"` // token 过期时间，可选
 	} `json:"data"`
 }
 
@@ -45,30 +59,54 @@ type Project struct {
 	LanguageVersion string    `gorm:"type:varchar(32);default:NULL" json:"language_version"` // 语言版本
 	UserID          uint      `gorm:"default:NULL" json:"user_id"`                           // 用户 ID (可以为 NULL
 	Status          string    `json:"status"`                                                // 状态
+	CommitSHA       string    `gorm:"type:varchar(64);default:NULL" json:"commit_sha"`       // 最近一次分析的提交
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
-// ApiClient 封装 API 客户端
+// ApiClient 封装 API 客户端，请求路径相对于 apiBasePath + "/api/v1/"。
+// 使用用户名密码时在 token 过期前或收到 401 后自动重新登录，并发请求只会触发一次登录；
+// 使用 API key 时直接把 key 作为 Bearer token，不需要登录。
 type ApiClient struct {
-	client      *resty.Client
-	apiBasePath string
-	token       string
-	username    string
-	password    string
+	client   *resty.Client
+	username string
+	password string
+	apiKey   string
+
+	mutex     sync.Mutex // 保护 token 和 expiresAt，登录期间一直持有
+	token     string
+	expiresAt time.Time // 为零表示未知
 }
 
 // NewApiClient 创建一个新的 ApiClient
 func NewApiClient(apiBasePath, username, password string) *ApiClient {
 	return &ApiClient{
-		client: resty.New().SetBaseURL(apiBasePath + "/api/v1/"),
-		//apiBasePath: apiBasePath + "/api/v1/",
+		client:   resty.New().SetBaseURL(apiBasePath + "/api/v1/"),
 		username: username,
 		password: password,
 	}
 }
 
+// NewApiClientWithAPIKey 创建使用 API key 认证的 ApiClient
+func NewApiClientWithAPIKey(apiBasePath, apiKey string) *ApiClient {
+	return &ApiClient{
+		client: resty.New().SetBaseURL(apiBasePath + "/api/v1/"),
+		apiKey: apiKey,
+	}
+}
+
+// Login 使用用户名密码登录并保存 token，使用 API key 时直接返回 key
 func (a *ApiClient) Login(ctx context.Context) (string, error) {
+	if a.apiKey != "" {
+		return a.apiKey, nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.login(ctx)
+}
+
+// login 调用登录接口，调用方需要持有 mutex
+func (a *ApiClient) login(ctx context.Context) (string, error) {
 	var response LoginResponse
 	resp, err := a.client.R().
 		SetContext(ctx).
@@ -76,24 +114,100 @@ func (a *ApiClient) Login(ctx context.Context) (string, error) {
 		SetResult(&response).
 		Post("/auth/login")
 	if err != nil {
-		return "", fmt.Errorf("failed to send login request: %v", err)
+		return "", fmt.Errorf("failed to send login request: %w", err)
+	}
+	if isAuthError(resp) {
+		return "", fmt.Errorf("login failed: %w: %s", entity.ErrUnauthorized, resp.String())
 	}
 	if resp.IsError() {
 		return "", fmt.Errorf("login failed: %s", resp.String())
 	}
 
 	a.token = response.Data.Token
+	a.expiresAt = tokenExpiry(response, time.Now())
 	return response.Data.Token, nil
 }
 
+// currentToken 返回可用的 token，没有 token 或即将过期时先登录
+func (a *ApiClient) currentToken(ctx context.Context) (string, error) {
+	if a.apiKey != "" {
+		return a.apiKey, nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.token != "" && (a.expiresAt.IsZero() || time.Until(a.expiresAt) > tokenRefreshSkew) {
+		return a.token, nil
+	}
+	return a.login(ctx)
+}
+
+// refreshToken 在 staleToken 被服务端拒绝后重新登录。
+// 其他请求已经刷新过 token 时直接返回新的 token，保证并发的 401 只触发一次登录。
+func (a *ApiClient) refreshToken(ctx context.Context, staleToken string) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.token != "" && a.token != staleToken {
+		return a.token, nil
+	}
+	return a.login(ctx)
+}
+
+// do 带上 token 发送请求，收到 401 时重新登录并重试一次，仍然被拒绝时返回 entity.ErrUnauthorized
+func (a *ApiClient) do(ctx context.Context, send func(req *resty.Request) (*resty.Response, error)) (*resty.Response, error) {
+	token, err := a.currentToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := send(a.client.R().SetContext(ctx).SetAuthToken(token))
+	if err == nil && resp.StatusCode() == http.StatusUnauthorized && a.apiKey == "" {
+		if token, err = a.refreshToken(ctx, token); err != nil {
+			return nil, err
+		}
+		resp, err = send(a.client.R().SetContext(ctx).SetAuthToken(token))
+	}
+	if err == nil && isAuthError(resp) {
+		return resp, fmt.Errorf("%w: %s", entity.ErrUnauthorized, resp.String())
+	}
+	return resp, err
+}
+
+// isAuthError 判断服务端是否拒绝了认证信息
+func isAuthError(resp *resty.Response) bool {
+	return resp.StatusCode() == http.StatusUnauthorized || resp.StatusCode() == http.StatusForbidden
+}
+
+// tokenExpiry 依次从 expires_in、expires_at 和 JWT 的 exp 中得到 token 的过期时间，都没有时返回零值
+func tokenExpiry(response LoginResponse, now time.Time) time.Time {
+	if response.Data.ExpiresIn > 0 {
+		return now.Add(time.Duration(response.Data.ExpiresIn) * time.Second)
+	}
+	if !response.Data.ExpiresAt.IsZero() {
+		return response.Data.ExpiresAt
+	}
+
+	parts := strings.Split(response.Data.Token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
 func (a *ApiClient) UploadCodeInfo(ctx context.Context, data entity.AICodeSnippet) (string, error) {
-	resp, err := a.client.R().
-		SetContext(ctx).
-		SetAuthToken(a.token).
-		SetBody(data).
-		Post("/codes")
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.SetBody(data).Post("/codes")
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to send upload request: %v", err)
+		return "", fmt.Errorf("failed to send upload request: %w", err)
 	}
 	if resp.IsError() {
 		return "", fmt.Errorf("upload failed: %s", resp.String())
@@ -102,35 +216,224 @@ func (a *ApiClient) UploadCodeInfo(ctx context.Context, data entity.AICodeSnippe
 	return resp.String(), nil
 }
 
+// BatchUpsertRequest 批量上传代码片段的请求，服务端按 project_name 和 file_path 更新或插入，每个文件只保留最新的片段。
+// content_hash 随片段保存，Sink 用它和记录模型、提示词版本的标签判断是否需要重新上传
+type BatchUpsertRequest struct {
+	Items []entity.AICodeSnippet `json:"items"`
+}
+
+// UpsertCodes 批量上传代码片段
+func (a *ApiClient) UpsertCodes(ctx context.Context, snippets []entity.AICodeSnippet) error {
+	response := Response{}
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.SetBody(BatchUpsertRequest{Items: snippets}).SetResult(&response).Post("/codes/batch")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send batch upload request: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("batch upload failed: %s", resp.String())
+	}
+	return nil
+}
+
+// ListCodes 列出项目下已上传的代码片段
+func (a *ApiClient) ListCodes(ctx context.Context, projectName string) ([]entity.AICodeSnippet, error) {
+	var response struct {
+		Data []entity.AICodeSnippet `json:"data"`
+	}
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.SetQueryParam("project_name", projectName).SetResult(&response).Get("/codes")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send list request: %w", err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to list codes: %s", resp.String())
+	}
+	return response.Data, nil
+}
+
+// GetCode 获取代码片段详情
+func (a *ApiClient) GetCode(ctx context.Context, id int) (*entity.AICodeSnippet, error) {
+	var response struct {
+		Data entity.AICodeSnippet `json:"data"`
+	}
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.SetResult(&response).Get(fmt.Sprintf("/codes/%d", id))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send get request: %w", err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to fetch code %d: %s", id, resp.String())
+	}
+	return &response.Data, nil
+}
+
+// DeleteCode 删除代码片段
+func (a *ApiClient) DeleteCode(ctx context.Context, id int) error {
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.Delete(fmt.Sprintf("/codes/%d", id))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send delete request: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("failed to delete code %d: %s", id, resp.String())
+	}
+	return nil
+}
+
+// GetProjectByID 获取项目详情，项目不存在时返回 ErrProjectNotFound
 func (a *ApiClient) GetProjectByID(ctx context.Context, projectID uint) (*Project, error) {
-	url := fmt.Sprintf("%s/projects/%d", a.apiBasePath, projectID)
+	url := fmt.Sprintf("/projects/%d", projectID)
 	var response struct {
 		Data    Project `json:"data"`
 		Message string  `json:"message"`
 	}
-	resp, err := a.client.R().
-		SetContext(ctx).
-		SetAuthToken(a.token).
-		SetResult(&response).
-		Get(url)
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.SetResult(&response).Get(url)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: id %d", ErrProjectNotFound, projectID)
+	}
 	if resp.IsError() {
 		return nil, fmt.Errorf("failed to fetch project: %s", resp.String())
 	}
 	return &response.Data, nil
 }
 
+// ListProjects 列出当前用户可见的项目，name 不为空时按名称过滤
+func (a *ApiClient) ListProjects(ctx context.Context, name string) ([]Project, error) {
+	var response struct {
+		Data []Project `json:"data"`
+	}
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		if name != "" {
+			req.SetQueryParam("name", name)
+		}
+		return req.SetResult(&response).Get("/projects")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send list request: %w", err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to list projects: %s", resp.String())
+	}
+	return response.Data, nil
+}
+
+// FindProjectByName 按名称精确查找项目，不存在时返回 ErrProjectNotFound
+func (a *ApiClient) FindProjectByName(ctx context.Context, name string) (*Project, error) {
+	projects, err := a.ListProjects(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range projects {
+		if projects[i].Name == name {
+			return &projects[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+}
+
+// CreateProject 创建项目并返回服务端保存的项目
+func (a *ApiClient) CreateProject(ctx context.Context, project *Project) (*Project, error) {
+	var response struct {
+		Data    Project `json:"data"`
+		Message string  `json:"message"`
+	}
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.SetBody(project).SetResult(&response).Post("/projects")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send create request: %w", err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to create project: %s", resp.String())
+	}
+	return &response.Data, nil
+}
+
+// DeleteProject 删除项目
+func (a *ApiClient) DeleteProject(ctx context.Context, projectID uint) error {
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.Delete(fmt.Sprintf("/projects/%d", projectID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send delete request: %w", err)
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return fmt.Errorf("%w: id %d", ErrProjectNotFound, projectID)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("failed to delete project: %s", resp.String())
+	}
+	return nil
+}
+
+// AddProjectTags 给项目添加标签，已有的标签不会重复添加
+func (a *ApiClient) AddProjectTags(ctx context.Context, projectID uint, tags ...string) (*Project, error) {
+	return a.updateProjectTags(ctx, projectID, func(current []string) []string {
+		return MergeTags(current, tags)
+	})
+}
+
+// RemoveProjectTags 删除项目的标签
+func (a *ApiClient) RemoveProjectTags(ctx context.Context, projectID uint, tags ...string) (*Project, error) {
+	remove := map[string]bool{}
+	for _, tag := range tags {
+		remove[tag] = true
+	}
+	return a.updateProjectTags(ctx, projectID, func(current []string) []string {
+		kept := []string{}
+		for _, tag := range current {
+			if !remove[tag] {
+				kept = append(kept, tag)
+			}
+		}
+		return kept
+	})
+}
+
+// updateProjectTags 读取项目，用 update 修改标签后保存
+func (a *ApiClient) updateProjectTags(ctx context.Context, projectID uint, update func([]string) []string) (*Project, error) {
+	project, err := a.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	project.Tags = update(project.Tags)
+	if err := a.UpdateProject(ctx, project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// MergeTags 把 extra 中不存在于 tags 的标签追加到末尾
+func MergeTags(tags, extra []string) []string {
+	seen := map[string]bool{}
+	merged := []string{}
+	for _, tag := range append(append([]string{}, tags...), extra...) {
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		merged = append(merged, tag)
+	}
+	return merged
+}
+
+// UpdateProject 更新项目
 func (a *ApiClient) UpdateProject(ctx context.Context, project *Project) error {
 	url := fmt.Sprintf("/projects/%d", project.ID)
 	response := Response{}
-	resp, err := a.client.R().
-		SetContext(ctx).
-		SetAuthToken(a.token).
-		SetBody(project).
-		SetResult(&response).
-		Put(url)
+	resp, err := a.do(ctx, func(req *resty.Request) (*resty.Response, error) {
+		return req.SetBody(project).SetResult(&response).Put(url)
+	})
 	if err != nil {
 		return err
 	}
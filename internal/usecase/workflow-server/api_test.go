@@ -0,0 +1,369 @@
+package workflow_server
+
+import (
+	"codetest/internal/entity"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServer 模拟 workflow server：登录时签发新 token，expire 后之前签发的 token 都返回 401
+type fakeServer struct {
+	mutex     sync.Mutex
+	valid     map[string]bool
+	logins    int32
+	uploads   int32
+	expiresIn int
+
+	codes       map[int]entity.AICodeSnippet // 已上传的代码片段
+	nextID      int
+	batches     int
+	failUploads bool
+
+	projects map[uint]Project
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	fs := &fakeServer{
+		valid:    map[string]bool{"api-key": true},
+		codes:    map[int]entity.AICodeSnippet{},
+		projects: map[uint]Project{1: {ID: 1, Name: "demo"}},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// 放慢登录，让并发的 401 有机会同时等待登录
+		time.Sleep(20 * time.Millisecond)
+		n := atomic.AddInt32(&fs.logins, 1)
+		token := fmt.Sprintf("token-%d", n)
+		fs.mutex.Lock()
+		fs.valid[token] = true
+		fs.mutex.Unlock()
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"token": token, "expires_in": fs.expiresIn}})
+	})
+	mux.HandleFunc("/api/v1/codes", func(w http.ResponseWriter, r *http.Request) {
+		if !fs.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			fs.mutex.Lock()
+			defer fs.mutex.Unlock()
+			var list []entity.AICodeSnippet
+			for _, snippet := range fs.codes {
+				if snippet.ProjectName == r.URL.Query().Get("project_name") {
+					list = append(list, snippet)
+				}
+			}
+			writeJSON(w, map[string]interface{}{"data": list})
+			return
+		}
+		atomic.AddInt32(&fs.uploads, 1)
+		writeJSON(w, map[string]interface{}{"code": 0})
+	})
+	mux.HandleFunc("/api/v1/codes/batch", func(w http.ResponseWriter, r *http.Request) {
+		if !fs.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		if fs.failUploads {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req BatchUpsertRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fs.batches++
+		for _, item := range req.Items {
+			item.ID = 0
+			for id, snippet := range fs.codes {
+				if snippet.ProjectName == item.ProjectName && snippet.FilePath == item.FilePath {
+					item.ID = id
+				}
+			}
+			if item.ID == 0 {
+				fs.nextID++
+				item.ID = fs.nextID
+			}
+			fs.codes[item.ID] = item
+		}
+		writeJSON(w, map[string]interface{}{"code": 0})
+	})
+	mux.HandleFunc("/api/v1/codes/", func(w http.ResponseWriter, r *http.Request) {
+		if !fs.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var id int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/api/v1/codes/"), "%d", &id)
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		if _, ok := fs.codes[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			writeJSON(w, map[string]interface{}{"data": fs.codes[id]})
+			return
+		}
+		delete(fs.codes, id)
+		writeJSON(w, map[string]interface{}{"code": 0})
+	})
+	mux.HandleFunc("/api/v1/projects", func(w http.ResponseWriter, r *http.Request) {
+		if !fs.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		if r.Method == http.MethodPost {
+			var project Project
+			if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			project.ID = uint(len(fs.projects) + 1)
+			fs.projects[project.ID] = project
+			writeJSON(w, map[string]interface{}{"data": project})
+			return
+		}
+		// 与真实服务一样按名称模糊匹配
+		var list []Project
+		for _, project := range fs.projects {
+			if strings.Contains(project.Name, r.URL.Query().Get("name")) {
+				list = append(list, project)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"data": list})
+	})
+	mux.HandleFunc("/api/v1/projects/", func(w http.ResponseWriter, r *http.Request) {
+		if !fs.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var id uint
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/api/v1/projects/"), "%d", &id)
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		if _, ok := fs.projects[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPut {
+			var project Project
+			if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fs.projects[id] = project
+			writeJSON(w, map[string]interface{}{"code": 0})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(fs.projects, id)
+			writeJSON(w, map[string]interface{}{"code": 0})
+			return
+		}
+		writeJSON(w, map[string]interface{}{"data": fs.projects[id]})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return fs, server
+}
+
+func (fs *fakeServer) authorized(r *http.Request) bool {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.valid[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+}
+
+// expire 让所有通过登录签发的 token 失效
+func (fs *fakeServer) expire() {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	for token := range fs.valid {
+		if token != "api-key" {
+			delete(fs.valid, token)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestApiClientReloginOnExpiredToken(t *testing.T) {
+	fs, server := newFakeServer(t)
+	client := NewApiClient(server.URL, "admin", "secret")
+	ctx := context.Background()
+
+	if _, err := client.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, err := client.UploadCodeInfo(ctx, entity.AICodeSnippet{FilePath: "a.go"}); err != nil {
+		t.Fatalf("upload before expiry: %v", err)
+	}
+
+	fs.expire()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := client.UploadCodeInfo(ctx, entity.AICodeSnippet{FilePath: fmt.Sprintf("%d.go", i)}); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("upload after expiry: %v", err)
+	}
+
+	if logins := atomic.LoadInt32(&fs.logins); logins != 2 {
+		t.Errorf("logins = %d, want 2 (one initial login and one shared re-login)", logins)
+	}
+	if uploads := atomic.LoadInt32(&fs.uploads); uploads != 9 {
+		t.Errorf("uploads = %d, want 9", uploads)
+	}
+	if _, err := client.GetProjectByID(ctx, 1); err != nil {
+		t.Errorf("GetProjectByID: %v", err)
+	}
+}
+
+func TestApiClientRefreshesBeforeExpiry(t *testing.T) {
+	fs, server := newFakeServer(t)
+	fs.expiresIn = 10 // 小于 tokenRefreshSkew，每次请求前都会重新登录
+	client := NewApiClient(server.URL, "admin", "secret")
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.UploadCodeInfo(ctx, entity.AICodeSnippet{}); err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+	}
+	if logins := atomic.LoadInt32(&fs.logins); logins != 2 {
+		t.Errorf("logins = %d, want 2", logins)
+	}
+}
+
+func TestApiClientWithAPIKey(t *testing.T) {
+	fs, server := newFakeServer(t)
+	client := NewApiClientWithAPIKey(server.URL, "api-key")
+	if _, err := client.UploadCodeInfo(context.Background(), entity.AICodeSnippet{}); err != nil {
+		t.Fatalf("upload with api key: %v", err)
+	}
+	if logins := atomic.LoadInt32(&fs.logins); logins != 0 {
+		t.Errorf("logins = %d, want 0", logins)
+	}
+
+	bad := NewApiClientWithAPIKey(server.URL, "wrong")
+	if _, err := bad.UploadCodeInfo(context.Background(), entity.AICodeSnippet{}); !errors.Is(err, entity.ErrUnauthorized) {
+		t.Errorf("err = %v, want ErrUnauthorized for an invalid api key", err)
+	}
+	if _, err := NewApiClient(server.URL, "admin", "wrong").Login(context.Background()); !errors.Is(err, entity.ErrUnauthorized) {
+		t.Errorf("err = %v, want ErrUnauthorized for a wrong password", err)
+	}
+}
+
+func TestTokenExpiryFromJWT(t *testing.T) {
+	exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	var response LoginResponse
+	response.Data.Token = "header." + payload + ".signature"
+
+	if got := tokenExpiry(response, time.Now()); !got.Equal(exp) {
+		t.Errorf("tokenExpiry = %v, want %v", got, exp)
+	}
+	response.Data.Token = "opaque"
+	if got := tokenExpiry(response, time.Now()); !got.IsZero() {
+		t.Errorf("tokenExpiry(opaque) = %v, want zero", got)
+	}
+}
+
+func TestApiClientProjects(t *testing.T) {
+	fs, server := newFakeServer(t)
+	client := NewApiClientWithAPIKey(server.URL, "api-key")
+	ctx := context.Background()
+
+	if _, err := client.FindProjectByName(ctx, "dem"); !errors.Is(err, ErrProjectNotFound) {
+		t.Fatalf("FindProjectByName(partial name) err = %v, want ErrProjectNotFound", err)
+	}
+	created, err := client.CreateProject(ctx, &Project{Name: "demo-api", Language: "go", Tags: []string{"lang:go"}})
+	if err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	found, err := client.FindProjectByName(ctx, "demo-api")
+	if err != nil || found.ID != created.ID {
+		t.Fatalf("FindProjectByName = %+v, %v, want id %d", found, err, created.ID)
+	}
+	if projects, err := client.ListProjects(ctx, ""); err != nil || len(projects) != 2 {
+		t.Errorf("ListProjects = %d projects, err %v, want 2", len(projects), err)
+	}
+
+	if _, err := client.AddProjectTags(ctx, created.ID, "lang:go", "team:infra"); err != nil {
+		t.Fatalf("AddProjectTags: %v", err)
+	}
+	project, err := client.RemoveProjectTags(ctx, created.ID, "lang:go")
+	if err != nil {
+		t.Fatalf("RemoveProjectTags: %v", err)
+	}
+	if strings.Join(project.Tags, ",") != "team:infra" || strings.Join(fs.projects[created.ID].Tags, ",") != "team:infra" {
+		t.Errorf("tags = %v, server tags = %v, want [team:infra]", project.Tags, fs.projects[created.ID].Tags)
+	}
+
+	if _, err := client.GetProjectByID(ctx, 99); !errors.Is(err, ErrProjectNotFound) {
+		t.Errorf("GetProjectByID(99) err = %v, want ErrProjectNotFound", err)
+	}
+
+	if err := client.DeleteProject(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteProject: %v", err)
+	}
+	if _, err := client.FindProjectByName(ctx, "demo-api"); !errors.Is(err, ErrProjectNotFound) {
+		t.Errorf("project still exists after delete, err = %v", err)
+	}
+}
+
+func TestApiClientCodes(t *testing.T) {
+	_, server := newFakeServer(t)
+	client := NewApiClientWithAPIKey(server.URL, "api-key")
+	ctx := context.Background()
+
+	if err := client.UpsertCodes(ctx, []entity.AICodeSnippet{{ProjectName: "demo", FilePath: "a.go", Desc: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	codes, err := client.ListCodes(ctx, "demo")
+	if err != nil || len(codes) != 1 {
+		t.Fatalf("ListCodes = %+v, %v", codes, err)
+	}
+	code, err := client.GetCode(ctx, codes[0].ID)
+	if err != nil || code.FilePath != "a.go" {
+		t.Fatalf("GetCode = %+v, %v", code, err)
+	}
+	if err := client.DeleteCode(ctx, code.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetCode(ctx, code.ID); err == nil {
+		t.Error("GetCode after delete should fail")
+	}
+}
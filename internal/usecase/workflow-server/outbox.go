@@ -0,0 +1,168 @@
+package workflow_server
+
+import (
+	"bufio"
+	"codetest/internal/entity"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Outbox 保存上传失败的代码片段（JSON Lines），由 sync 命令重新上传
+type Outbox struct {
+	file  string
+	mutex sync.Mutex
+}
+
+// NewOutbox 创建新的 Outbox
+func NewOutbox(file string) *Outbox {
+	return &Outbox{file: file}
+}
+
+// File 返回 outbox 文件路径
+func (o *Outbox) File() string {
+	return o.file
+}
+
+// Add 把代码片段追加到 outbox
+func (o *Outbox) Add(snippets []entity.AICodeSnippet) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(o.file), 0755); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %v", err)
+	}
+	file, err := os.OpenFile(o.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %v", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, snippet := range snippets {
+		if err := encoder.Encode(snippet); err != nil {
+			return fmt.Errorf("failed to write outbox: %v", err)
+		}
+	}
+	return nil
+}
+
+// Load 读取 outbox 中的代码片段，同一个项目的同一个文件只保留最后一条；outbox 不存在时返回空
+func (o *Outbox) Load() ([]entity.AICodeSnippet, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	file, err := os.Open(o.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox: %v", err)
+	}
+	defer file.Close()
+
+	var snippets []entity.AICodeSnippet
+	index := map[string]int{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var snippet entity.AICodeSnippet
+		if err := json.Unmarshal(scanner.Bytes(), &snippet); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox: %v", err)
+		}
+		key := snippet.ProjectName + "\x00" + snippet.FilePath
+		if i, ok := index[key]; ok {
+			snippets[i] = snippet
+			continue
+		}
+		index[key] = len(snippets)
+		snippets = append(snippets, snippet)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %v", err)
+	}
+	return snippets, nil
+}
+
+// Replay 重新上传 outbox 中的代码片段，上传失败的片段留在 outbox 中。
+// 返回成功上传的数量。
+func (o *Outbox) Replay(ctx context.Context, client *ApiClient, batchSize int) (int, error) {
+	snippets, err := o.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var failed []entity.AICodeSnippet
+	var errs []error
+	for _, batch := range batches(snippets, batchSize) {
+		if err := client.UpsertCodes(ctx, batch); err != nil {
+			failed = append(failed, batch...)
+			errs = append(errs, err)
+			continue
+		}
+		sent += len(batch)
+	}
+
+	if err := o.replace(failed); err != nil {
+		return sent, err
+	}
+	return sent, errors.Join(errs...)
+}
+
+// replace 用 snippets 覆盖 outbox，为空时删除 outbox 文件
+func (o *Outbox) replace(snippets []entity.AICodeSnippet) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if len(snippets) == 0 {
+		if err := os.Remove(o.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove outbox: %v", err)
+		}
+		return nil
+	}
+
+	tmp := o.file + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox: %v", err)
+	}
+	encoder := json.NewEncoder(file)
+	for _, snippet := range snippets {
+		if err := encoder.Encode(snippet); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write outbox: %v", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write outbox: %v", err)
+	}
+	if err := os.Rename(tmp, o.file); err != nil {
+		return fmt.Errorf("failed to replace outbox: %v", err)
+	}
+	return nil
+}
+
+// batches 把代码片段按 size 分批
+func batches(snippets []entity.AICodeSnippet, size int) [][]entity.AICodeSnippet {
+	if size <= 0 {
+		size = len(snippets)
+	}
+	var out [][]entity.AICodeSnippet
+	for len(snippets) > 0 {
+		n := size
+		if n > len(snippets) {
+			n = len(snippets)
+		}
+		out = append(out, snippets[:n])
+		snippets = snippets[n:]
+	}
+	return out
+}
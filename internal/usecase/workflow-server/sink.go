@@ -0,0 +1,212 @@
+package workflow_server
+
+import (
+	"codetest/internal/entity"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// UploadBatchSize 每次批量上传的代码片段数量
+const UploadBatchSize = 20
+
+// Sink 把分析结果上传到 workflow server：代码片段按批上传，内容和分析元数据都未变化的文件不会重复上传，
+// 结束时删除已经不存在的文件对应的片段并更新项目描述。上传失败的片段写入 outbox。
+type Sink struct {
+	client  *ApiClient
+	outbox  *Outbox
+	project Project
+
+	mutex    sync.Mutex
+	pending  []entity.AICodeSnippet
+	seen     map[string]bool                 // 本次运行处理过的文件
+	existing map[string]entity.AICodeSnippet // 服务端已有的片段，按文件路径索引；为 nil 表示还没有读取
+	listErr  error
+	queued   int // 本次运行写入 outbox 的数量
+}
+
+// NewSink 创建新的 Sink，project 需要包含 ID、Name、Language 和 LanguageVersion，CommitSHA 不为空时在 Close 时写入项目
+func NewSink(client *ApiClient, outbox *Outbox, project Project) *Sink {
+	return &Sink{
+		client:  client,
+		outbox:  outbox,
+		project: project,
+		seen:    map[string]bool{},
+	}
+}
+
+// Name 实现 usecase.Sink
+func (s *Sink) Name() string {
+	return "workflow-server"
+}
+
+// SaveFileResult 把代码片段加入待上传队列，内容和分析使用的模型、提示词版本、输出语言都未变化时跳过，队列满一批时上传
+func (s *Sink) SaveFileResult(ctx context.Context, result entity.FileResult) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.seen[result.Path] = true
+	hash := entity.ContentHash(result.Code)
+	metaTags := resultMetaTags(result.Meta)
+	if old, ok := s.existingSnippets(ctx)[result.Path]; ok && old.ContentHash == hash && hasTags(old.Tags, metaTags) {
+		return nil
+	}
+
+	s.pending = append(s.pending, entity.AICodeSnippet{
+		ProjectName:     s.project.Name,
+		FilePath:        result.Path,
+		FileName:        filepath.Base(result.Path),
+		FileType:        filepath.Ext(result.Path),
+		CodeRaw:         result.Code,
+		ContentHash:     hash,
+		Desc:            result.Parsed.FileDescription,
+		Snippet:         result.Raw,
+		Language:        s.project.Language,
+		LanguageVersion: s.project.LanguageVersion,
+		Tags: append([]string{
+			"lang:" + s.project.Language,
+			"langVersion:" + s.project.LanguageVersion,
+			"project:" + s.project.Name,
+		}, metaTags...),
+	})
+	if len(s.pending) < UploadBatchSize {
+		return nil
+	}
+	return s.flush(ctx)
+}
+
+// resultMetaTags 把分析结果的元数据记录为片段的标签，服务端没有单独的字段保存它们
+func resultMetaTags(meta entity.ResultMeta) []string {
+	return []string{
+		"model:" + meta.Model,
+		"promptVersion:" + meta.PromptVersion,
+		"outputLanguage:" + meta.OutputLanguage,
+	}
+}
+
+// hasTags 判断 tags 是否包含 want 中的所有标签
+func hasTags(tags, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush 实现 usecase.Flusher，立即上传队列中的片段，失败时写入 outbox
+func (s *Sink) Flush(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.flush(ctx)
+}
+
+// RemoveFileResult 实现 usecase.Remover，删除服务端中文件的片段
+func (s *Sink) RemoveFileResult(ctx context.Context, path string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snippet, ok := s.existingSnippets(ctx)[path]
+	if !ok {
+		return nil
+	}
+	if err := s.client.DeleteCode(ctx, snippet.ID); err != nil {
+		return err
+	}
+	delete(s.existing, path)
+	return nil
+}
+
+// Close 上传剩余的片段，删除已经不存在的文件的片段，并用简洁的项目描述更新项目
+func (s *Sink) Close(ctx context.Context, summary entity.ProjectSummary) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var errs []error
+	if err := s.flush(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.deleteMissing(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	project, err := s.client.GetProjectByID(ctx, s.project.ID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to get project details: %v", err))
+	} else {
+		project.Desc = summary.Description
+		if s.project.CommitSHA != "" {
+			project.CommitSHA = s.project.CommitSHA
+		}
+		if err := s.client.UpdateProject(ctx, project); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update project: %v", err))
+		}
+	}
+
+	if s.queued > 0 {
+		errs = append(errs, fmt.Errorf("%d snippets could not be uploaded and were saved to %s, run the sync command to retry", s.queued, s.outbox.File()))
+	}
+	return errors.Join(errs...)
+}
+
+// existingSnippets 第一次调用时读取服务端已有的片段，读取失败时不做去重
+func (s *Sink) existingSnippets(ctx context.Context) map[string]entity.AICodeSnippet {
+	if s.existing != nil {
+		return s.existing
+	}
+	s.existing = map[string]entity.AICodeSnippet{}
+	snippets, err := s.client.ListCodes(ctx, s.project.Name)
+	if err != nil {
+		s.listErr = err
+		slog.WarnContext(ctx, "Failed to list uploaded snippets, uploading all files", "error", err)
+		return s.existing
+	}
+	for _, snippet := range snippets {
+		s.existing[snippet.FilePath] = snippet
+	}
+	return s.existing
+}
+
+// flush 上传待上传队列，失败时写入 outbox
+func (s *Sink) flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	batch := s.pending
+	s.pending = nil
+
+	if err := s.client.UpsertCodes(ctx, batch); err != nil {
+		slog.WarnContext(ctx, "Failed to upload snippets, saving them to the outbox", "count", len(batch), "error", err)
+		if err := s.outbox.Add(batch); err != nil {
+			return err
+		}
+		s.queued += len(batch)
+	}
+	return nil
+}
+
+// deleteMissing 删除服务端存在、本次运行没有处理到并且本地已经不存在的文件的片段。
+// 分析失败的文件仍然存在，不会被删除。
+func (s *Sink) deleteMissing(ctx context.Context) error {
+	existing := s.existingSnippets(ctx)
+	if s.listErr != nil {
+		return nil
+	}
+	var errs []error
+	for path, snippet := range existing {
+		if s.seen[path] {
+			continue
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := s.client.DeleteCode(ctx, snippet.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,167 @@
+package workflow_server
+
+import (
+	"codetest/internal/entity"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// analyzeFiles 模拟一次 analyze：把 files 中的文件依次交给 sink
+func analyzeFiles(t *testing.T, sink *Sink, files map[string]string) error {
+	t.Helper()
+	return analyzeFilesWith(t, sink, files, entity.ResultMeta{Model: "gpt-4o-mini", PromptVersion: "v1", OutputLanguage: "zh"})
+}
+
+// analyzeFilesWith 与 analyzeFiles 相同，结果使用 meta 作为元数据
+func analyzeFilesWith(t *testing.T, sink *Sink, files map[string]string, meta entity.ResultMeta) error {
+	t.Helper()
+	ctx := context.Background()
+	for path, code := range files {
+		result := entity.FileResult{ProjectName: "demo", Path: path, Code: code, Raw: "file_description: " + path, Meta: meta}
+		if err := sink.SaveFileResult(ctx, result); err != nil {
+			t.Fatalf("SaveFileResult(%s): %v", path, err)
+		}
+	}
+	return sink.Close(ctx, entity.ProjectSummary{Description: "summary"})
+}
+
+func TestSinkUpsertsChangedFilesAndDeletesRemoved(t *testing.T) {
+	fs, server := newFakeServer(t)
+	client := NewApiClientWithAPIKey(server.URL, "api-key")
+	dir := t.TempDir()
+	project := Project{ID: 1, Name: "demo", Language: "go"}
+	outbox := NewOutbox(filepath.Join(dir, "outbox.jsonl"))
+
+	a, b, c := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go"), filepath.Join(dir, "c.go")
+	first := map[string]string{a: "package a", b: "package b", c: "package c"}
+	if err := analyzeFiles(t, NewSink(client, outbox, project), first); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if len(fs.codes) != 3 || fs.batches != 1 {
+		t.Fatalf("after first run: %d codes in %d batches, want 3 in 1", len(fs.codes), fs.batches)
+	}
+
+	// b 的内容变化，c 已经被删除，a 没有变化
+	second := map[string]string{a: "package a", b: "package b // changed"}
+	if err := analyzeFiles(t, NewSink(client, outbox, project), second); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if fs.batches != 2 {
+		t.Errorf("batches = %d, want 2", fs.batches)
+	}
+	paths := map[string]string{}
+	for _, snippet := range fs.codes {
+		paths[snippet.FilePath] = snippet.CodeRaw
+	}
+	if len(paths) != 2 || paths[b] != "package b // changed" {
+		t.Errorf("codes after second run = %v", paths)
+	}
+	if _, ok := paths[c]; ok {
+		t.Errorf("snippet of the removed file %s was not deleted", c)
+	}
+}
+
+func TestSinkReuploadsWhenAnalysisMetaChanges(t *testing.T) {
+	fs, server := newFakeServer(t)
+	client := NewApiClientWithAPIKey(server.URL, "api-key")
+	dir := t.TempDir()
+	project := Project{ID: 1, Name: "demo", Language: "go"}
+	outbox := NewOutbox(filepath.Join(dir, "outbox.jsonl"))
+	files := map[string]string{filepath.Join(dir, "a.go"): "package a"}
+
+	meta := entity.ResultMeta{Model: "gpt-4o-mini", PromptVersion: "v1", OutputLanguage: "zh"}
+	for i, m := range []entity.ResultMeta{meta, meta, {Model: "gpt-4o", PromptVersion: "v1", OutputLanguage: "zh"},
+		{Model: "gpt-4o", PromptVersion: "v2", OutputLanguage: "zh"}, {Model: "gpt-4o", PromptVersion: "v2", OutputLanguage: "en"}} {
+		if err := analyzeFilesWith(t, NewSink(client, outbox, project), files, m); err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+	// 第二次运行内容和元数据都没有变化，其余每次运行都有一项元数据变化
+	if fs.batches != 4 {
+		t.Errorf("batches = %d, want 4", fs.batches)
+	}
+	for _, snippet := range fs.codes {
+		if !hasTags(snippet.Tags, []string{"model:gpt-4o", "promptVersion:v2", "outputLanguage:en"}) {
+			t.Errorf("tags = %v, want the latest analysis metadata", snippet.Tags)
+		}
+	}
+}
+
+func TestSinkKeepsSnippetsOfFailedFiles(t *testing.T) {
+	fs, server := newFakeServer(t)
+	client := NewApiClientWithAPIKey(server.URL, "api-key")
+	dir := t.TempDir()
+	project := Project{ID: 1, Name: "demo"}
+	outbox := NewOutbox(filepath.Join(dir, "outbox.jsonl"))
+
+	// 文件仍然存在，只是本次运行没有交给 sink（例如分析失败），不应被删除
+	path := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(path, []byte("package a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzeFiles(t, NewSink(client, outbox, project), map[string]string{path: "package a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzeFiles(t, NewSink(client, outbox, project), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.codes) != 1 {
+		t.Errorf("codes = %d, want 1", len(fs.codes))
+	}
+}
+
+func TestSinkOutboxAndReplay(t *testing.T) {
+	fs, server := newFakeServer(t)
+	client := NewApiClientWithAPIKey(server.URL, "api-key")
+	dir := t.TempDir()
+	outbox := NewOutbox(filepath.Join(dir, "outbox.jsonl"))
+
+	fs.failUploads = true
+	err := analyzeFiles(t, NewSink(client, outbox, Project{ID: 1, Name: "demo"}), map[string]string{"a.go": "package a", "b.go": "package b"})
+	if err == nil || !strings.Contains(err.Error(), "sync") {
+		t.Fatalf("err = %v, want a hint to run sync", err)
+	}
+	queued, err := outbox.Load()
+	if err != nil || len(queued) != 2 {
+		t.Fatalf("outbox = %d snippets, err %v, want 2", len(queued), err)
+	}
+
+	if _, err := outbox.Replay(context.Background(), client, 1); err == nil {
+		t.Fatal("replay against a failing server should return an error")
+	}
+	if queued, _ := outbox.Load(); len(queued) != 2 {
+		t.Fatalf("outbox after failed replay = %d snippets, want 2", len(queued))
+	}
+
+	fs.failUploads = false
+	sent, err := outbox.Replay(context.Background(), client, 1)
+	if err != nil || sent != 2 {
+		t.Fatalf("replay sent %d, err %v", sent, err)
+	}
+	if _, err := os.Stat(outbox.File()); !os.IsNotExist(err) {
+		t.Errorf("outbox should be removed after a successful replay, stat err = %v", err)
+	}
+	if len(fs.codes) != 2 {
+		t.Errorf("codes = %d, want 2", len(fs.codes))
+	}
+}
+
+func TestOutboxKeepsLatestSnippetPerFile(t *testing.T) {
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	if err := outbox.Add([]entity.AICodeSnippet{{ProjectName: "demo", FilePath: "a.go", CodeRaw: "v1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Add([]entity.AICodeSnippet{{ProjectName: "demo", FilePath: "a.go", CodeRaw: "v2"}, {ProjectName: "demo", FilePath: "b.go"}}); err != nil {
+		t.Fatal(err)
+	}
+	snippets, err := outbox.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 2 || snippets[0].CodeRaw != "v2" {
+		t.Errorf("snippets = %+v", snippets)
+	}
+}
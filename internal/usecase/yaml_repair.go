@@ -0,0 +1,376 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"codetest/internal/usecase/prompt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxYAMLRepairRounds 本地修复失败后，请求 LLM 修复 YAML 的最大轮数
+const maxYAMLRepairRounds = 2
+
+var errEmptyYAML = errors.New("empty YAML document")
+
+// unmarshalLLMYAML 容错地解析 LLM 输出的 YAML。
+// 先在本地依次尝试各种修复策略，仍然失败时最多请求 maxYAMLRepairRounds 次 LLM 修复。
+// 返回最终成功解析的 YAML 文本，失败时返回最后一次尝试的文本；client 为 nil 时不做 LLM 修复。
+func unmarshalLLMYAML(ctx context.Context, client LLMClient, prompts *prompt.Set, response string, shape *yamlShape, out interface{}) (string, error) {
+	text, err := repairAndUnmarshal(response, shape, out)
+	if err == nil {
+		return text, nil
+	}
+
+	// 每一轮把上一次的文本和它自己的解析错误交给 LLM
+	for round := 0; client != nil && round < maxYAMLRepairRounds; round++ {
+		fixed, callErr := askLLM(ctx, client, prompts, prompt.YAMLRepair, prompt.Data{YAML: text, Error: err.Error()})
+		if callErr != nil {
+			return text, fmt.Errorf("LLM repair of YAML failed: %w", callErr)
+		}
+
+		fixedText, fixedErr := repairAndUnmarshal(fixed, shape, out)
+		if fixedErr == nil {
+			return fixedText, nil
+		}
+		text, err = fixedText, fixedErr
+	}
+	return text, fmt.Errorf("failed to parse YAML: %v", err)
+}
+
+// repairAndUnmarshal 依次尝试本地修复策略。
+// 成功时返回可解析的 YAML 文本；失败时返回提取出的原始代码块以及未修复时的解析错误。
+func repairAndUnmarshal(response string, shape *yamlShape, out interface{}) (string, error) {
+	block := extractYAMLBlock(response)
+	candidates := []string{block, cleanYAMLResponse(block)}
+	fixed := quoteYAMLValues(expandLeadingTabs(candidates[1]))
+	candidates = append(candidates, fixed)
+	if shape != nil {
+		candidates = append(candidates, reindentYAML(fixed, shape))
+	}
+
+	var firstErr error
+	for _, candidate := range candidates {
+		err := strictUnmarshal(candidate, out)
+		if err == nil {
+			err = checkRootKeys(candidate, shape)
+		}
+		if err == nil {
+			return candidate, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return block, firstErr
+}
+
+// strictUnmarshal 解析 YAML，空文档视为失败，失败时把 out 重置为零值
+func strictUnmarshal(text string, out interface{}) error {
+	target := reflect.ValueOf(out).Elem()
+	target.Set(reflect.Zero(target.Type()))
+
+	if strings.TrimSpace(text) == "" {
+		return errEmptyYAML
+	}
+	if err := yaml.Unmarshal([]byte(text), out); err != nil {
+		target.Set(reflect.Zero(target.Type()))
+		return err
+	}
+	if target.IsZero() {
+		return errEmptyYAML
+	}
+	return nil
+}
+
+// checkRootKeys 检查顶层的字符串值中是否吞进了其他顶层字段，
+// 避免缩进错误时后面的字段被当作块标量的内容而"解析成功"
+func checkRootKeys(text string, shape *yamlShape) error {
+	if shape == nil || shape.fields == nil {
+		return nil
+	}
+
+	var root map[string]interface{}
+	if err := yaml.Unmarshal([]byte(text), &root); err != nil {
+		return err
+	}
+	for name, value := range root {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		for _, line := range strings.Split(str, "\n") {
+			if key, _, ok := splitYAMLKey(strings.TrimSpace(line)); ok && shape.fields[key] != nil {
+				return fmt.Errorf("top-level key %q is swallowed by %q", key, name)
+			}
+		}
+	}
+	return nil
+}
+
+// extractYAMLBlock 提取响应中第一个 ``` 代码块的内容，代码块可以出现在任意位置，
+// 缺少结尾标记时取到响应末尾；没有代码块时返回整个响应
+func extractYAMLBlock(response string) string {
+	start := strings.Index(response, "```")
+	if start < 0 {
+		return dedentBlock(response)
+	}
+
+	rest := response[start+3:]
+	newline := strings.IndexByte(rest, '\n')
+	if newline < 0 {
+		return dedentBlock(strings.Trim(rest, "`"))
+	}
+	rest = rest[newline+1:]
+
+	if end := strings.Index(rest, "```"); end >= 0 {
+		rest = rest[:end]
+	}
+	return dedentBlock(rest)
+}
+
+// dedentBlock 去掉首尾空行以及所有行共同的前导空格
+func dedentBlock(text string) string {
+	lines := strings.Split(strings.Trim(text, "\r\n"), "\n")
+	minIndent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if minIndent < 0 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \r")
+		if len(line) >= minIndent && minIndent > 0 {
+			line = line[minIndent:]
+		}
+		lines[i] = line
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// expandLeadingTabs 把行首的 tab 替换为两个空格
+func expandLeadingTabs(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := line[:len(line)-len(trimmed)]
+		lines[i] = strings.ReplaceAll(indent, "\t", "  ") + trimmed
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	yamlKeyLineRegex  = regexp.MustCompile(`^(\s*(?:- )?)([A-Za-z_][\w.\-]*):(?:\s+(.*))?$`)
+	yamlItemLineRegex = regexp.MustCompile(`^(\s*- )(.*)$`)
+)
+
+// quoteYAMLValues 给含有冒号、以 YAML 特殊字符开头的普通值加上单引号
+func quoteYAMLValues(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if match := yamlKeyLineRegex.FindStringSubmatch(line); match != nil {
+			if needsQuote(match[3]) {
+				lines[i] = match[1] + match[2] + ": " + quoteYAMLScalar(match[3])
+			}
+		} else if match := yamlItemLineRegex.FindStringSubmatch(line); match != nil {
+			if needsQuote(match[2]) {
+				lines[i] = match[1] + quoteYAMLScalar(match[2])
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// needsQuote 判断普通值是否会被 YAML 误解析
+func needsQuote(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+	switch value[0] {
+	case '\'', '"', '|', '>', '[', '{', '#':
+		return false
+	case '*', '&', '!', '%', '@', '`', ',', '?':
+		return true
+	}
+	return strings.Contains(value, ": ") || strings.HasSuffix(value, ":") || strings.Contains(value, " #")
+}
+
+// quoteYAMLScalar 用单引号包裹值
+func quoteYAMLScalar(value string) string {
+	return "'" + strings.ReplaceAll(strings.TrimSpace(value), "'", "''") + "'"
+}
+
+// yamlShape 描述期望的 YAML 结构，用于在缩进混乱时重建缩进。
+// fields 非空表示 mapping，items 非空表示 sequence，都为空表示标量。
+type yamlShape struct {
+	fields map[string]*yamlShape
+	items  *yamlShape
+}
+
+// shapeOf 根据 Go 类型的 yaml tag 推导 YAML 结构
+func shapeOf(t reflect.Type) *yamlShape {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		shape := &yamlShape{fields: map[string]*yamlShape{}}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if strings.Contains(opts, "inline") {
+				for key, sub := range shapeOf(field.Type).fields {
+					shape.fields[key] = sub
+				}
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			shape.fields[name] = shapeOf(field.Type)
+		}
+		return shape
+	case reflect.Slice, reflect.Array:
+		return &yamlShape{items: shapeOf(t.Elem())}
+	default:
+		return &yamlShape{}
+	}
+}
+
+// shapeContext 重建缩进时的上下文，isSeq 表示序列，否则为 mapping
+type shapeContext struct {
+	shape  *yamlShape
+	indent int
+	isSeq  bool
+}
+
+// reindentYAML 忽略原有缩进，按期望的结构重新计算每一行的缩进。
+// 用于修复 LLM 模仿 tab 缩进示例时产生的错位，空行之后出现的顶层字段优先归属到顶层。
+func reindentYAML(text string, shape *yamlShape) string {
+	var out []string
+	stack := []shapeContext{{shape: shape}}
+	blockIndent := -1
+	afterBlank := false
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			out = append(out, "")
+			afterBlank = true
+			continue
+		}
+
+		if blockIndent >= 0 {
+			if key, _, ok := splitYAMLKey(trimmed); !ok || findShapeContext(stack, key, false) < 0 {
+				out = append(out, strings.Repeat(" ", blockIndent)+trimmed)
+				continue
+			}
+			blockIndent = -1
+		}
+
+		item := strings.HasPrefix(trimmed, "- ") || trimmed == "-"
+		if item {
+			idx := len(stack) - 1
+			for idx > 0 && !stack[idx].isSeq {
+				idx--
+			}
+			if !stack[idx].isSeq {
+				out = append(out, strings.Repeat(" ", stack[len(stack)-1].indent)+trimmed)
+				continue
+			}
+			stack = stack[:idx+1]
+			seq := stack[idx]
+			content := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			out = append(out, strings.Repeat(" ", seq.indent)+"- "+content)
+
+			itemShape := seq.shape.items
+			if itemShape == nil || itemShape.fields == nil {
+				continue
+			}
+			stack = append(stack, shapeContext{shape: itemShape, indent: seq.indent + 2})
+			if key, value, ok := splitYAMLKey(content); ok {
+				stack, blockIndent = openYAMLValue(stack, itemShape.fields[key], key, value, seq.indent+2)
+			}
+			afterBlank = false
+			continue
+		}
+
+		key, value, ok := splitYAMLKey(trimmed)
+		if !ok {
+			out = append(out, strings.Repeat(" ", stack[len(stack)-1].indent+2)+trimmed)
+			continue
+		}
+
+		idx := findShapeContext(stack, key, afterBlank)
+		var sub *yamlShape
+		if idx < 0 {
+			idx = len(stack) - 1
+			for idx > 0 && stack[idx].isSeq {
+				idx--
+			}
+		} else {
+			sub = stack[idx].shape.fields[key]
+		}
+		stack = stack[:idx+1]
+		indent := stack[idx].indent
+		out = append(out, strings.Repeat(" ", indent)+trimmed)
+		stack, blockIndent = openYAMLValue(stack, sub, key, value, indent)
+		afterBlank = false
+	}
+	return strings.Join(out, "\n")
+}
+
+// openYAMLValue 根据字段的值类型压入新的上下文，返回块标量内容的缩进（-1 表示不是块标量）
+func openYAMLValue(stack []shapeContext, sub *yamlShape, key, value string, indent int) ([]shapeContext, int) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+		return stack, indent + 2
+	}
+	if value != "" || sub == nil {
+		return stack, -1
+	}
+	if sub.items != nil {
+		return append(stack, shapeContext{shape: sub, indent: indent + 2, isSeq: true}), -1
+	}
+	if sub.fields != nil {
+		return append(stack, shapeContext{shape: sub, indent: indent + 2}), -1
+	}
+	return stack, -1
+}
+
+// findShapeContext 从栈顶向下查找包含 key 的 mapping 上下文，preferRoot 为 true 时优先匹配顶层
+func findShapeContext(stack []shapeContext, key string, preferRoot bool) int {
+	if preferRoot && stack[0].shape.fields[key] != nil {
+		return 0
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if !stack[i].isSeq && stack[i].shape.fields[key] != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitYAMLKey 拆分 "key: value" 形式的行
+func splitYAMLKey(line string) (string, string, bool) {
+	match := yamlKeyLineRegex.FindStringSubmatch(line)
+	if match == nil || match[1] != "" {
+		return "", "", false
+	}
+	return match[2], match[3], true
+}
@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codetest/internal/entity"
+	"codetest/internal/usecase/prompt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stubLLMClient 按顺序返回预设回复的 LLM 客户端
+type stubLLMClient struct {
+	responses []string
+	prompts   []string
+	err       error // 预设回复用完后返回的错误
+}
+
+func (s *stubLLMClient) GetResponse(ctx context.Context, prompt string) (entity.LLMResponse, error) {
+	s.prompts = append(s.prompts, prompt)
+	if len(s.responses) == 0 {
+		if s.err != nil {
+			return entity.LLMResponse{}, s.err
+		}
+		return entity.LLMResponse{}, fmt.Errorf("no more stub responses")
+	}
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+	return entity.LLMResponse{Content: response, Model: "gpt-4o-mini"}, nil
+}
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	return readFile(t, filepath.Join("testdata", "yaml_repair", name))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRepairAnalysisFixtures(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		packageName string
+		imports     int
+	}{
+		{"analysis_preamble_and_trailer.txt", "cmd", 1},
+		{"analysis_tab_indented_sample.txt", "web_api", 4},
+		{"analysis_unquoted_colons.txt", "cmd", 3},
+		{"analysis_alias_values.txt", "workflow_server", 2},
+		{"analysis_plain_fence_truncated.txt", "entity", 0},
+		{"analysis_mixed_indent.txt", "repo", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			raw := readFixture(t, tt.fixture)
+			var direct entity.ParsedYAML
+			if yaml.Unmarshal([]byte(raw), &direct) == nil && direct.FileInfo.PackageName == tt.packageName {
+				t.Fatalf("fixture parses without repair, it does not belong in the corpus")
+			}
+
+			var parsed entity.ParsedYAML
+			text, err := unmarshalLLMYAML(context.Background(), nil, nil, raw, analysisShape, &parsed)
+			if err != nil {
+				t.Fatalf("unmarshalLLMYAML: %v", err)
+			}
+			if parsed.FileInfo.PackageName != tt.packageName {
+				t.Errorf("package_name = %q, want %q", parsed.FileInfo.PackageName, tt.packageName)
+			}
+			if len(parsed.FileInfo.Imports) != tt.imports {
+				t.Errorf("imports = %v, want %d entries", parsed.FileInfo.Imports, tt.imports)
+			}
+			if strings.TrimSpace(parsed.FileDescription) == "" {
+				t.Errorf("file_description is empty")
+			}
+
+			// 保存的是修复后的文本，需要能被再次解析
+			var doc map[string]interface{}
+			if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+				t.Errorf("repaired text is not valid YAML: %v\n%s", err, text)
+			}
+		})
+	}
+}
+
+func TestRepairTabIndentedSampleKeepsNesting(t *testing.T) {
+	var full entity.FileAnalysis
+	if _, err := unmarshalLLMYAML(context.Background(), nil, nil, readFixture(t, "analysis_tab_indented_sample.txt"), analysisShape, &full); err != nil {
+		t.Fatalf("unmarshalLLMYAML: %v", err)
+	}
+	if len(full.Structs) != 1 || len(full.Structs[0].Methods) != 2 {
+		t.Fatalf("structs = %+v, want one struct with two methods", full.Structs)
+	}
+	if len(full.Methods) != 1 || full.Methods[0].Name != "NewChatGPTClient" {
+		t.Errorf("top-level methods = %+v, want NewChatGPTClient", full.Methods)
+	}
+}
+
+func TestRepairStep1Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		files   []string
+	}{
+		{"step1_with_prose.txt", []string{"walk.go", "cmd/analyze.go"}},
+		{"step1_tab_indented.txt", []string{"internal/usecase/ai_code.go", "cmd/question.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			infos, err := parseStep1FileInfos(context.Background(), nil, nil, readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("parseStep1FileInfos: %v", err)
+			}
+			if len(infos) != len(tt.files) {
+				t.Fatalf("got %d files, want %d", len(infos), len(tt.files))
+			}
+			for i, info := range infos {
+				if info.File != tt.files[i] || info.Why == "" {
+					t.Errorf("info[%d] = %+v, want file %q with a reason", i, info, tt.files[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRepairFallsBackToLLM(t *testing.T) {
+	client := &stubLLMClient{responses: []string{
+		"```yaml\nfile_description: 修复后的描述\nfile_info:\n  package_name: fixed\n```",
+	}}
+
+	var parsed entity.ParsedYAML
+	text, err := unmarshalLLMYAML(context.Background(), client, prompt.Default(), "file_info: [unclosed\n  package_name: broken", analysisShape, &parsed)
+	if err != nil {
+		t.Fatalf("unmarshalLLMYAML: %v", err)
+	}
+	if parsed.FileInfo.PackageName != "fixed" || !strings.Contains(text, "package_name: fixed") {
+		t.Errorf("parsed = %+v, text = %q", parsed, text)
+	}
+	if len(client.prompts) != 1 || !strings.Contains(client.prompts[0], "file_info: [unclosed") {
+		t.Errorf("repair prompt should contain the broken YAML, prompts = %q", client.prompts)
+	}
+}
+
+func TestRepairLLMRoundsAreBounded(t *testing.T) {
+	client := &stubLLMClient{}
+	for i := 0; i < maxYAMLRepairRounds+2; i++ {
+		client.responses = append(client.responses, "抱歉，我无法修复这段内容: [")
+	}
+
+	var parsed entity.ParsedYAML
+	if _, err := unmarshalLLMYAML(context.Background(), client, prompt.Default(), "file_info: [unclosed", analysisShape, &parsed); err == nil {
+		t.Fatal("expected an error for unrepairable YAML")
+	}
+	if len(client.prompts) != maxYAMLRepairRounds {
+		t.Errorf("LLM called %d times, want %d", len(client.prompts), maxYAMLRepairRounds)
+	}
+}
+
+func TestRepairLLMRoundsSendTheirOwnError(t *testing.T) {
+	client := &stubLLMClient{responses: []string{
+		"file_info: {second: [broken",
+		"```yaml\nfile_description: 第二轮修好了\n```",
+	}}
+
+	var parsed entity.ParsedYAML
+	if _, err := unmarshalLLMYAML(context.Background(), client, prompt.Default(), "file_info: [unclosed", analysisShape, &parsed); err != nil {
+		t.Fatalf("unmarshalLLMYAML: %v", err)
+	}
+	if len(client.prompts) != 2 {
+		t.Fatalf("LLM called %d times, want 2", len(client.prompts))
+	}
+	// 第二轮的 prompt 包含第一轮的输出，而不是最初的文本
+	second := client.prompts[1]
+	if !strings.Contains(second, "second: [broken") || strings.Contains(second, "file_info: [unclosed") {
+		t.Errorf("second repair prompt does not pair the previous output with its error:\n%s", second)
+	}
+}
+
+func TestRepairKeepsLLMErrorsDetectable(t *testing.T) {
+	client := &stubLLMClient{responses: []string{"file_info: [unclosed"}, err: ErrBudgetExceeded}
+	uc := NewAiCode(client, prompt.Default())
+
+	_, _, err := uc.AIAnalysisCode(context.Background(), "main.go", "package main")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("AIAnalysisCode error = %v, want ErrBudgetExceeded", err)
+	}
+}
@@ -1,7 +1,7 @@
 package code
 
 import (
-	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -29,19 +29,28 @@ func isIgnored(dir string) bool {
 
 // WalkDir 遍历目录并输出所有的 .go 文件
 func WalkDir(dir string, callback func(path string)) error {
+	return walkGoFiles(dir, false, callback)
+}
+
+// WalkDirWithTests 与 WalkDir 相同，但也输出 _test.go 文件
+func WalkDirWithTests(dir string, callback func(path string)) error {
+	return walkGoFiles(dir, true, callback)
+}
+
+func walkGoFiles(dir string, withTests bool, callback func(path string)) error {
 	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			//fmt.Println("Error:", err)
 			return err
 		}
 
-		if strings.HasSuffix(path, "_test.go") {
+		if !withTests && strings.HasSuffix(path, "_test.go") {
 			//fmt.Println("Skip:", path)
 			return nil
 		}
 
 		if !info.IsDir() && filepath.Ext(path) == ".go" && !isIgnored(path) {
-			fmt.Println(path)
+			slog.Debug("Found file", "path", path)
 			callback(path)
 		}
 		return nil
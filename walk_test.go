@@ -1,20 +1,78 @@
 package code
 
 import (
-	"codetest/internal/usecase/web_api"
-	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 )
 
 func TestWalkDir(t *testing.T) {
-	parse := web_api.NewParser()
-	WalkDir("", func(path string) {
-		fmt.Print(path)
-		file, err := parse.ParseByFile(path)
-		if err != nil {
-			return
+	root := t.TempDir()
+	files := []string{
+		"main.go",
+		"main_test.go",
+		"README.md",
+		"internal/service/service.go",
+		"internal/service/mocks/service_mock.go",
+		"vendor/github.com/foo/foo.go",
+		"testdata/fixture.go",
+		".git/hooks/hook.go",
+	}
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
 		}
-		file.PrintResults()
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
 
+	var got []string
+	err := WalkDir(root, func(path string) {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
 	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+
+	sort.Strings(got)
+	want := []string{"internal/service/service.go", "main.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkDir visited %v, want %v", got, want)
+	}
+}
+
+func TestWalkDirWithTests(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"main.go", "main_test.go", "testdata/fixture_test.go"} {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	if err := WalkDirWithTests(root, func(path string) {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
+	}); err != nil {
+		t.Fatalf("WalkDirWithTests: %v", err)
+	}
+	sort.Strings(got)
+	if want := []string{"main.go", "main_test.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkDirWithTests visited %v, want %v", got, want)
+	}
+}
+
+func TestWalkDirMissingRoot(t *testing.T) {
+	if err := WalkDir(filepath.Join(t.TempDir(), "missing"), func(string) {}); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
 }